@@ -17,6 +17,9 @@ type Token interface {
 
 // Adapter is the contract for exchange market-data connectors.
 type Adapter interface {
+	// Name returns the exchange name used in candle.Candle.Exchange.
+	Name() string
+
 	// Subscribe registers handler to receive live candle updates for
 	// symbol/interval. Returns a Token that cancels the subscription.
 	Subscribe(symbol, interval string, handler CandleHandler) (Token, error)
@@ -25,6 +28,10 @@ type Adapter interface {
 	// Uses the exchange REST API internally.
 	Backfill(symbol, interval string, start, end time.Time) ([]*candle.Candle, error)
 
+	// Status reports the connection state of every active subscription.
+	Status() []SubscriptionStatus
+
 	// Close shuts down all active subscriptions and releases resources.
+	// It blocks until every WebSocket session has closed.
 	Close() error
 }
@@ -3,23 +3,58 @@ package binance
 import (
 	"context"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/yitech/candles/adapter"
+	"github.com/yitech/candles/metrics"
 	"github.com/yitech/candles/model/candle"
 )
 
+// Default endpoints.
+const (
+	DefaultRESTURL = "https://api.binance.com"
+	DefaultWSURL   = "wss://stream.binance.com:9443/ws"
+)
+
+// Options configures an Adapter. Zero fields take the defaults.
+type Options struct {
+	RESTURL     string        // REST base URL
+	WSURL       string        // WebSocket base URL
+	HTTPTimeout time.Duration // REST request timeout (default 30s)
+}
+
 // Adapter is the Binance exchange adapter.
 type Adapter struct {
 	httpClient *http.Client
+	restURL    string
+	wsURL      string
 	ctx        context.Context
 	cancel     context.CancelFunc
+	wg         sync.WaitGroup // one per subscription goroutine
+	subs       adapter.SubSet
 }
 
 func New() *Adapter {
+	return NewWithOptions(Options{})
+}
+
+// NewWithOptions creates an Adapter with custom endpoints and timeouts.
+func NewWithOptions(opts Options) *Adapter {
+	if opts.RESTURL == "" {
+		opts.RESTURL = DefaultRESTURL
+	}
+	if opts.WSURL == "" {
+		opts.WSURL = DefaultWSURL
+	}
+	if opts.HTTPTimeout <= 0 {
+		opts.HTTPTimeout = 30 * time.Second
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Adapter{
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+		httpClient: &http.Client{Timeout: opts.HTTPTimeout},
+		restURL:    opts.RESTURL,
+		wsURL:      opts.WSURL,
 		ctx:        ctx,
 		cancel:     cancel,
 	}
@@ -28,16 +63,27 @@ func New() *Adapter {
 // Subscribe opens a WebSocket kline stream for symbol/interval.
 // The returned Token cancels this specific subscription.
 func (a *Adapter) Subscribe(symbol, interval string, handler adapter.CandleHandler) (adapter.Token, error) {
-	return subscribeKline(a.ctx, symbol, interval, handler)
+	return subscribeKline(a.ctx, &a.wg, &a.subs, a.wsURL, symbol, interval, handler)
 }
 
+// Name returns "binance", the Exchange of every candle this adapter emits.
+func (a *Adapter) Name() string { return "binance" }
+
+// Status reports the connection state of every active subscription.
+func (a *Adapter) Status() []adapter.SubscriptionStatus { return a.subs.Status() }
+
 // Backfill fetches historical klines via the Binance REST API.
 func (a *Adapter) Backfill(symbol, interval string, start, end time.Time) ([]*candle.Candle, error) {
-	return fetchKlines(a.ctx, a.httpClient, symbol, interval, start.UnixMilli(), end.UnixMilli())
+	t0 := time.Now()
+	out, err := fetchKlines(a.ctx, a.httpClient, a.restURL, symbol, interval, start.UnixMilli(), end.UnixMilli())
+	metrics.ObserveBackfill("binance", t0, err)
+	return out, err
 }
 
-// Close cancels all active subscriptions and releases resources.
+// Close cancels all active subscriptions and waits for their WebSocket
+// sessions to close.
 func (a *Adapter) Close() error {
 	a.cancel()
+	a.wg.Wait()
 	return nil
 }
@@ -12,18 +12,17 @@ import (
 )
 
 const (
-	baseURL   = "https://api.binance.com"
 	klinePath = "/api/v3/klines"
 	maxLimit  = 1000
 )
 
 // fetchKlines requests historical klines from the Binance REST API,
 // paginating automatically until the full [startMs, endMs] range is covered.
-func fetchKlines(ctx context.Context, client *http.Client, symbol, interval string, startMs, endMs int64) ([]*candle.Candle, error) {
+func fetchKlines(ctx context.Context, client *http.Client, baseURL, symbol, interval string, startMs, endMs int64) ([]*candle.Candle, error) {
 	var out []*candle.Candle
 
 	for {
-		batch, err := fetchBatch(ctx, client, symbol, interval, startMs, endMs)
+		batch, err := fetchBatch(ctx, client, baseURL, symbol, interval, startMs, endMs)
 		if err != nil {
 			return nil, err
 		}
@@ -45,7 +44,7 @@ func fetchKlines(ctx context.Context, client *http.Client, symbol, interval stri
 }
 
 // fetchBatch fetches a single page (up to maxLimit candles) from the API.
-func fetchBatch(ctx context.Context, client *http.Client, symbol, interval string, startMs, endMs int64) ([]*candle.Candle, error) {
+func fetchBatch(ctx context.Context, client *http.Client, baseURL, symbol, interval string, startMs, endMs int64) ([]*candle.Candle, error) {
 	u, err := url.Parse(baseURL + klinePath)
 	if err != nil {
 		return nil, fmt.Errorf("binance: parse url: %w", err)
@@ -6,15 +6,19 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 
 	"github.com/yitech/candles/adapter"
+	"github.com/yitech/candles/metrics"
 	"github.com/yitech/candles/model/candle"
 )
 
-const wsBaseURL = "wss://stream.binance.com:9443/ws"
+// closeGrace bounds how long a cancelled session waits for the exchange to
+// acknowledge its close frame.
+const closeGrace = time.Second
 
 // token implements adapter.Token for a single Binance kline subscription.
 type token struct {
@@ -26,22 +30,35 @@ func (t *token) Unsubscribe() { t.cancel() }
 // subscribeKline opens a Binance WebSocket kline stream for symbol/interval,
 // invoking handler for every update. It reconnects automatically on error.
 // Returns a Token to cancel the subscription.
-func subscribeKline(ctx context.Context, symbol, interval string, handler adapter.CandleHandler) (adapter.Token, error) {
+func subscribeKline(ctx context.Context, wg *sync.WaitGroup, subs *adapter.SubSet, wsBaseURL, symbol, interval string, handler adapter.CandleHandler) (adapter.Token, error) {
 	ctx, cancel := context.WithCancel(ctx)
 
+	market := symbol + ":" + interval
+	backoffGauge := metrics.Backoff.WithLabelValues("binance", market)
+	st := adapter.NewSubState(symbol, interval)
+	subs.Add(st)
+
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
+		defer subs.Remove(st)
+		defer backoffGauge.Set(0)
 		backoff := time.Second
 		for {
 			if ctx.Err() != nil {
 				return
 			}
-			if err := connectAndRead(ctx, symbol, interval, handler); err != nil && ctx.Err() == nil {
+			if err := connectAndRead(ctx, st, wsBaseURL, symbol, interval, handler); err != nil && ctx.Err() == nil {
+				metrics.Reconnects.WithLabelValues("binance", market).Inc()
+				st.Reconnected()
 				log.Printf("binance ws [%s/%s]: %v — reconnecting in %v", symbol, interval, err, backoff)
+				backoffGauge.Set(backoff.Seconds())
 				select {
 				case <-time.After(backoff):
 				case <-ctx.Done():
 					return
 				}
+				backoffGauge.Set(0)
 				if backoff < 30*time.Second {
 					backoff *= 2
 				}
@@ -56,7 +73,7 @@ func subscribeKline(ctx context.Context, symbol, interval string, handler adapte
 
 // connectAndRead maintains a single WebSocket session until the context is
 // cancelled or an error occurs.
-func connectAndRead(ctx context.Context, symbol, interval string, handler adapter.CandleHandler) error {
+func connectAndRead(ctx context.Context, st *adapter.SubState, wsBaseURL, symbol, interval string, handler adapter.CandleHandler) error {
 	streamName := strings.ToLower(symbol) + "@kline_" + interval
 	u := wsBaseURL + "/" + streamName
 
@@ -66,12 +83,33 @@ func connectAndRead(ctx context.Context, symbol, interval string, handler adapte
 	}
 	defer conn.Close()
 
-	// Close the connection when the context is cancelled.
+	market := symbol + ":" + interval
+	connected := metrics.Connected.WithLabelValues("binance", market)
+	connected.Set(1)
+	defer connected.Set(0)
+	st.SetConnected(true)
+	defer st.SetConnected(false)
+	received := metrics.CandlesReceived.WithLabelValues("binance", market)
+	parseErrors := metrics.ParseErrors.WithLabelValues("binance", market)
+
+	// On cancellation send a close frame and give the exchange closeGrace
+	// to answer it (ending the read loop) before tearing the socket down.
+	done := make(chan struct{})
+	defer close(done)
 	go func() {
-		<-ctx.Done()
-		conn.WriteMessage(websocket.CloseMessage,
-			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-		conn.Close()
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+		}
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+			time.Now().Add(closeGrace))
+		select {
+		case <-done:
+		case <-time.After(closeGrace):
+			conn.Close()
+		}
 	}()
 
 	for {
@@ -86,8 +124,11 @@ func connectAndRead(ctx context.Context, symbol, interval string, handler adapte
 		c, err := parseWsKline(msg)
 		if err != nil {
 			log.Printf("binance ws [%s/%s]: parse error: %v", symbol, interval, err)
+			parseErrors.Inc()
 			continue
 		}
+		received.Inc()
+		st.Touch()
 		handler(c)
 	}
 }
@@ -3,25 +3,66 @@ package bybit
 import (
 	"context"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/yitech/candles/adapter"
+	"github.com/yitech/candles/metrics"
 	"github.com/yitech/candles/model/candle"
 )
 
+// Default endpoints. The WebSocket URL is suffixed with the category
+// (e.g. ".../v5/public/linear").
+const (
+	DefaultRESTURL  = "https://api.bybit.com"
+	DefaultWSURL    = "wss://stream.bybit.com/v5/public"
+	DefaultCategory = "linear"
+)
+
+// Options configures an Adapter. Zero fields take the defaults.
+type Options struct {
+	RESTURL     string        // REST base URL
+	WSURL       string        // WebSocket base URL, without the category
+	Category    string        // "linear" | "spot" | "inverse"
+	HTTPTimeout time.Duration // REST request timeout (default 30s)
+}
+
 // Adapter is the Bybit exchange adapter.
 type Adapter struct {
 	httpClient *http.Client
+	restURL    string
+	wsURL      string
 	category   string // "linear" | "spot" | "inverse"
 	ctx        context.Context
 	cancel     context.CancelFunc
+	wg         sync.WaitGroup // one per subscription goroutine
+	subs       adapter.SubSet
 }
 
 func New() *Adapter {
+	return NewWithOptions(Options{})
+}
+
+// NewWithOptions creates an Adapter with custom endpoints and timeouts.
+func NewWithOptions(opts Options) *Adapter {
+	if opts.RESTURL == "" {
+		opts.RESTURL = DefaultRESTURL
+	}
+	if opts.WSURL == "" {
+		opts.WSURL = DefaultWSURL
+	}
+	if opts.Category == "" {
+		opts.Category = DefaultCategory
+	}
+	if opts.HTTPTimeout <= 0 {
+		opts.HTTPTimeout = 30 * time.Second
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Adapter{
-		httpClient: &http.Client{Timeout: 30 * time.Second},
-		category:   "linear",
+		httpClient: &http.Client{Timeout: opts.HTTPTimeout},
+		restURL:    opts.RESTURL,
+		wsURL:      opts.WSURL + "/" + opts.Category,
+		category:   opts.Category,
 		ctx:        ctx,
 		cancel:     cancel,
 	}
@@ -30,16 +71,27 @@ func New() *Adapter {
 // Subscribe opens a WebSocket kline stream for symbol/interval.
 // The returned Token cancels this specific subscription.
 func (a *Adapter) Subscribe(symbol, interval string, handler adapter.CandleHandler) (adapter.Token, error) {
-	return subscribeKline(a.ctx, a.category, symbol, interval, handler)
+	return subscribeKline(a.ctx, &a.wg, &a.subs, a.wsURL, a.category, symbol, interval, handler)
 }
 
+// Name returns "bybit", the Exchange of every candle this adapter emits.
+func (a *Adapter) Name() string { return "bybit" }
+
+// Status reports the connection state of every active subscription.
+func (a *Adapter) Status() []adapter.SubscriptionStatus { return a.subs.Status() }
+
 // Backfill fetches historical klines via the Bybit REST API.
 func (a *Adapter) Backfill(symbol, interval string, start, end time.Time) ([]*candle.Candle, error) {
-	return fetchKlines(a.ctx, a.httpClient, a.category, symbol, interval, start.UnixMilli(), end.UnixMilli())
+	t0 := time.Now()
+	out, err := fetchKlines(a.ctx, a.httpClient, a.restURL, a.category, symbol, interval, start.UnixMilli(), end.UnixMilli())
+	metrics.ObserveBackfill("bybit", t0, err)
+	return out, err
 }
 
-// Close cancels all active subscriptions and releases resources.
+// Close cancels all active subscriptions and waits for their WebSocket
+// sessions to close.
 func (a *Adapter) Close() error {
 	a.cancel()
+	a.wg.Wait()
 	return nil
 }
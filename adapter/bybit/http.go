@@ -12,7 +12,6 @@ import (
 )
 
 const (
-	baseURL   = "https://api.bybit.com"
 	klinePath = "/v5/market/kline"
 	maxLimit  = 200
 )
@@ -22,12 +21,12 @@ const (
 //
 // Bybit returns candles newest-first; this function reverses the result
 // to chronological order before returning.
-func fetchKlines(ctx context.Context, client *http.Client, category, symbol, interval string, startMs, endMs int64) ([]*candle.Candle, error) {
+func fetchKlines(ctx context.Context, client *http.Client, baseURL, category, symbol, interval string, startMs, endMs int64) ([]*candle.Candle, error) {
 	var all []*candle.Candle
 	end := endMs
 
 	for {
-		batch, err := fetchBatch(ctx, client, category, symbol, interval, startMs, end)
+		batch, err := fetchBatch(ctx, client, baseURL, category, symbol, interval, startMs, end)
 		if err != nil {
 			return nil, err
 		}
@@ -55,7 +54,7 @@ func fetchKlines(ctx context.Context, client *http.Client, category, symbol, int
 }
 
 // fetchBatch fetches a single page from the Bybit kline endpoint.
-func fetchBatch(ctx context.Context, client *http.Client, category, symbol, interval string, startMs, endMs int64) ([]*candle.Candle, error) {
+func fetchBatch(ctx context.Context, client *http.Client, baseURL, category, symbol, interval string, startMs, endMs int64) ([]*candle.Candle, error) {
 	u, err := url.Parse(baseURL + klinePath)
 	if err != nil {
 		return nil, fmt.Errorf("bybit: parse url: %w", err)
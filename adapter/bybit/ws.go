@@ -5,19 +5,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 
 	"github.com/yitech/candles/adapter"
+	"github.com/yitech/candles/metrics"
 	"github.com/yitech/candles/model/candle"
 )
 
-const wsURL = "wss://stream.bybit.com/v5/public/linear"
-
 // pingInterval is how often we send a heartbeat to keep the connection alive.
 const pingInterval = 20 * time.Second
 
+// closeGrace bounds how long a cancelled session waits for the exchange to
+// acknowledge its close frame.
+const closeGrace = time.Second
+
 // token implements adapter.Token for a single Bybit kline subscription.
 type token struct {
 	cancel context.CancelFunc
@@ -27,22 +31,35 @@ func (t *token) Unsubscribe() { t.cancel() }
 
 // subscribeKline opens a Bybit WebSocket kline stream for category/symbol/interval,
 // invoking handler for every update. It reconnects automatically on error.
-func subscribeKline(ctx context.Context, category, symbol, interval string, handler adapter.CandleHandler) (adapter.Token, error) {
+func subscribeKline(ctx context.Context, wg *sync.WaitGroup, subs *adapter.SubSet, wsURL, category, symbol, interval string, handler adapter.CandleHandler) (adapter.Token, error) {
 	ctx, cancel := context.WithCancel(ctx)
 
+	market := symbol + ":" + interval
+	backoffGauge := metrics.Backoff.WithLabelValues("bybit", market)
+	st := adapter.NewSubState(symbol, interval)
+	subs.Add(st)
+
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
+		defer subs.Remove(st)
+		defer backoffGauge.Set(0)
 		backoff := time.Second
 		for {
 			if ctx.Err() != nil {
 				return
 			}
-			if err := connectAndRead(ctx, category, symbol, interval, handler); err != nil && ctx.Err() == nil {
+			if err := connectAndRead(ctx, st, wsURL, category, symbol, interval, handler); err != nil && ctx.Err() == nil {
+				metrics.Reconnects.WithLabelValues("bybit", market).Inc()
+				st.Reconnected()
 				log.Printf("bybit ws [%s/%s]: %v — reconnecting in %v", symbol, interval, err, backoff)
+				backoffGauge.Set(backoff.Seconds())
 				select {
 				case <-time.After(backoff):
 				case <-ctx.Done():
 					return
 				}
+				backoffGauge.Set(0)
 				if backoff < 30*time.Second {
 					backoff *= 2
 				}
@@ -56,19 +73,40 @@ func subscribeKline(ctx context.Context, category, symbol, interval string, hand
 }
 
 // connectAndRead maintains a single Bybit WebSocket session.
-func connectAndRead(ctx context.Context, category, symbol, interval string, handler adapter.CandleHandler) error {
+func connectAndRead(ctx context.Context, st *adapter.SubState, wsURL, category, symbol, interval string, handler adapter.CandleHandler) error {
 	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
 	if err != nil {
 		return fmt.Errorf("dial: %w", err)
 	}
 	defer conn.Close()
 
-	// Close connection on context cancellation.
+	market := symbol + ":" + interval
+	connected := metrics.Connected.WithLabelValues("bybit", market)
+	connected.Set(1)
+	defer connected.Set(0)
+	st.SetConnected(true)
+	defer st.SetConnected(false)
+	received := metrics.CandlesReceived.WithLabelValues("bybit", market)
+	parseErrors := metrics.ParseErrors.WithLabelValues("bybit", market)
+
+	// On cancellation send a close frame and give the exchange closeGrace
+	// to answer it (ending the read loop) before tearing the socket down.
+	done := make(chan struct{})
+	defer close(done)
 	go func() {
-		<-ctx.Done()
-		conn.WriteMessage(websocket.CloseMessage,
-			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-		conn.Close()
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+		}
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+			time.Now().Add(closeGrace))
+		select {
+		case <-done:
+		case <-time.After(closeGrace):
+			conn.Close()
+		}
 	}()
 
 	// Send subscribe message.
@@ -109,9 +147,12 @@ func connectAndRead(ctx context.Context, category, symbol, interval string, hand
 		candles, err := parseWsMessage(symbol, msg)
 		if err != nil {
 			log.Printf("bybit ws [%s/%s]: parse error: %v", symbol, interval, err)
+			parseErrors.Inc()
 			continue
 		}
 		for _, c := range candles {
+			received.Inc()
+			st.Touch()
 			handler(c)
 		}
 	}
@@ -13,7 +13,6 @@ import (
 )
 
 const (
-	baseURL   = "https://www.okx.com"
 	klinePath = "/api/v5/market/history-candles"
 	maxLimit  = 100
 )
@@ -23,14 +22,14 @@ const (
 //
 // OKX returns candles newest-first using cursor-based pagination via the
 // `after` parameter; this function reverses the result to chronological order.
-func fetchKlines(ctx context.Context, client *http.Client, instID, bar string, startMs, endMs int64) ([]*candle.Candle, error) {
+func fetchKlines(ctx context.Context, client *http.Client, baseURL, instID, bar string, startMs, endMs int64) ([]*candle.Candle, error) {
 	var all []*candle.Candle
 
 	// after=T returns candles with ts < T, so seed with endMs+1 to include endMs.
 	after := strconv.FormatInt(endMs+1, 10)
 
 	for {
-		batch, err := fetchBatch(ctx, client, instID, bar, after)
+		batch, err := fetchBatch(ctx, client, baseURL, instID, bar, after)
 		if err != nil {
 			return nil, err
 		}
@@ -64,7 +63,7 @@ func fetchKlines(ctx context.Context, client *http.Client, instID, bar string, s
 }
 
 // fetchBatch fetches a single page from the OKX history-candles endpoint.
-func fetchBatch(ctx context.Context, client *http.Client, instID, bar, after string) ([]*candle.Candle, error) {
+func fetchBatch(ctx context.Context, client *http.Client, baseURL, instID, bar, after string) ([]*candle.Candle, error) {
 	u, err := url.Parse(baseURL + klinePath)
 	if err != nil {
 		return nil, fmt.Errorf("okx: parse url: %w", err)
@@ -3,23 +3,58 @@ package okx
 import (
 	"context"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/yitech/candles/adapter"
+	"github.com/yitech/candles/metrics"
 	"github.com/yitech/candles/model/candle"
 )
 
+// Default endpoints.
+const (
+	DefaultRESTURL = "https://www.okx.com"
+	DefaultWSURL   = "wss://ws.okx.com:8443/ws/v5/public"
+)
+
+// Options configures an Adapter. Zero fields take the defaults.
+type Options struct {
+	RESTURL     string        // REST base URL
+	WSURL       string        // WebSocket base URL
+	HTTPTimeout time.Duration // REST request timeout (default 30s)
+}
+
 // Adapter is the OKX exchange adapter.
 type Adapter struct {
 	httpClient *http.Client
+	restURL    string
+	wsURL      string
 	ctx        context.Context
 	cancel     context.CancelFunc
+	wg         sync.WaitGroup // one per subscription goroutine
+	subs       adapter.SubSet
 }
 
 func New() *Adapter {
+	return NewWithOptions(Options{})
+}
+
+// NewWithOptions creates an Adapter with custom endpoints and timeouts.
+func NewWithOptions(opts Options) *Adapter {
+	if opts.RESTURL == "" {
+		opts.RESTURL = DefaultRESTURL
+	}
+	if opts.WSURL == "" {
+		opts.WSURL = DefaultWSURL
+	}
+	if opts.HTTPTimeout <= 0 {
+		opts.HTTPTimeout = 30 * time.Second
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Adapter{
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+		httpClient: &http.Client{Timeout: opts.HTTPTimeout},
+		restURL:    opts.RESTURL,
+		wsURL:      opts.WSURL,
 		ctx:        ctx,
 		cancel:     cancel,
 	}
@@ -30,16 +65,27 @@ func New() *Adapter {
 // Note: OKX uses hyphenated instrument IDs (e.g. "BTC-USDT") and
 // suffixed bar notation (e.g. "1m", "4H", "1D").
 func (a *Adapter) Subscribe(symbol, interval string, handler adapter.CandleHandler) (adapter.Token, error) {
-	return subscribeKline(a.ctx, symbol, interval, handler)
+	return subscribeKline(a.ctx, &a.wg, &a.subs, a.wsURL, symbol, interval, handler)
 }
 
+// Name returns "okx", the Exchange of every candle this adapter emits.
+func (a *Adapter) Name() string { return "okx" }
+
+// Status reports the connection state of every active subscription.
+func (a *Adapter) Status() []adapter.SubscriptionStatus { return a.subs.Status() }
+
 // Backfill fetches historical klines via the OKX REST API.
 func (a *Adapter) Backfill(symbol, interval string, start, end time.Time) ([]*candle.Candle, error) {
-	return fetchKlines(a.ctx, a.httpClient, symbol, interval, start.UnixMilli(), end.UnixMilli())
+	t0 := time.Now()
+	out, err := fetchKlines(a.ctx, a.httpClient, a.restURL, symbol, interval, start.UnixMilli(), end.UnixMilli())
+	metrics.ObserveBackfill("okx", t0, err)
+	return out, err
 }
 
-// Close cancels all active subscriptions and releases resources.
+// Close cancels all active subscriptions and waits for their WebSocket
+// sessions to close.
 func (a *Adapter) Close() error {
 	a.cancel()
+	a.wg.Wait()
 	return nil
 }
@@ -6,15 +6,19 @@ import (
 	"fmt"
 	"log"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 
 	"github.com/yitech/candles/adapter"
+	"github.com/yitech/candles/metrics"
 	"github.com/yitech/candles/model/candle"
 )
 
-const wsEndpoint = "wss://ws.okx.com:8443/ws/v5/public"
+// closeGrace bounds how long a cancelled session waits for the exchange to
+// acknowledge its close frame.
+const closeGrace = time.Second
 
 // token implements adapter.Token for a single OKX kline subscription.
 type token struct {
@@ -25,22 +29,35 @@ func (t *token) Unsubscribe() { t.cancel() }
 
 // subscribeKline opens an OKX WebSocket candle stream for instID/bar,
 // invoking handler for every update. It reconnects automatically on error.
-func subscribeKline(ctx context.Context, instID, bar string, handler adapter.CandleHandler) (adapter.Token, error) {
+func subscribeKline(ctx context.Context, wg *sync.WaitGroup, subs *adapter.SubSet, wsEndpoint, instID, bar string, handler adapter.CandleHandler) (adapter.Token, error) {
 	ctx, cancel := context.WithCancel(ctx)
 
+	market := instID + ":" + bar
+	backoffGauge := metrics.Backoff.WithLabelValues("okx", market)
+	st := adapter.NewSubState(instID, bar)
+	subs.Add(st)
+
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
+		defer subs.Remove(st)
+		defer backoffGauge.Set(0)
 		backoff := time.Second
 		for {
 			if ctx.Err() != nil {
 				return
 			}
-			if err := connectAndRead(ctx, instID, bar, handler); err != nil && ctx.Err() == nil {
+			if err := connectAndRead(ctx, st, wsEndpoint, instID, bar, handler); err != nil && ctx.Err() == nil {
+				metrics.Reconnects.WithLabelValues("okx", market).Inc()
+				st.Reconnected()
 				log.Printf("okx ws [%s/%s]: %v — reconnecting in %v", instID, bar, err, backoff)
+				backoffGauge.Set(backoff.Seconds())
 				select {
 				case <-time.After(backoff):
 				case <-ctx.Done():
 					return
 				}
+				backoffGauge.Set(0)
 				if backoff < 30*time.Second {
 					backoff *= 2
 				}
@@ -54,19 +71,40 @@ func subscribeKline(ctx context.Context, instID, bar string, handler adapter.Can
 }
 
 // connectAndRead maintains a single OKX WebSocket session.
-func connectAndRead(ctx context.Context, instID, bar string, handler adapter.CandleHandler) error {
+func connectAndRead(ctx context.Context, st *adapter.SubState, wsEndpoint, instID, bar string, handler adapter.CandleHandler) error {
 	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsEndpoint, nil)
 	if err != nil {
 		return fmt.Errorf("dial: %w", err)
 	}
 	defer conn.Close()
 
-	// Close connection on context cancellation.
+	market := instID + ":" + bar
+	connected := metrics.Connected.WithLabelValues("okx", market)
+	connected.Set(1)
+	defer connected.Set(0)
+	st.SetConnected(true)
+	defer st.SetConnected(false)
+	received := metrics.CandlesReceived.WithLabelValues("okx", market)
+	parseErrors := metrics.ParseErrors.WithLabelValues("okx", market)
+
+	// On cancellation send a close frame and give the exchange closeGrace
+	// to answer it (ending the read loop) before tearing the socket down.
+	done := make(chan struct{})
+	defer close(done)
 	go func() {
-		<-ctx.Done()
-		conn.WriteMessage(websocket.CloseMessage,
-			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-		conn.Close()
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+		}
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+			time.Now().Add(closeGrace))
+		select {
+		case <-done:
+		case <-time.After(closeGrace):
+			conn.Close()
+		}
 	}()
 
 	// OKX channel name: "candle" + bar (e.g. "candle1m", "candle4H").
@@ -102,9 +140,12 @@ func connectAndRead(ctx context.Context, instID, bar string, handler adapter.Can
 		candles, err := parseWsMessage(instID, bar, msg)
 		if err != nil {
 			log.Printf("okx ws [%s/%s]: parse error: %v", instID, bar, err)
+			parseErrors.Inc()
 			continue
 		}
 		for _, c := range candles {
+			received.Inc()
+			st.Touch()
 			handler(c)
 		}
 	}
@@ -0,0 +1,86 @@
+package adapter
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SubscriptionStatus is a point-in-time view of one live subscription.
+type SubscriptionStatus struct {
+	Symbol     string
+	Interval   string
+	Connected  bool      // WebSocket session currently established
+	LastUpdate time.Time // last candle received; zero if none yet
+	Reconnects uint64    // sessions that failed and were retried
+}
+
+// SubState tracks the connection state of one subscription. Adapters update
+// it from their read loop; it is safe for concurrent use.
+type SubState struct {
+	symbol     string
+	interval   string
+	connected  atomic.Bool
+	lastUpdate atomic.Int64 // Unix ms
+	reconnects atomic.Uint64
+}
+
+func NewSubState(symbol, interval string) *SubState {
+	return &SubState{symbol: symbol, interval: interval}
+}
+
+// SetConnected records whether the WebSocket session is established.
+func (s *SubState) SetConnected(v bool) { s.connected.Store(v) }
+
+// Touch records that a candle was just received.
+func (s *SubState) Touch() { s.lastUpdate.Store(time.Now().UnixMilli()) }
+
+// Reconnected counts a failed session that will be retried.
+func (s *SubState) Reconnected() { s.reconnects.Add(1) }
+
+// Status returns a snapshot of s.
+func (s *SubState) Status() SubscriptionStatus {
+	st := SubscriptionStatus{
+		Symbol:     s.symbol,
+		Interval:   s.interval,
+		Connected:  s.connected.Load(),
+		Reconnects: s.reconnects.Load(),
+	}
+	if ms := s.lastUpdate.Load(); ms != 0 {
+		st.LastUpdate = time.UnixMilli(ms)
+	}
+	return st
+}
+
+// SubSet is the set of an adapter's live subscriptions. The zero value is
+// ready to use.
+type SubSet struct {
+	mu   sync.Mutex
+	subs map[*SubState]struct{}
+}
+
+func (s *SubSet) Add(st *SubState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subs == nil {
+		s.subs = make(map[*SubState]struct{})
+	}
+	s.subs[st] = struct{}{}
+}
+
+func (s *SubSet) Remove(st *SubState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subs, st)
+}
+
+// Status returns a snapshot of every subscription in the set.
+func (s *SubSet) Status() []SubscriptionStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]SubscriptionStatus, 0, len(s.subs))
+	for st := range s.subs {
+		out = append(out, st.Status())
+	}
+	return out
+}
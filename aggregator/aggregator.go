@@ -1,14 +1,22 @@
 package aggregator
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"log"
+	"math"
 	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/yitech/candles/adapter"
+	"github.com/yitech/candles/metrics"
 	"github.com/yitech/candles/model/candle"
+	"github.com/yitech/candles/store"
+	"github.com/yitech/candles/wal"
 )
 
 // MaxRequestLimit is the target buffer size after a resize.
@@ -19,23 +27,111 @@ const MaxRequestLimit = 365
 // single aggregated stream per "symbol:interval" key.
 //
 // Closed semantics: a period is marked IsClosed only when every exchange has
-// confirmed it.  If exchange A starts the next period before exchange B has
-// closed the current one, the current period is force-closed immediately.
-// Late-arriving candles for an already-finalized period are dropped.
+// confirmed it (or the quorum configured for the symbol in Config.Markets).
+// If exchange A starts the next period before exchange B has closed the
+// current one, the current period is force-closed immediately.
+// Late-arriving candles for an already-finalized period, or for one older
+// than a period already seen, are dropped, or merged into a revision of it
+// within Config.LateWindow; see late.go.
+//
+// Every published candle carries a Seq that is strictly increasing per key.
+// Sequences start at the key's creation time in Unix nanoseconds, so they
+// also keep increasing across server restarts.
+//
+// With a Store configured, every finalized period is written to it (the
+// merged candle plus each exchange's contribution) and a key's history
+// buffer is seeded from it when the key is first used.
+//
+// With Config.BaseInterval set, keys of longer intervals are resampled from
+// the base key instead of subscribing on the exchanges; see resample.go.
 type Aggregator struct {
 	adapters []adapter.Adapter
-	numEx    int
 	maxLimit int
+	queueLen int // per subscriber
+
+	// Per-symbol exchanges, quorum and weights; symbols not in markets use
+	// defaultMarket.
+	markets       map[string]*market
+	defaultMarket *market
 
 	mu     sync.Mutex
 	states map[string]*symState
+
+	// Asynchronous store writes; nil without a store.
+	store      store.Store
+	writes     chan []candle.Candle
+	writeMu    sync.RWMutex // guards writesDone against sends
+	writesDone bool
+	writerExit chan struct{}
+
+	// Write-ahead log of accepted updates; nil without one.  Records are
+	// appended by walWriter in the order they were queued; checkpoints
+	// start after Recover.
+	wal          *wal.Log
+	walRecs      chan walRecord
+	walMu        sync.RWMutex // guards walDone against sends
+	walDone      bool
+	walExit      chan struct{}
+	walErrMu     sync.Mutex
+	walErr       error // of the last append
+	ckptInterval time.Duration
+	ckptStop     chan struct{}
+	ckptDone     chan struct{}
+
+	// Keys of longer intervals are resampled from this one; empty if every
+	// key is fed by the exchanges.
+	baseInterval string
+	baseDur      time.Duration
+
+	gapFill    bool
+	lateWindow time.Duration
+
+	now func() time.Time
+}
+
+// Errors returned by SubscribeWith when ResumeAfter cannot be honoured.
+var (
+	ErrResumeGap   = errors.New("resume point is older than the history buffer")
+	ErrResumeAhead = errors.New("resume point is ahead of the stream")
+)
+
+// SubscribeOptions tunes a subscription made with SubscribeWith.
+type SubscribeOptions struct {
+	// ResumeAfter, when non-zero, is the Seq of the last candle the caller
+	// received. Closed candles published after it are replayed from the
+	// history buffer before any live update.
+	ResumeAfter uint64
+
+	// History, when positive and ResumeAfter is zero, replays up to that
+	// many of the most recent closed candles before any live update.
+	History int
+
+	// Seed, when positive, passes OnSeed up to that many of the closed
+	// candles preceding the first one delivered (the first replayed
+	// candle, or the live feed without a replay), oldest first, so the
+	// caller can prime state such as indicators.  OnSeed runs before
+	// handler sees any candle; it is not called if Seed is not positive.
+	Seed   int
+	OnSeed func([]candle.Candle)
 }
 
 // symState holds runtime data for one "symbol:interval" key.
 type symState struct {
+	key      string // "symbol:interval"
+	symbol   string
+	interval string
 	mu       sync.Mutex
 	setup    bool
 	setupErr error
+	// requested is set once the key is subscribed to or warmed, as opposed
+	// to running only as the base of a resampled key.
+	requested bool
+
+	// seq is the last sequence number assigned; floor is the Seq of the
+	// newest candle trimmed from history (resume tokens below it cannot be
+	// served).
+	seq   uint64
+	floor uint64
 
 	// Exchange-level subscription tokens (for cleanup).
 	tokens []adapter.Token
@@ -43,22 +139,30 @@ type symState struct {
 	// Rolling history of finalized candles.
 	candles []candle.Candle
 
-	// In-flight periods, keyed by openTime.
-	pending map[int64]*pendingCandle
+	// core merges and finalizes the key's periods; see core.go.
+	core *core
 
-	// openTimes that have been finalized (normally or force-closed).
-	finalized map[int64]struct{}
+	// Time of the last exchange update accepted for this key.
+	lastUpdate time.Time
 
-	// Registered downstream handlers.
-	handlers map[uint64]adapter.CandleHandler
-	nextID   uint64
-}
+	// Registered subscribers, each with its own queue; see dispatch.go.
+	subs    []*subscriber[candle.Candle]
+	divSubs []*subscriber[Divergence] // see divergence.go
+	nextID  uint64
+
+	// divAlerted is the OpenTime of the last period whose divergence
+	// crossed a threshold.
+	divAlerted int64
+
+	// out is reused for the candles one update publishes.
+	out []candle.Candle
 
-// pendingCandle tracks the merged state of one time period across all exchanges.
-type pendingCandle struct {
-	agg         candle.Candle
-	perExchange map[string]*candle.Candle
-	closedBy    map[string]struct{}
+	// rs is set on keys resampled from the base interval; their core is
+	// fed from the base key's candles instead of the exchanges.
+	rs *resampler
+
+	// market is the symbol's exchanges, quorum and weights.
+	market *market
 }
 
 // aggregatorToken cancels a single handler registration.
@@ -67,141 +171,653 @@ type aggregatorToken struct {
 	state *symState
 }
 
+// Unsubscribe stops deliveries to the handler.  A call already in progress
+// completes; no call starts after Unsubscribe returns, unless it is called
+// from the handler itself.
 func (t *aggregatorToken) Unsubscribe() {
-	t.state.mu.Lock()
-	delete(t.state.handlers, t.id)
-	t.state.mu.Unlock()
+	removeSubscriber(t.state, &t.state.subs, t.id)
+}
+
+// Config tunes an Aggregator. Zero fields take the defaults.
+type Config struct {
+	// HistoryDepth is the number of finalized candles kept per key
+	// (default MaxRequestLimit).
+	HistoryDepth int
+
+	// Store, if set, persists finalized candles and serves history beyond
+	// the in-memory buffer.  The caller closes it after Close.
+	Store store.Store
+
+	// WAL, if set, logs every accepted exchange update so that in-flight
+	// periods survive a restart; see Recover.  The caller closes it after
+	// Close.
+	WAL *wal.Log
+
+	// CheckpointInterval is how often the WAL is checkpointed and
+	// compacted (default DefaultCheckpointInterval).
+	CheckpointInterval time.Duration
+
+	// BaseInterval, if set, is the only interval subscribed on the
+	// exchanges: keys whose interval is a longer multiple of it are
+	// resampled from its aggregated candles.  See resample.go.
+	BaseInterval string
+
+	// GapFill synthesizes flat candles for periods no exchange reported,
+	// both live and in Backfill.  See gapfill.go.
+	GapFill bool
+
+	// LateWindow is how long after a period's close time exchange updates
+	// for it are still merged, publishing revisions of the closed candle.
+	// Zero drops every update for a finalized period.  See late.go.
+	LateWindow time.Duration
+
+	// SubscriberQueue is the capacity of each subscriber's queue (default
+	// DefaultSubscriberQueue); see dispatch.go.
+	SubscriberQueue int
+
+	// Markets overrides, by symbol, the exchanges taking part, the quorum
+	// that closes a period and the weights of the merge.
+	Markets map[string]MarketConfig
+
+	// Divergence holds the thresholds beyond which exchanges diverge; see
+	// divergence.go.  Markets may override it per symbol.
+	Divergence DivergenceThresholds
+
+	// Now is the clock that decides when periods are over and late-data
+	// windows end (default time.Now).
+	Now func() time.Time
 }
 
+// AggregatedExchange is the Exchange of merged candles.
+const AggregatedExchange = "aggregated"
+
+// writeQueue is the number of finalized periods buffered for the store.
+const writeQueue = 1024
+
 // New creates an Aggregator backed by the given exchange adapters.
 func New(adapters ...adapter.Adapter) *Aggregator {
-	return &Aggregator{
+	return NewWithConfig(Config{}, adapters...)
+}
+
+// NewWithConfig creates an Aggregator with a custom configuration.  It
+// panics if cfg.BaseInterval is not a valid interval, or cfg.Divergence or
+// an entry of cfg.Markets is invalid.
+func NewWithConfig(cfg Config, adapters ...adapter.Adapter) *Aggregator {
+	var baseDur time.Duration
+	if cfg.BaseInterval != "" {
+		var err error
+		if baseDur, err = candle.IntervalDuration(cfg.BaseInterval); err != nil {
+			panic("aggregator: base interval: " + err.Error())
+		}
+	}
+	markets := make(map[string]*market, len(cfg.Markets))
+	for symbol, mc := range cfg.Markets {
+		if mc.Divergence == nil {
+			mc.Divergence = &cfg.Divergence
+		}
+		m, err := newMarket(mc, adapters)
+		if err != nil {
+			panic(fmt.Sprintf("aggregator: market %s: %v", symbol, err))
+		}
+		markets[symbol] = m
+	}
+	defaultMarket, err := newMarket(MarketConfig{Divergence: &cfg.Divergence}, adapters)
+	if err != nil {
+		panic("aggregator: " + err.Error())
+	}
+	if cfg.HistoryDepth <= 0 {
+		cfg.HistoryDepth = MaxRequestLimit
+	}
+	if cfg.CheckpointInterval <= 0 {
+		cfg.CheckpointInterval = DefaultCheckpointInterval
+	}
+	if cfg.SubscriberQueue <= 0 {
+		cfg.SubscriberQueue = DefaultSubscriberQueue
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	a := &Aggregator{
 		adapters: adapters,
-		numEx:    len(adapters),
-		maxLimit: MaxRequestLimit,
+		maxLimit: cfg.HistoryDepth,
+		queueLen: cfg.SubscriberQueue,
 		states:   make(map[string]*symState),
+		store:    cfg.Store,
+
+		wal:          cfg.WAL,
+		ckptInterval: cfg.CheckpointInterval,
+
+		baseInterval: cfg.BaseInterval,
+		baseDur:      baseDur,
+
+		gapFill:    cfg.GapFill,
+		lateWindow: cfg.LateWindow,
+		now:        cfg.Now,
+
+		markets:       markets,
+		defaultMarket: defaultMarket,
+	}
+	if a.store != nil {
+		a.writes = make(chan []candle.Candle, writeQueue)
+		a.writerExit = make(chan struct{})
+		go a.writer()
 	}
+	if a.wal != nil {
+		a.walRecs = make(chan walRecord, walQueue)
+		a.walExit = make(chan struct{})
+		go a.walWriter()
+	}
+	return a
 }
 
 // Subscribe registers handler to receive aggregated candle updates for
 // symbol/interval.  Exchange subscriptions are created lazily on the first
 // call for each key.
 func (a *Aggregator) Subscribe(symbol, interval string, handler adapter.CandleHandler) (adapter.Token, error) {
+	return a.SubscribeWith(symbol, interval, SubscribeOptions{}, func(c *candle.Candle, _ int) { handler(c) })
+}
+
+// Handler receives the candles of a SubscribeWith subscription.  dropped is
+// the number of candles dropped from the subscriber's queue since the
+// previous call because the handler fell behind; see dispatch.go.
+type Handler func(c *candle.Candle, dropped int)
+
+// SubscribeWith is Subscribe with options.  Replayed candles are delivered to
+// handler before any live update.  handler runs on a goroutine of its own
+// and must not keep the candle after it returns.
+func (a *Aggregator) SubscribeWith(symbol, interval string, opts SubscribeOptions, handler Handler) (adapter.Token, error) {
+	return a.subscribe(symbol, interval, opts, handler, true)
+}
+
+// subscribe is SubscribeWith; requested is false for the subscription of a
+// resampled key to its base key.
+func (a *Aggregator) subscribe(symbol, interval string, opts SubscribeOptions, handler Handler, requested bool) (adapter.Token, error) {
 	key := symbol + ":" + interval
-	state := a.getOrCreateState(key)
+	state := a.getOrCreateState(symbol, interval)
 
 	// Register the handler before starting exchange connections so we
 	// never miss an early candle.
 	state.mu.Lock()
+	var replay []candle.Candle
+	switch {
+	case opts.ResumeAfter != 0:
+		var err error
+		if replay, err = state.since(opts.ResumeAfter); err != nil {
+			state.mu.Unlock()
+			return nil, fmt.Errorf("aggregator [%s]: %w", key, err)
+		}
+	case opts.History > 0:
+		var err error
+		if replay, err = a.history(state, opts.History); err != nil {
+			state.mu.Unlock()
+			return nil, fmt.Errorf("aggregator [%s]: %w", key, err)
+		}
+	}
+	var seed []candle.Candle
+	if opts.Seed > 0 && opts.OnSeed != nil {
+		before := int64(math.MaxInt64)
+		for _, c := range replay {
+			before = min(before, c.OpenTime)
+		}
+		var err error
+		if seed, err = a.historyBefore(state, before, opts.Seed); err != nil {
+			state.mu.Unlock()
+			return nil, fmt.Errorf("aggregator [%s]: %w", key, err)
+		}
+	}
 	id := state.nextID
 	state.nextID++
-	state.handlers[id] = handler
+	// The queue holds the replay on top of its usual capacity.
+	sub := newSubscriber(id, handler, inProgress, a.queueLen+len(replay), metrics.DispatchDropped.WithLabelValues(key))
+	sub.push(replay)
+	state.subs = append(state.subs, sub)
+	state.mu.Unlock()
+	if opts.Seed > 0 && opts.OnSeed != nil {
+		opts.OnSeed(seed)
+	}
+	go sub.run()
+
+	if err := a.setup(key, symbol, interval, state, requested); err != nil {
+		removeSubscriber(state, &state.subs, id)
+		return nil, err
+	}
+	return &aggregatorToken{id: id, state: state}, nil
+}
+
+// setup starts the feed of a key unless it is running, and returns the
+// error of the last attempt to start it.  requested marks the key as
+// requested; see symState.
+func (a *Aggregator) setup(key, symbol, interval string, state *symState, requested bool) error {
+	state.mu.Lock()
 	needsSetup := !state.setup
 	if needsSetup {
 		state.setup = true // claim the setup slot
 	}
+	state.requested = state.requested || requested
 	state.mu.Unlock()
 
 	if needsSetup {
-		tokens, err := a.startExchangeSubs(key, symbol, interval, state)
+		tokens, err := a.startSubs(key, symbol, interval, state)
 		state.mu.Lock()
 		if err != nil {
 			state.setup = false // allow a future retry
-			delete(state.handlers, id)
+			state.requested = false
 			state.setupErr = err
 		} else {
 			state.tokens = tokens
 			state.setupErr = nil
 		}
 		state.mu.Unlock()
+		return err
+	}
+	// Another goroutine may still be running the setup.
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return state.setupErr
+}
+
+// Warm seeds the history of symbol/interval with the last depth closed
+// periods and starts its exchange subscriptions, so the first subscriber
+// gets history immediately.  Periods already in the store are not fetched
+// again; only those after the newest stored one are backfilled from the
+// exchanges.  Exchange subscriptions stay open for the lifetime of the
+// Aggregator, so a warmed key stays hot whether or not anyone is subscribed.
+func (a *Aggregator) Warm(symbol, interval string, depth int) error {
+	key := symbol + ":" + interval
+	dur, err := candle.IntervalDuration(interval)
+	if err != nil {
+		return fmt.Errorf("aggregator warm [%s]: %w", key, err)
+	}
+	state := a.getOrCreateState(symbol, interval)
+
+	now := a.now()
+	from := now.Add(-time.Duration(depth) * dur)
+	state.mu.Lock()
+	var last int64 = -1
+	if n := len(state.candles); n > 0 {
+		last = state.candles[n-1].OpenTime
+		if next := time.UnixMilli(last).Add(dur); next.After(from) {
+			from = next
+		}
+	}
+	state.mu.Unlock()
+
+	// Backfill only if at least one whole period is missing.
+	if !from.Add(dur).After(now) {
+		groups, err := a.fetch(symbol, interval, from, now)
 		if err != nil {
-			return nil, err
+			return err
 		}
-	} else {
-		// Wait for setup that another goroutine may still be running.
+		var backfilled []emission
 		state.mu.Lock()
-		err := state.setupErr
-		state.mu.Unlock()
-		if err != nil {
-			state.mu.Lock()
-			delete(state.handlers, id)
-			state.mu.Unlock()
-			return nil, err
+		for _, t := range sortedTimes(groups) {
+			// Skip the period still in progress and anything already known.
+			if t+dur.Milliseconds() > now.UnixMilli() || t <= last {
+				continue
+			}
+			backfilled = state.core.backfilled(backfilled, merge(groups[t], state.market.weights), groups[t], from.UnixMilli())
 		}
+		a.emit(state, backfilled)
+		state.mu.Unlock()
 	}
 
-	return &aggregatorToken{id: id, state: state}, nil
+	state.mu.Lock()
+	if state.core.live == 0 {
+		// The backfill (or the store) covers everything up to the live
+		// feed, so a gap after it had no trades.
+		state.core.live = from.UnixMilli()
+	}
+	needsSetup := !state.setup
+	if needsSetup {
+		state.setup = true
+	}
+	state.requested = true
+	state.mu.Unlock()
+
+	if !needsSetup {
+		return nil
+	}
+	tokens, err := a.startSubs(key, symbol, interval, state)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if err != nil {
+		state.setup = false
+		state.requested = false
+		state.setupErr = err
+		return err
+	}
+	state.tokens = tokens
+	state.setupErr = nil
+	return nil
 }
 
 // Backfill fetches historical candles from every exchange, merges them by
 // openTime, and returns them in chronological order.
 func (a *Aggregator) Backfill(symbol, interval string, start, end time.Time) ([]*candle.Candle, error) {
-	// Collect candles per openTime from all exchanges.
-	groups := make(map[int64]map[string]*candle.Candle)
+	periods, err := a.BackfillPeriods(symbol, interval, start, end)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*candle.Candle, len(periods))
+	for i := range periods {
+		out[i] = &periods[i].Aggregated
+	}
+	return out, nil
+}
+
+// Period is one backfilled period: the merged candle and the exchange
+// candles it was merged from.
+type Period struct {
+	Aggregated candle.Candle
+	Exchanges  []candle.Candle // sorted by exchange
+}
+
+// BackfillPeriods is Backfill that also returns each exchange's candles.
+func (a *Aggregator) BackfillPeriods(symbol, interval string, start, end time.Time) ([]Period, error) {
+	groups, err := a.fetch(symbol, interval, start, end)
+	if err != nil {
+		return nil, err
+	}
+	times := sortedTimes(groups)
+	out := make([]Period, 0, len(times))
+	for _, t := range times {
+		agg := merge(groups[t], a.market(symbol).weights)
+		agg.IsClosed = true // historical candles are always closed
+		recs := periodRecords(agg, groups[t])
+		out = append(out, Period{Aggregated: recs[0], Exchanges: recs[1:]})
+	}
+	if a.gapFill {
+		out = fillPeriods(interval, out)
+	}
+	return out, nil
+}
+
+// Flush finalizes every pending period that is already over, either because
+// at least one exchange has closed it or because its close time has passed,
+// and publishes the closed candles.  It returns once every subscriber's
+// handler has been called with them.  Used on shutdown so that subscribers
+// receive the last closed candle before their streams end.
+func (a *Aggregator) Flush() {
+	now := a.now().UnixMilli()
+
+	a.mu.Lock()
+	states := make([]*symState, 0, len(a.states))
+	for _, state := range a.states {
+		states = append(states, state)
+	}
+	a.mu.Unlock()
+
+	for _, state := range states {
+		state.mu.Lock()
+		closed := state.core.flush(now)
+		if state.rs == nil {
+			for _, e := range closed {
+				if e.reason == "flush" {
+					a.logFlush(state, e.OpenTime, now)
+				}
+			}
+		}
+		a.diverge(state, closed)
+		publishAndUnlock(state, a.emit(state, closed))
+	}
+
+	// Wait until every subscriber has been handed what was published; base
+	// keys first, as their subscribers include the keys resampled from them.
+	for _, resampled := range []bool{false, true} {
+		for _, state := range states {
+			if (state.rs != nil) != resampled {
+				continue
+			}
+			state.mu.Lock()
+			subs := slices.Clone(state.subs)
+			state.mu.Unlock()
+			for _, s := range subs {
+				s.wait()
+			}
+		}
+	}
+}
+
+// MarketStatus is a point-in-time view of one "symbol:interval" key.
+type MarketStatus struct {
+	Symbol       string
+	Interval     string
+	Subscribers  int       // registered handlers
+	HistoryDepth int       // finalized candles in the history buffer
+	Pending      int       // periods not yet finalized
+	Seq          uint64    // last sequence number published
+	LastUpdate   time.Time // last exchange update accepted; zero if none
+	Running      bool      // the feed has been started, requested or not
+
+	// ResampledFrom is the base interval a resampled key is computed from;
+	// empty for keys fed by the exchanges.
+	ResampledFrom string
+
+	// Exchanges holds one entry per exchange taking part in the symbol, in
+	// adapter order.  An exchange without a live subscription for the key
+	// reports Connected=false.  A resampled key reports its base key's
+	// subscriptions.
+	Exchanges []ExchangeStatus
+}
 
+// ExchangeStatus is one exchange's subscription state for a market.
+type ExchangeStatus struct {
+	Exchange string
+	adapter.SubscriptionStatus
+}
+
+// Status returns the state of every key, sorted by symbol then interval.
+func (a *Aggregator) Status() []MarketStatus {
+	// Index adapter subscriptions by exchange and key once.
+	subs := make(map[string]map[string]adapter.SubscriptionStatus, len(a.adapters))
 	for _, ad := range a.adapters {
-		batch, err := ad.Backfill(symbol, interval, start, end)
-		if err != nil {
-			return nil, fmt.Errorf("aggregator backfill [%s:%s]: %w", symbol, interval, err)
+		subs[ad.Name()] = make(map[string]adapter.SubscriptionStatus)
+		for _, st := range ad.Status() {
+			subs[ad.Name()][st.Symbol+":"+st.Interval] = st
 		}
-		for _, c := range batch {
-			if groups[c.OpenTime] == nil {
-				groups[c.OpenTime] = make(map[string]*candle.Candle)
+	}
+
+	a.mu.Lock()
+	states := make([]*symState, 0, len(a.states))
+	for _, state := range a.states {
+		states = append(states, state)
+	}
+	a.mu.Unlock()
+
+	out := make([]MarketStatus, 0, len(states))
+	for _, state := range states {
+		state.mu.Lock()
+		ms := MarketStatus{
+			Symbol:       state.symbol,
+			Interval:     state.interval,
+			Subscribers:  len(state.subs),
+			HistoryDepth: len(state.candles),
+			Pending:      len(state.core.pending),
+			Seq:          state.seq,
+			LastUpdate:   state.lastUpdate,
+			Running:      state.setup,
+		}
+		state.mu.Unlock()
+
+		// A resampled key is fed by its base key's exchange sockets.
+		feed := state.key
+		if state.rs != nil {
+			ms.ResampledFrom = state.rs.source
+			feed = state.symbol + ":" + state.rs.source
+		}
+		for _, ad := range state.market.adapters {
+			es := ExchangeStatus{Exchange: ad.Name()}
+			if st, ok := subs[ad.Name()][feed]; ok {
+				es.SubscriptionStatus = st
 			}
-			groups[c.OpenTime][c.Exchange] = c
+			ms.Exchanges = append(ms.Exchanges, es)
 		}
+		out = append(out, ms)
 	}
+	slices.SortFunc(out, func(x, y MarketStatus) int {
+		if c := strings.Compare(x.Symbol, y.Symbol); c != 0 {
+			return c
+		}
+		return strings.Compare(x.Interval, y.Interval)
+	})
+	return out
+}
 
-	// Sort openTimes chronologically.
-	times := make([]int64, 0, len(groups))
-	for t := range groups {
-		times = append(times, t)
+// Running reports whether symbol/interval has been subscribed to or warmed
+// and its feed started (or is starting).  A running key keeps its exchange
+// subscriptions until Close; a key whose start failed is not running, and
+// neither is one that runs only as the base of a resampled key.
+func (a *Aggregator) Running(symbol, interval string) bool {
+	a.mu.Lock()
+	s, ok := a.states[symbol+":"+interval]
+	a.mu.Unlock()
+	if !ok {
+		return false
 	}
-	slices.Sort(times)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setup && s.requested
+}
 
-	out := make([]*candle.Candle, 0, len(times))
-	for _, t := range times {
-		agg := merge(groups[t])
-		agg.IsClosed = true // historical candles are always closed
-		out = append(out, &agg)
+// RunningMarkets returns the number of running keys, as reported by Running.
+func (a *Aggregator) RunningMarkets() int {
+	n := 0
+	for _, s := range a.snapshotStates() {
+		s.mu.Lock()
+		if s.setup && s.requested {
+			n++
+		}
+		s.mu.Unlock()
 	}
-	return out, nil
+	return n
 }
 
-// Close cancels all exchange subscriptions managed by this aggregator.
+// Close cancels all exchange subscriptions managed by this aggregator,
+// appends the records queued for the WAL, writes a final WAL checkpoint and
+// waits until every finalized period queued for the store is written.
 func (a *Aggregator) Close() {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-	for _, state := range a.states {
+	// Unsubscribe outside the locks: a resampled key's token unsubscribes
+	// from its base key.
+	var tokens []adapter.Token
+	for _, state := range a.snapshotStates() {
 		state.mu.Lock()
-		for _, tok := range state.tokens {
-			tok.Unsubscribe()
-		}
+		tokens = append(tokens, state.tokens...)
 		state.tokens = nil
 		state.mu.Unlock()
 	}
+	for _, tok := range tokens {
+		tok.Unsubscribe()
+	}
+
+	a.stopWAL()
+	a.stopCheckpoints()
+
+	if a.store == nil {
+		return
+	}
+	a.writeMu.Lock()
+	if !a.writesDone {
+		a.writesDone = true
+		close(a.writes)
+	}
+	a.writeMu.Unlock()
+	<-a.writerExit
 }
 
 // ── internal ─────────────────────────────────────────────────────────────────
 
-func (a *Aggregator) getOrCreateState(key string) *symState {
+func (a *Aggregator) getOrCreateState(symbol, interval string) *symState {
+	key := symbol + ":" + interval
 	a.mu.Lock()
-	defer a.mu.Unlock()
-	if s, ok := a.states[key]; ok {
+	s, ok := a.states[key]
+	a.mu.Unlock()
+	if ok {
 		return s
 	}
-	s := &symState{
-		pending:   make(map[int64]*pendingCandle),
-		finalized: make(map[int64]struct{}),
-		handlers:  make(map[uint64]adapter.CandleHandler),
+
+	// Build and seed the state outside the lock; if another goroutine
+	// created the key meanwhile, theirs wins.
+	s = newSymState(symbol, interval, a.now())
+	s.rs = a.newResampler(interval)
+	s.market = a.market(symbol)
+	s.core = newCore(key, interval, s.market, a.lateWindow, a.gapFill, a.maxLimit)
+	if s.rs != nil {
+		// The resampled candle of a period is its only input.
+		s.core.market = &market{quorum: 1}
+		s.core.records = false
+	}
+	a.seed(s)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if existing, ok := a.states[key]; ok {
+		return existing
 	}
 	a.states[key] = s
 	return s
 }
 
+func newSymState(symbol, interval string, now time.Time) *symState {
+	base := uint64(now.UnixNano())
+	return &symState{
+		key:      symbol + ":" + interval,
+		symbol:   symbol,
+		interval: interval,
+		seq:      base,
+		floor:    base,
+	}
+}
+
+// seed fills a new key's history buffer from the store.  Stored Seqs are
+// kept when they still form an increasing run below the new key's base, so
+// resume tokens issued before a restart stay valid; otherwise the seeded
+// candles are renumbered.
+func (a *Aggregator) seed(s *symState) {
+	if a.store == nil {
+		return
+	}
+	cs, err := a.store.Last(s.series(), math.MaxInt64, a.maxLimit)
+	if err != nil {
+		log.Printf("warn: aggregator [%s]: load history: %v", s.key, err)
+		return
+	}
+	if len(cs) == 0 {
+		return
+	}
+	keep := true
+	for i := range cs {
+		if cs[i].Seq == 0 || cs[i].Seq >= s.seq || (i > 0 && cs[i].Seq <= cs[i-1].Seq) {
+			keep = false
+			break
+		}
+	}
+	if keep {
+		s.floor = cs[0].Seq
+	} else {
+		for i := range cs {
+			cs[i].Seq = s.nextSeq()
+		}
+	}
+	s.candles = cs
+	s.core.seed(cs)
+}
+
+// startSubs starts the feed of a key: its base key for a resampled key,
+// the exchanges otherwise.
+func (a *Aggregator) startSubs(key, symbol, interval string, state *symState) ([]adapter.Token, error) {
+	if state.rs != nil {
+		tok, err := a.startResample(state)
+		if err != nil {
+			return nil, err
+		}
+		return []adapter.Token{tok}, nil
+	}
+	return a.startExchangeSubs(key, symbol, interval, state)
+}
+
 func (a *Aggregator) startExchangeSubs(key, symbol, interval string, state *symState) ([]adapter.Token, error) {
-	tokens := make([]adapter.Token, 0, len(a.adapters))
-	for _, ad := range a.adapters {
+	tokens := make([]adapter.Token, 0, len(state.market.adapters))
+	for _, ad := range state.market.adapters {
 		tok, err := ad.Subscribe(symbol, interval, func(c *candle.Candle) {
 			a.handleCandle(state, c)
 		})
@@ -216,79 +832,250 @@ func (a *Aggregator) startExchangeSubs(key, symbol, interval string, state *symS
 	return tokens, nil
 }
 
+// fetch backfills [start, end) from every exchange taking part and groups the candles by
+// openTime, then exchange.
+func (a *Aggregator) fetch(symbol, interval string, start, end time.Time) (map[int64]map[string]*candle.Candle, error) {
+	if rs := a.newResampler(interval); rs != nil {
+		return a.fetchResampled(symbol, interval, rs, start, end)
+	}
+	groups := make(map[int64]map[string]*candle.Candle)
+	for _, ad := range a.market(symbol).adapters {
+		batch, err := ad.Backfill(symbol, interval, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("aggregator backfill [%s:%s]: %w", symbol, interval, err)
+		}
+		for _, c := range batch {
+			if groups[c.OpenTime] == nil {
+				groups[c.OpenTime] = make(map[string]*candle.Candle)
+			}
+			groups[c.OpenTime][c.Exchange] = c
+		}
+	}
+	return groups, nil
+}
+
+// sortedTimes returns the openTimes of groups in chronological order.
+func sortedTimes(groups map[int64]map[string]*candle.Candle) []int64 {
+	times := make([]int64, 0, len(groups))
+	for t := range groups {
+		times = append(times, t)
+	}
+	slices.Sort(times)
+	return times
+}
+
 // handleCandle is called by every exchange adapter for every incoming candle.
 func (a *Aggregator) handleCandle(state *symState, c *candle.Candle) {
-	openTime := c.OpenTime
-	var toPublish []candle.Candle
-
 	state.mu.Lock()
-
-	// 1. Drop candles for already-finalized periods.
-	if _, done := state.finalized[openTime]; done {
+	now := a.now()
+	ems, ok := state.core.update(c, now.UnixMilli())
+	if !ok {
 		state.mu.Unlock()
+		metrics.LateCandles.WithLabelValues(c.Exchange, state.key).Inc()
 		return
 	}
+	state.lastUpdate = now
+	a.logUpdate(c, now.UnixMilli())
+	a.diverge(state, ems)
+	publishAndUnlock(state, a.emit(state, ems))
+}
 
-	// 2. Force-close any pending period that is older than the incoming one.
-	//    This handles the race where exchange A has moved to the next period
-	//    before exchange B confirmed the close of the current period.
-	for t, p := range state.pending {
-		if t < openTime {
-			p.agg.IsClosed = true
-			appendAndResize(state, p.agg, a.maxLimit)
-			toPublish = append(toPublish, p.agg)
-			delete(state.pending, t)
-			state.finalized[t] = struct{}{}
+// emit assigns Seqs to the candles the core emitted, records the closed
+// ones in history, the metrics and the store, and returns them for
+// publishing, in a buffer reused by the next call (called under lock).
+// Dropped periods are logged and left out.
+func (a *Aggregator) emit(state *symState, ems []emission) []candle.Candle {
+	out := state.out[:0]
+	for i := range ems {
+		e := &ems[i]
+		if e.dropped {
+			log.Printf("warn: aggregator [%s]: dropping pending period %d behind the watermark", state.key, e.OpenTime)
+			continue
+		}
+		c := e.Candle
+		c.Seq = state.nextSeq()
+		switch {
+		case !c.IsClosed:
+		case c.Revision > 0:
+			j, found := slices.BinarySearchFunc(state.candles, c.OpenTime, func(h candle.Candle, t int64) int {
+				return cmp.Compare(h.OpenTime, t)
+			})
+			if found {
+				state.candles[j] = c
+			}
+			metrics.Revisions.WithLabelValues(state.key).Inc()
+			a.persist(append([]candle.Candle{c}, e.exchanges...))
+		default:
+			appendAndResize(state, c, a.maxLimit)
+			switch {
+			case e.reason != "":
+				metrics.PeriodsClosed.WithLabelValues(state.key, e.reason).Inc()
+			case c.Synthetic:
+				metrics.SyntheticCandles.WithLabelValues(state.key).Inc()
+			}
+			a.persist(append([]candle.Candle{c}, e.exchanges...))
 		}
+		out = append(out, c)
 	}
+	state.out = out[:0]
+	return out
+}
 
-	// 3. Get or create the pending entry for this period.
-	p, ok := state.pending[openTime]
-	if !ok {
-		p = &pendingCandle{
-			perExchange: make(map[string]*candle.Candle),
-			closedBy:    make(map[string]struct{}),
+// persist queues cs for the store.  It blocks only while the write queue is
+// full, and drops cs once Close has begun.
+func (a *Aggregator) persist(cs []candle.Candle) {
+	if a.store == nil || len(cs) == 0 {
+		return
+	}
+	a.writeMu.RLock()
+	defer a.writeMu.RUnlock()
+	if !a.writesDone {
+		a.writes <- cs
+	}
+}
+
+// writer drains the write queue into the store, batching whatever has
+// accumulated into one transaction.
+func (a *Aggregator) writer() {
+	defer close(a.writerExit)
+	for cs := range a.writes {
+	batch:
+		for {
+			select {
+			case more, ok := <-a.writes:
+				if !ok {
+					break batch
+				}
+				cs = append(cs, more...)
+			default:
+				break batch
+			}
+		}
+		if err := a.store.Put(cs); err != nil {
+			log.Printf("warn: aggregator: %v", err)
 		}
-		state.pending[openTime] = p
 	}
+}
+
+// periodRecords returns the records stored for one finalized period: the
+// merged candle followed by each exchange's last candle, sorted by
+// exchange.  An exchange's candle may be partial if the period was
+// force-closed before it confirmed.
+func periodRecords(agg candle.Candle, perEx map[string]*candle.Candle) []candle.Candle {
+	return append([]candle.Candle{agg}, sortedCandles(perEx)...)
+}
 
-	// 4. Store the latest candle from this exchange and re-merge.
-	cp := *c
-	p.perExchange[c.Exchange] = &cp
-	if c.IsClosed {
-		p.closedBy[c.Exchange] = struct{}{}
+// sortedCandles returns copies of the candles of perEx, sorted by exchange.
+func sortedCandles(perEx map[string]*candle.Candle) []candle.Candle {
+	out := make([]candle.Candle, 0, len(perEx))
+	for _, c := range perEx {
+		out = append(out, *c)
 	}
-	p.agg = merge(p.perExchange)
+	slices.SortFunc(out, func(x, y candle.Candle) int { return strings.Compare(x.Exchange, y.Exchange) })
+	return out
+}
 
-	// 5. Finalize the period when all exchanges have confirmed the close.
-	if len(p.closedBy) == a.numEx {
-		p.agg.IsClosed = true
-		appendAndResize(state, p.agg, a.maxLimit)
-		delete(state.pending, openTime)
-		state.finalized[openTime] = struct{}{}
+// publishAndUnlock queues cs for every subscriber.  It must be called with
+// state.mu held and releases it; queueing under the lock keeps each
+// subscriber's candles in Seq order.
+func publishAndUnlock(state *symState, cs []candle.Candle) {
+	if len(cs) > 0 {
+		for _, s := range state.subs {
+			s.push(cs)
+		}
 	}
+	state.mu.Unlock()
+}
 
-	toPublish = append(toPublish, p.agg)
+// inProgress reports whether c is an update of a period still open, which
+// a subscriber's queue drops first.
+func inProgress(c *candle.Candle) bool { return !c.IsClosed }
 
-	// Snapshot handlers before releasing the lock to avoid holding it
-	// while calling user code.
-	hs := snapshotHandlers(state)
-	state.mu.Unlock()
+// nextSeq assigns the next sequence number for the key (called under lock).
+func (s *symState) nextSeq() uint64 {
+	s.seq++
+	return s.seq
+}
 
-	for _, c := range toPublish {
-		for _, h := range hs {
-			h(&c)
+// History returns up to n of the most recent closed candles of
+// symbol/interval with OpenTime < before, oldest first.  A running key
+// serves them from its history buffer, then the store; any other key from
+// the store alone, without starting it.
+func (a *Aggregator) History(symbol, interval string, before int64, n int) ([]candle.Candle, error) {
+	key := symbol + ":" + interval
+	a.mu.Lock()
+	s, ok := a.states[key]
+	a.mu.Unlock()
+	if !ok {
+		if a.store == nil {
+			return nil, nil
+		}
+		cs, err := a.store.Last(store.Series{Exchange: AggregatedExchange, Symbol: symbol, Interval: interval}, before, n)
+		if err != nil {
+			return nil, fmt.Errorf("aggregator [%s]: %w", key, err)
 		}
+		return cs, nil
 	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cs, err := a.historyBefore(s, before, n)
+	if err != nil {
+		return nil, fmt.Errorf("aggregator [%s]: %w", key, err)
+	}
+	return cs, nil
+}
+
+// history returns up to n of the most recent closed candles, reading those
+// older than the buffer from the store (called under lock).
+func (a *Aggregator) history(s *symState, n int) ([]candle.Candle, error) {
+	return a.historyBefore(s, math.MaxInt64, n)
+}
+
+// historyBefore returns up to n of the most recent closed candles with
+// OpenTime < before, reading those older than the buffer from the store
+// (called under lock).
+func (a *Aggregator) historyBefore(s *symState, before int64, n int) ([]candle.Candle, error) {
+	end, _ := slices.BinarySearchFunc(s.candles, before, func(c candle.Candle, t int64) int {
+		return cmp.Compare(c.OpenTime, t)
+	})
+	have := min(n, end)
+	out := slices.Clone(s.candles[end-have : end])
+	if have == n || a.store == nil {
+		return out, nil
+	}
+	if len(s.candles) > 0 {
+		before = min(before, s.candles[0].OpenTime)
+	}
+	older, err := a.store.Last(s.series(), before, n-have)
+	if err != nil {
+		return nil, err
+	}
+	return append(older, out...), nil
+}
+
+// series is the store series of the key's merged candles.
+func (s *symState) series() store.Series {
+	return store.Series{Exchange: AggregatedExchange, Symbol: s.symbol, Interval: s.interval}
 }
 
-// snapshotHandlers returns a copy of the handler slice (called under lock).
-func snapshotHandlers(state *symState) []adapter.CandleHandler {
-	hs := make([]adapter.CandleHandler, 0, len(state.handlers))
-	for _, h := range state.handlers {
-		hs = append(hs, h)
+// since returns the closed candles in history published after seq, in Seq
+// order: a revision is published after candles of later periods
+// (called under lock).
+func (s *symState) since(seq uint64) ([]candle.Candle, error) {
+	if seq > s.seq {
+		return nil, ErrResumeAhead
+	}
+	if seq < s.floor {
+		return nil, ErrResumeGap
 	}
-	return hs
+	var out []candle.Candle
+	for _, c := range s.candles {
+		if c.Seq > seq {
+			out = append(out, c)
+		}
+	}
+	slices.SortFunc(out, func(x, y candle.Candle) int { return cmp.Compare(x.Seq, y.Seq) })
+	return out, nil
 }
 
 // appendAndResize appends c to the buffer and trims if it exceeds 2×limit.
@@ -297,28 +1084,38 @@ func appendAndResize(state *symState, c candle.Candle, limit int) {
 	if len(state.candles) > limit*2 {
 		// Keep the most recent `limit` candles; wait for the buffer to
 		// grow to 2×limit again before the next resize.
-		state.candles = state.candles[len(state.candles)-limit:]
+		cut := len(state.candles) - limit
+		for _, c := range state.candles[:cut] {
+			state.floor = max(state.floor, c.Seq) // revisions are out of order
+		}
+		state.candles = state.candles[cut:]
 	}
 }
 
 // merge combines per-exchange candles into one aggregated candle.
 //   - Exchange : "aggregated"
-//   - Open     : from the first exchange (all share the same period open)
+//   - Open     : from the first exchange by name, or weighted (see weigh)
 //   - High     : max across exchanges
 //   - Low      : min across exchanges
-//   - Close    : last update received (map iteration order is random;
-//                for determinism, callers that care should sort by exchange)
+//   - Close    : from the last exchange by name, or weighted
 //   - Volume   : sum across exchanges
 //   - IsClosed : set by caller (not by merge)
-func merge(perEx map[string]*candle.Candle) candle.Candle {
+func merge(perEx map[string]*candle.Candle, weights map[string]float64) candle.Candle {
 	var agg candle.Candle
 	var sumVol, maxH, minL float64
 	first := true
 
-	for _, c := range perEx {
+	var arr [8]string // no allocation for the usual handful of exchanges
+	names := arr[:0]
+	for ex := range perEx {
+		names = append(names, ex)
+	}
+	slices.Sort(names)
+	for _, ex := range names {
+		c := perEx[ex]
 		if first {
 			agg = *c
-			agg.Exchange = "aggregated"
+			agg.Exchange = AggregatedExchange
 			maxH, _ = strconv.ParseFloat(c.High, 64)
 			minL, _ = strconv.ParseFloat(c.Low, 64)
 			sumVol, _ = strconv.ParseFloat(c.Volume, 64)
@@ -339,6 +1136,9 @@ func merge(perEx map[string]*candle.Candle) candle.Candle {
 		agg.Close = c.Close
 	}
 
+	if weights != nil {
+		weigh(&agg, perEx, weights)
+	}
 	agg.Volume = strconv.FormatFloat(sumVol, 'f', -1, 64)
 	agg.IsClosed = false // caller decides
 	return agg
@@ -0,0 +1,271 @@
+package aggregator
+
+import (
+	"slices"
+	"time"
+
+	"github.com/yitech/candles/model/candle"
+)
+
+// core is the merge/finalize state machine of one key.  It takes exchange
+// updates, each with the time it is applied at, and returns the aggregated
+// candles to emit; it holds no lock, reads no clock and touches neither
+// subscribers nor the store, so the same events always give the same
+// candles.  symState wraps it with Seqs, history, subscribers and the store.
+//
+// A resampled key runs the same core fed with the resampled candle of each
+// period as its single exchange.
+type core struct {
+	key        string // for log messages
+	dur        int64  // interval length in ms; 0 if the interval is invalid
+	market     *market
+	lateWindow int64 // ms
+	gapFill    bool
+	fillLimit  int  // most synthetic candles per gap
+	records    bool // emissions carry the exchange candles to store
+
+	// In-flight periods, keyed by openTime.
+	pending map[int64]*pendingCandle
+
+	// openTimes that have been finalized (normally or force-closed), at or
+	// after watermark; every period before it is finalized too.  See
+	// watermark.go.
+	finalized map[int64]struct{}
+	watermark int64
+	newest    int64 // OpenTime of the newest period pending or finalized
+
+	// Finalized periods still within the late-data window, keyed by
+	// openTime; agg is the latest revision.  See late.go.
+	late map[int64]*pendingCandle
+
+	// last is the newest finalized candle (valid if hasLast); gap filling
+	// continues from it.
+	last    candle.Candle
+	hasLast bool
+
+	// live is the OpenTime of the first period received live; gap filling
+	// stops there.
+	live int64
+
+	out []emission // reused across updates
+}
+
+// pendingCandle tracks the merged state of one time period across all exchanges.
+type pendingCandle struct {
+	agg         candle.Candle
+	perExchange map[string]*candle.Candle
+	closedBy    map[string]struct{}
+	seen        map[string]int64 // when each exchange last updated it, Unix ms
+}
+
+func newPendingCandle() *pendingCandle {
+	return &pendingCandle{
+		perExchange: make(map[string]*candle.Candle),
+		closedBy:    make(map[string]struct{}),
+		seen:        make(map[string]int64),
+	}
+}
+
+// set stores the latest candle of c.Exchange, received at now.
+func (p *pendingCandle) set(c *candle.Candle, now int64) {
+	if prev, ok := p.perExchange[c.Exchange]; ok {
+		*prev = *c
+	} else {
+		cp := *c
+		p.perExchange[c.Exchange] = &cp
+	}
+	if c.IsClosed {
+		p.closedBy[c.Exchange] = struct{}{}
+	}
+	p.seen[c.Exchange] = now
+}
+
+// emission is one candle the core emits, without a Seq.
+type emission struct {
+	candle.Candle
+
+	// reason is why a period was finalized ("consensus", "forced" or
+	// "flush"); empty for open updates, revisions, synthetic and backfilled
+	// candles.
+	reason string
+
+	// exchanges are the exchange candles stored with a finalized period or
+	// revision, sorted by exchange; nil for synthetic candles and for a
+	// resampled key's own periods.
+	exchanges []candle.Candle
+
+	// p is the period the candle was merged from; nil for synthetic and
+	// backfilled candles.  Only valid until the next call into the core.
+	p *pendingCandle
+
+	// dropped marks a pending period the watermark passed, which is
+	// discarded rather than published; see watermark.go.
+	dropped bool
+}
+
+func newCore(key, interval string, m *market, lateWindow time.Duration, gapFill bool, fillLimit int) *core {
+	dur, _ := candle.IntervalDuration(interval)
+	return &core{
+		key:        key,
+		dur:        dur.Milliseconds(),
+		market:     m,
+		lateWindow: lateWindow.Milliseconds(),
+		gapFill:    gapFill,
+		fillLimit:  fillLimit,
+		records:    true,
+		pending:    make(map[int64]*pendingCandle),
+		finalized:  make(map[int64]struct{}),
+		late:       make(map[int64]*pendingCandle),
+	}
+}
+
+// update merges an exchange update applied at now (Unix ms) and returns the
+// candles to emit, in a buffer reused by the next call.  ok is false if c
+// was dropped because its period is already finalized.
+func (k *core) update(c *candle.Candle, now int64) (out []emission, ok bool) {
+	openTime := c.OpenTime
+	out = k.out[:0]
+	defer func() { k.out = out[:0] }()
+
+	// 1. Drop candles for already-finalized periods, or revise them within
+	//    the late-data window.
+	if k.isFinal(openTime) {
+		return k.lateUpdate(out, c, now)
+	}
+
+	// 2. Force-close any pending period that is older than the incoming one.
+	//    This handles the race where exchange A has moved to the next period
+	//    before exchange B confirmed the close of the current period.
+	var stale []int64
+	for t := range k.pending {
+		if t < openTime {
+			stale = append(stale, t)
+		}
+	}
+	slices.Sort(stale)
+	for _, t := range stale {
+		out = k.finalize(out, t, "forced", now)
+	}
+
+	// 3. Get or create the pending entry for this period.
+	if k.live == 0 {
+		k.live = openTime
+	}
+	k.newest = openTime
+	p, ok := k.pending[openTime]
+	if !ok {
+		p = newPendingCandle()
+		k.pending[openTime] = p
+	}
+
+	// 4. Store the latest candle from this exchange and re-merge.
+	p.set(c, now)
+	p.agg = merge(p.perExchange, k.market.weights)
+
+	// 5. Finalize the period once the quorum (by default every exchange
+	//    taking part) has confirmed the close.
+	if len(p.closedBy) >= k.market.quorum {
+		return k.finalize(out, openTime, "consensus", now), true
+	}
+	return append(out, emission{Candle: p.agg, p: p}), true
+}
+
+// flush finalizes every pending period that is over at now, because an
+// exchange has closed it or its close time has passed, and returns the
+// candles to emit.
+func (k *core) flush(now int64) []emission {
+	var over []int64
+	for t, p := range k.pending {
+		if len(p.closedBy) > 0 || p.agg.CloseTime < now {
+			over = append(over, t)
+		}
+	}
+	slices.Sort(over)
+	var out []emission
+	for _, t := range over {
+		out = k.finalize(out, t, "flush", now)
+	}
+	return out
+}
+
+// closePending finalizes the period at openTime, if pending, and returns
+// the candles to emit.
+func (k *core) closePending(openTime int64, reason string, now int64) []emission {
+	if _, ok := k.pending[openTime]; !ok {
+		return nil
+	}
+	return k.finalize(nil, openTime, reason, now)
+}
+
+// finalize closes the pending period at openTime and appends to out any
+// synthetic candles filling the gap before it, then the closed candle.
+// reason is recorded in the emission.
+func (k *core) finalize(out []emission, openTime int64, reason string, now int64) []emission {
+	p := k.pending[openTime]
+	delete(k.pending, openTime)
+	out = k.fill(out, openTime, k.live)
+	p.agg.IsClosed = true
+	out = k.markFinal(out, openTime)
+	k.keepLate(openTime, p, now)
+	k.setLast(p.agg)
+	return append(out, emission{Candle: p.agg, reason: reason, exchanges: k.exchangeRecords(p.perExchange), p: p})
+}
+
+// backfilled records a closed period fetched from the exchanges, unless it
+// is already finalized, and appends to out the candles to store: any
+// synthetic candles filling the gap before it, from since on, then agg.
+func (k *core) backfilled(out []emission, agg candle.Candle, perEx map[string]*candle.Candle, since int64) []emission {
+	if k.isFinal(agg.OpenTime) {
+		return out
+	}
+	out = k.fill(out, agg.OpenTime, since)
+	agg.IsClosed = true
+	out = k.markFinal(out, agg.OpenTime)
+	k.setLast(agg)
+	return append(out, emission{Candle: agg, exchanges: sortedCandles(perEx)})
+}
+
+// seed marks cs, closed candles loaded from the store in chronological
+// order, as finalized.
+func (k *core) seed(cs []candle.Candle) {
+	for _, c := range cs {
+		k.markFinal(nil, c.OpenTime) // nothing is pending yet
+	}
+	if len(cs) > 0 {
+		k.setLast(cs[len(cs)-1])
+	}
+}
+
+// restore reinstates a pending period from a checkpoint unless it is
+// already finalized.
+func (k *core) restore(perEx []candle.Candle, closedBy []string) {
+	if len(perEx) == 0 || k.isFinal(perEx[0].OpenTime) {
+		return
+	}
+	p := newPendingCandle()
+	for i := range perEx {
+		p.perExchange[perEx[i].Exchange] = &perEx[i]
+	}
+	for _, ex := range closedBy {
+		p.closedBy[ex] = struct{}{}
+	}
+	p.agg = merge(p.perExchange, k.market.weights)
+	k.pending[perEx[0].OpenTime] = p
+	k.newest = max(k.newest, perEx[0].OpenTime)
+}
+
+// setLast makes c the newest finalized candle unless a newer one is known.
+func (k *core) setLast(c candle.Candle) {
+	if !k.hasLast || c.OpenTime >= k.last.OpenTime {
+		k.last, k.hasLast = c, true
+	}
+}
+
+// exchangeRecords returns copies of perEx sorted by exchange, or nil if the
+// core stores no exchange candles.
+func (k *core) exchangeRecords(perEx map[string]*candle.Candle) []candle.Candle {
+	if !k.records {
+		return nil
+	}
+	return sortedCandles(perEx)
+}
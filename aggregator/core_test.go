@@ -0,0 +1,351 @@
+package aggregator
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/yitech/candles/model/candle"
+)
+
+const (
+	testBase = int64(1_700_000_040_000) // a minute boundary
+	testDur  = int64(60_000)            // 1m
+)
+
+// period returns the OpenTime of the i-th period after testBase.
+func period(i int) int64 { return testBase + int64(i)*testDur }
+
+// bar returns an exchange candle of the i-th period.
+func bar(ex string, i int, o, h, l, c float64, closed bool) *candle.Candle {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	return &candle.Candle{
+		Exchange:  ex,
+		Symbol:    "BTCUSDT",
+		Interval:  "1m",
+		OpenTime:  period(i),
+		Open:      f(o),
+		High:      f(h),
+		Low:       f(l),
+		Close:     f(c),
+		Volume:    "1",
+		CloseTime: period(i) + testDur - 1,
+		IsClosed:  closed,
+	}
+}
+
+func newTestCore(quorum int, lateWindow time.Duration, gapFill bool) *core {
+	return newCore("BTCUSDT:1m", "1m", &market{quorum: quorum}, lateWindow, gapFill, MaxRequestLimit)
+}
+
+// describe summarizes emissions as "<kind> <period>", where kind is the
+// finalize reason, "open", "synthetic", "rev<n>" or "dropped".
+func describe(ems []emission) []string {
+	out := []string{}
+	for _, e := range ems {
+		i := (e.OpenTime - testBase) / testDur
+		switch {
+		case e.dropped:
+			out = append(out, fmt.Sprintf("dropped %d", i))
+		case e.Revision > 0:
+			out = append(out, fmt.Sprintf("rev%d %d", e.Revision, i))
+		case e.Synthetic:
+			out = append(out, fmt.Sprintf("synthetic %d", i))
+		case e.IsClosed:
+			out = append(out, fmt.Sprintf("%s %d", e.reason, i))
+		default:
+			out = append(out, fmt.Sprintf("open %d", i))
+		}
+	}
+	return out
+}
+
+// step is one event fed to a core: an update if c is set, otherwise a flush,
+// or a closePending of period close if reason is set.
+type step struct {
+	c      *candle.Candle
+	reason string
+	close  int
+	now    int64
+	want   []string
+}
+
+func TestCore(t *testing.T) {
+	tests := []struct {
+		name       string
+		quorum     int
+		lateWindow time.Duration
+		gapFill    bool
+		steps      []step
+	}{
+		{
+			name:   "consensus",
+			quorum: 2,
+			steps: []step{
+				{c: bar("a", 0, 10, 12, 9, 11, false), now: period(0) + 1000, want: []string{"open 0"}},
+				{c: bar("b", 0, 10, 13, 8, 12, true), now: period(1), want: []string{"open 0"}},
+				{c: bar("a", 0, 10, 12, 9, 11, true), now: period(1), want: []string{"consensus 0"}},
+				{c: bar("a", 0, 10, 12, 9, 11, true), now: period(1) + 1, want: []string{}},
+			},
+		},
+		{
+			name:   "quorum",
+			quorum: 1,
+			steps: []step{
+				{c: bar("a", 0, 10, 12, 9, 11, true), now: period(1), want: []string{"consensus 0"}},
+				{c: bar("b", 0, 10, 13, 8, 12, true), now: period(1) + 1, want: []string{}},
+			},
+		},
+		{
+			name:   "force-close",
+			quorum: 2,
+			steps: []step{
+				{c: bar("a", 0, 10, 12, 9, 11, false), now: period(0) + 1000, want: []string{"open 0"}},
+				{c: bar("b", 0, 10, 13, 8, 12, false), now: period(0) + 2000, want: []string{"open 0"}},
+				{c: bar("a", 1, 11, 11, 11, 11, false), now: period(1) + 100, want: []string{"forced 0", "open 1"}},
+				{c: bar("b", 0, 10, 13, 8, 12, true), now: period(1) + 200, want: []string{}},
+			},
+		},
+		{
+			name:       "late revision",
+			quorum:     2,
+			lateWindow: 30 * time.Second,
+			steps: []step{
+				{c: bar("a", 0, 10, 12, 9, 11, true), now: period(1), want: []string{"open 0"}},
+				{c: bar("a", 1, 11, 11, 11, 11, false), now: period(1) + 1000, want: []string{"forced 0", "open 1"}},
+				{c: bar("b", 0, 10, 14, 8, 13, true), now: period(1) + 5000, want: []string{"rev1 0"}},
+				{c: bar("b", 0, 10, 14, 8, 13, true), now: period(1) + 6000, want: []string{}},
+				{c: bar("b", 0, 10, 15, 8, 14, true), now: period(1) + 40_000, want: []string{}},
+			},
+		},
+		{
+			name:   "late without window",
+			quorum: 2,
+			steps: []step{
+				{c: bar("a", 0, 10, 12, 9, 11, true), now: period(1), want: []string{"open 0"}},
+				{c: bar("a", 1, 11, 11, 11, 11, false), now: period(1) + 1000, want: []string{"forced 0", "open 1"}},
+				{c: bar("b", 0, 10, 14, 8, 13, true), now: period(1) + 5000, want: []string{}},
+			},
+		},
+		{
+			name:    "gap fill",
+			quorum:  1,
+			gapFill: true,
+			steps: []step{
+				{c: bar("a", 0, 10, 12, 9, 11, true), now: period(1), want: []string{"consensus 0"}},
+				{c: bar("a", 3, 11, 12, 10, 12, true), now: period(4), want: []string{"synthetic 1", "synthetic 2", "consensus 3"}},
+				{c: bar("a", 2, 11, 12, 10, 12, true), now: period(4) + 1, want: []string{}},
+			},
+		},
+		{
+			name:   "gap without filling",
+			quorum: 1,
+			steps: []step{
+				{c: bar("a", 0, 10, 12, 9, 11, true), now: period(1), want: []string{"consensus 0"}},
+				{c: bar("a", 3, 11, 12, 10, 12, true), now: period(4), want: []string{"consensus 3"}},
+			},
+		},
+		{
+			name:   "flush",
+			quorum: 2,
+			steps: []step{
+				{c: bar("a", 0, 10, 12, 9, 11, false), now: period(0) + 1000, want: []string{"open 0"}},
+				{now: period(0) + 30_000, want: []string{}},
+				{now: period(1) + 1, want: []string{"flush 0"}},
+				{c: bar("b", 0, 10, 12, 9, 11, true), now: period(1) + 2, want: []string{}},
+			},
+		},
+		{
+			name:   "flush of an exchange close",
+			quorum: 2,
+			steps: []step{
+				{c: bar("a", 0, 10, 12, 9, 11, true), now: period(0) + 59_000, want: []string{"open 0"}},
+				{now: period(0) + 59_500, want: []string{"flush 0"}},
+			},
+		},
+		{
+			name:   "close pending",
+			quorum: 2,
+			steps: []step{
+				{c: bar("a", 0, 10, 12, 9, 11, false), now: period(0) + 1000, want: []string{"open 0"}},
+				{reason: "shutdown", close: 0, now: period(0) + 2000, want: []string{"shutdown 0"}},
+				{reason: "shutdown", close: 0, now: period(0) + 3000, want: []string{}},
+				{c: bar("a", 0, 10, 12, 9, 11, false), now: period(0) + 4000, want: []string{}},
+			},
+		},
+		{
+			name:   "older than newest",
+			quorum: 1,
+			steps: []step{
+				{c: bar("a", 1, 10, 12, 9, 11, false), now: period(1) + 1000, want: []string{"open 1"}},
+				{c: bar("b", 0, 10, 12, 9, 11, false), now: period(1) + 2000, want: []string{}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := newTestCore(tt.quorum, tt.lateWindow, tt.gapFill)
+			for i, s := range tt.steps {
+				var ems []emission
+				switch {
+				case s.c != nil:
+					var ok bool
+					ems, ok = k.update(s.c, s.now)
+					if !ok && len(ems) > 0 {
+						t.Fatalf("step %d: dropped update emitted %v", i, describe(ems))
+					}
+				case s.reason != "":
+					ems = k.closePending(period(s.close), s.reason, s.now)
+				default:
+					ems = k.flush(s.now)
+				}
+				if got := describe(ems); !slices.Equal(got, s.want) {
+					t.Fatalf("step %d: got %v, want %v", i, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCoreMerge(t *testing.T) {
+	k := newTestCore(2, 0, false)
+	k.update(bar("a", 0, 10, 12, 9, 11, false), period(0))
+	ems, _ := k.update(bar("b", 0, 10.5, 13, 8, 12, true), period(0))
+	got := ems[0].Candle
+	if got.Open != "10" || got.High != "13" || got.Low != "8" || got.Close != "12" || got.Volume != "2" {
+		t.Fatalf("merged %+v, want O=10 H=13 L=8 C=12 V=2", got)
+	}
+}
+
+// checker asserts the core's invariants over everything it emits:
+//
+//   - High >= max(Open, Close) and Low <= min(Open, Close),
+//   - periods close in OpenTime order,
+//   - every period closes exactly once,
+//   - nothing is emitted for a finalized period but its revisions, and
+//     those only within the late-data window.
+type checker struct {
+	t          *testing.T
+	lateWindow int64
+	closed     map[int64]bool
+	opened     map[int64]bool
+	lastClosed int64
+}
+
+func newChecker(t *testing.T, lateWindow time.Duration) *checker {
+	return &checker{t: t, lateWindow: lateWindow.Milliseconds(), closed: make(map[int64]bool), opened: make(map[int64]bool)}
+}
+
+func (ch *checker) check(ems []emission, now int64, what string) {
+	ch.t.Helper()
+	for _, e := range ems {
+		if e.dropped {
+			continue
+		}
+		o, _ := strconv.ParseFloat(e.Open, 64)
+		h, _ := strconv.ParseFloat(e.High, 64)
+		l, _ := strconv.ParseFloat(e.Low, 64)
+		c, _ := strconv.ParseFloat(e.Close, 64)
+		if h < max(o, c) || l > min(o, c) {
+			ch.t.Fatalf("%s: period %d has O=%v H=%v L=%v C=%v", what, e.OpenTime, o, h, l, c)
+		}
+		switch {
+		case e.Revision > 0:
+			if !ch.closed[e.OpenTime] || !e.IsClosed {
+				ch.t.Fatalf("%s: revision of period %d, which is not closed", what, e.OpenTime)
+			}
+			if ch.lateWindow == 0 || now >= e.CloseTime+ch.lateWindow {
+				ch.t.Fatalf("%s: revision of period %d outside the late-data window", what, e.OpenTime)
+			}
+		case e.IsClosed:
+			if ch.closed[e.OpenTime] {
+				ch.t.Fatalf("%s: period %d closed twice", what, e.OpenTime)
+			}
+			if e.OpenTime <= ch.lastClosed {
+				ch.t.Fatalf("%s: period %d closed after period %d", what, e.OpenTime, ch.lastClosed)
+			}
+			ch.closed[e.OpenTime] = true
+			ch.lastClosed = e.OpenTime
+		default:
+			if ch.closed[e.OpenTime] || e.OpenTime <= ch.lastClosed {
+				ch.t.Fatalf("%s: update of finalized period %d", what, e.OpenTime)
+			}
+			ch.opened[e.OpenTime] = true
+		}
+	}
+}
+
+// randomBar returns a well-formed exchange candle of the i-th period.
+func randomBar(rng *rand.Rand, ex string, i int, closed bool) *candle.Candle {
+	o := 100 + rng.Float64()*10
+	c := 100 + rng.Float64()*10
+	return bar(ex, i, o, max(o, c)+rng.Float64(), min(o, c)-rng.Float64(), c, closed)
+}
+
+// TestCoreInvariants drives cores with random event sequences: exchanges
+// that lag, skip periods and replay old ones, interleaved with flushes and
+// forced closes, under random quorums, weights, late-data windows and gap
+// filling.
+func TestCoreInvariants(t *testing.T) {
+	exchanges := []string{"binance", "bybit", "okx"}
+	for seed := range uint64(500) {
+		rng := rand.New(rand.NewPCG(seed, 43))
+		n := 1 + rng.IntN(len(exchanges))
+		m := &market{quorum: 1 + rng.IntN(n)}
+		if rng.IntN(2) == 0 {
+			m.weights = map[string]float64{exchanges[0]: 2}
+		}
+		lateWindow := []time.Duration{0, 30 * time.Second, 3 * time.Minute}[rng.IntN(3)]
+		gapFill := rng.IntN(2) == 0
+		k := newCore("BTCUSDT:1m", "1m", m, lateWindow, gapFill, MaxRequestLimit)
+		ch := newChecker(t, lateWindow)
+		what := fmt.Sprintf("seed %d (%d exchanges, quorum %d, late window %v, gap fill %v)", seed, n, m.quorum, lateWindow, gapFill)
+
+		cur := make([]int, n) // period each exchange is in
+		now := period(0)
+		for range 300 {
+			now += rng.Int64N(20_000)
+			switch r := rng.IntN(100); {
+			case r < 5:
+				ch.check(k.flush(now), now, what)
+			case r < 7:
+				ch.check(k.closePending(period(slices.Max(cur)-rng.IntN(2)), "shutdown", now), now, what)
+			default:
+				e := rng.IntN(n)
+				i := cur[e]
+				switch {
+				case r < 15:
+					i = max(0, i-1-rng.IntN(3)) // replays an old period
+				case r < 20:
+					cur[e] += 1 + rng.IntN(4) // skips periods
+					i = cur[e]
+				}
+				closed := rng.IntN(3) == 0
+				ems, ok := k.update(randomBar(rng, exchanges[e], i, closed), now)
+				if !ok && len(ems) > 0 {
+					t.Fatalf("%s: dropped update emitted %v", what, describe(ems))
+				}
+				ch.check(ems, now, what)
+				if closed && i == cur[e] {
+					cur[e]++
+				}
+			}
+			now = max(now, period(slices.Max(cur)))
+		}
+
+		// Once everything is over, every period that was open has closed.
+		now = period(slices.Max(cur) + 10)
+		ch.check(k.flush(now), now, what)
+		for t0 := range ch.opened {
+			if !ch.closed[t0] {
+				t.Fatalf("%s: period %d never closed", what, t0)
+			}
+		}
+		if len(k.pending) > 0 {
+			t.Fatalf("%s: %d periods pending after the final flush", what, len(k.pending))
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package aggregator
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Every subscriber has its own bounded queue and dispatch goroutine.
+// Publishing copies the candles into each subscriber's queue under the key's
+// lock, which keeps every subscriber's candles in Seq order, and never
+// waits for a handler: a slow handler only fills its own queue.  When a
+// queue is full the oldest droppable value makes room: for candles, the
+// oldest in-progress update, which a later update of its period
+// supersedes.  Only if there is none is a new in-progress update dropped,
+// or, for a closed candle, the oldest queued one.  Drops are counted in
+// candles_aggregator_dispatch_dropped_total and passed to the handler with
+// the next value it gets.  Handlers get a pointer that is only valid for
+// the duration of the call.  Divergence subscribers (see divergence.go) are
+// dispatched the same way, every value droppable.
+
+// DefaultSubscriberQueue is the default capacity of a subscriber's queue.
+const DefaultSubscriberQueue = 1024
+
+// subscriber is one registered handler of Ts: candles or divergences.
+type subscriber[T any] struct {
+	id        uint64
+	handler   func(v *T, dropped int)
+	droppable func(*T) bool // nil if every value is
+	drops     prometheus.Counter
+
+	mu       sync.Mutex
+	idle     *sync.Cond // broadcast when the queue drains or the subscriber stops
+	buf      []T
+	head, n  int // ring of the n queued values starting at buf[head]
+	limit    int
+	inflight int // values handed to the handler and not yet done
+	lost     int // values dropped and not yet reported to the handler
+
+	stopped atomic.Bool
+	ready   chan struct{} // signalled (without blocking) after every push
+}
+
+func newSubscriber[T any](id uint64, handler func(*T, int), droppable func(*T) bool, limit int, drops prometheus.Counter) *subscriber[T] {
+	s := &subscriber[T]{
+		id:        id,
+		handler:   handler,
+		droppable: droppable,
+		drops:     drops,
+		limit:     limit,
+		ready:     make(chan struct{}, 1),
+	}
+	s.idle = sync.NewCond(&s.mu)
+	return s
+}
+
+// push queues cs, making room as described above if the queue is full.
+func (s *subscriber[T]) push(cs []T) {
+	s.mu.Lock()
+	dropped := 0
+	for i := range cs {
+		if s.n == len(s.buf) {
+			if len(s.buf) < s.limit {
+				s.grow()
+			} else {
+				dropped++
+				if j := s.oldestDroppable(); j >= 0 {
+					s.remove(j)
+				} else if s.droppable != nil && s.droppable(&cs[i]) {
+					continue
+				} else {
+					s.remove(0)
+				}
+			}
+		}
+		s.buf[(s.head+s.n)%len(s.buf)] = cs[i]
+		s.n++
+	}
+	s.lost += dropped
+	s.mu.Unlock()
+	if dropped > 0 {
+		s.drops.Add(float64(dropped))
+	}
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// grow doubles the ring, up to limit (called under s.mu).
+func (s *subscriber[T]) grow() {
+	buf := make([]T, min(max(8, 2*len(s.buf)), s.limit))
+	for i := 0; i < s.n; i++ {
+		buf[i] = s.buf[(s.head+i)%len(s.buf)]
+	}
+	s.buf, s.head = buf, 0
+}
+
+// oldestDroppable returns the position in the queue of its oldest droppable
+// value, or -1 (called under s.mu).
+func (s *subscriber[T]) oldestDroppable() int {
+	for i := 0; i < s.n; i++ {
+		if s.droppable == nil || s.droppable(&s.buf[(s.head+i)%len(s.buf)]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// remove deletes the i-th queued value (called under s.mu).
+func (s *subscriber[T]) remove(i int) {
+	var zero T
+	for ; i < s.n-1; i++ {
+		s.buf[(s.head+i)%len(s.buf)] = s.buf[(s.head+i+1)%len(s.buf)]
+	}
+	s.buf[(s.head+s.n-1)%len(s.buf)] = zero
+	s.n--
+}
+
+// run delivers queued values to the handler until stop.
+func (s *subscriber[T]) run() {
+	var batch []T
+	for range s.ready {
+		s.mu.Lock()
+		batch = batch[:0]
+		for ; s.n > 0; s.n-- {
+			batch = append(batch, s.buf[s.head])
+			s.head = (s.head + 1) % len(s.buf)
+		}
+		s.inflight = len(batch)
+		lost := s.lost
+		s.lost = 0
+		s.mu.Unlock()
+
+		for i := range batch {
+			if s.stopped.Load() {
+				return
+			}
+			s.handler(&batch[i], lost)
+			lost = 0
+		}
+
+		s.mu.Lock()
+		s.inflight = 0
+		if s.n == 0 {
+			s.idle.Broadcast()
+		}
+		s.mu.Unlock()
+	}
+}
+
+// stop ends the dispatch goroutine after the handler call in progress, if
+// any, and discards the queue.  It may be called from the handler.
+func (s *subscriber[T]) stop() {
+	if s.stopped.Swap(true) {
+		return
+	}
+	s.mu.Lock()
+	s.n, s.buf = 0, nil
+	s.idle.Broadcast()
+	s.mu.Unlock()
+	close(s.ready)
+}
+
+// wait blocks until everything queued so far has been handled, or the
+// subscriber stopped.
+func (s *subscriber[T]) wait() {
+	s.mu.Lock()
+	for !s.stopped.Load() && (s.n > 0 || s.inflight > 0) {
+		s.idle.Wait()
+	}
+	s.mu.Unlock()
+}
+
+// removeSubscriber unregisters the subscriber id from subs, one of state's
+// subscriber lists, and stops it.
+func removeSubscriber[T any](state *symState, subs *[]*subscriber[T], id uint64) {
+	state.mu.Lock()
+	var gone *subscriber[T]
+	*subs = slices.DeleteFunc(*subs, func(s *subscriber[T]) bool {
+		if s.id == id {
+			gone = s
+			return true
+		}
+		return false
+	})
+	state.mu.Unlock()
+	if gone != nil {
+		gone.stop()
+	}
+}
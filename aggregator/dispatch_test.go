@@ -0,0 +1,81 @@
+package aggregator
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/yitech/candles/model/candle"
+)
+
+// TestSubscriberPush checks which candles a full queue drops, and that the
+// handler is told how many.
+func TestSubscriberPush(t *testing.T) {
+	type got struct {
+		seq     uint64
+		dropped int
+	}
+	var out []got
+	done := make(chan struct{})
+	drops := prometheus.NewCounter(prometheus.CounterOpts{Name: "dropped"})
+	s := newSubscriber(1, func(c *candle.Candle, dropped int) {
+		out = append(out, got{c.Seq, dropped})
+		if c.Seq == 10 {
+			close(done)
+		}
+	}, inProgress, 4, drops)
+
+	// Seq is the push order; closed candles are even.
+	push := func(seq uint64) {
+		s.push([]candle.Candle{{Seq: seq, IsClosed: seq%2 == 0}})
+	}
+	for _, seq := range []uint64{2, 1, 3, 4} {
+		push(seq) // full: 2 1 3 4
+	}
+	push(6)  // evicts 1
+	push(5)  // evicts 3
+	push(7)  // evicts 5
+	push(8)  // evicts 7
+	push(9)  // every queued candle is closed: dropped
+	push(10) // evicts 2, the oldest
+	go s.run()
+	<-done
+	s.stop()
+
+	want := []got{{4, 6}, {6, 0}, {8, 0}, {10, 0}}
+	if !slices.Equal(out, want) {
+		t.Errorf("delivered %v, want %v", out, want)
+	}
+}
+
+// BenchmarkPublish measures publishing a candle update to a key's
+// subscribers, whose handlers do nothing.
+func BenchmarkPublish(b *testing.B) {
+	for _, n := range []int{1, 100, 1000, 5000} {
+		b.Run(fmt.Sprintf("subscribers=%d", n), func(b *testing.B) {
+			state := &symState{key: "BTCUSDT:1m"}
+			drops := prometheus.NewCounter(prometheus.CounterOpts{Name: "dropped"})
+			for id := range n {
+				s := newSubscriber(uint64(id), func(*candle.Candle, int) {}, inProgress, DefaultSubscriberQueue, drops)
+				state.subs = append(state.subs, s)
+				go s.run()
+			}
+			cs := []candle.Candle{*bar(AggregatedExchange, 0, 100, 101, 99, 100.5, false)}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				cs[0].Seq = uint64(i + 1)
+				state.mu.Lock()
+				publishAndUnlock(state, cs)
+			}
+			b.StopTimer()
+			for _, s := range state.subs {
+				s.wait()
+				s.stop()
+			}
+		})
+	}
+}
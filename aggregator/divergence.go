@@ -0,0 +1,190 @@
+package aggregator
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/yitech/candles/adapter"
+	"github.com/yitech/candles/metrics"
+)
+
+// Divergence monitoring measures, on every merged update of a period, how
+// far apart the exchanges' closes are: the spread between the highest and
+// lowest close, each exchange's deviation from the median close in basis
+// points, and how long ago each exchange last updated the period compared
+// with the freshest one (lead/lag).  Measurements are published to
+// SubscribeDivergence handlers and, with thresholds configured, a period
+// whose spread or any deviation crosses them is flagged, logged and counted
+// once in candles_aggregator_divergence_alerts_total.  Nothing is measured
+// for a key without divergence subscribers or thresholds, nor for resampled
+// keys, whose periods have no exchange candles of their own.
+
+// ErrResampled is returned by SubscribeDivergence for a resampled key.
+var ErrResampled = errors.New("divergence is not measured for resampled intervals")
+
+// DivergenceThresholds are the limits beyond which exchanges are considered
+// to diverge.  Zero fields are not checked.
+type DivergenceThresholds struct {
+	// SpreadBps is the largest spread between the highest and lowest
+	// exchange close, in basis points of the median close.
+	SpreadBps float64
+
+	// DeviationBps is the largest distance of any exchange's close from
+	// the median close, in basis points of it.
+	DeviationBps float64
+}
+
+// enabled reports whether any threshold is set.
+func (t DivergenceThresholds) enabled() bool {
+	return t.SpreadBps > 0 || t.DeviationBps > 0
+}
+
+// Divergence is one measurement of how far the exchanges taking part in a
+// period disagree.
+type Divergence struct {
+	Symbol   string
+	Interval string
+	OpenTime int64
+	Closed   bool // the period is finalized (or revised)
+
+	Median    float64 // median exchange close
+	Spread    float64 // highest minus lowest exchange close
+	SpreadBps float64 // Spread in basis points of Median
+
+	// Exchanges holds one entry per exchange that has reported the
+	// period, sorted by exchange.
+	Exchanges []ExchangeDivergence
+
+	// Alert is set when a threshold is crossed.
+	Alert bool
+}
+
+// ExchangeDivergence is one exchange's part of a Divergence.
+type ExchangeDivergence struct {
+	Exchange     string
+	Close        string
+	DeviationBps float64 // signed distance of Close from the median
+	LagMs        int64   // since the freshest exchange's last update; 0 leads
+	Closed       bool    // the exchange has closed the period
+}
+
+// measure computes the divergence of the period p against th.
+func measure(p *pendingCandle, th DivergenceThresholds) Divergence {
+	d := Divergence{
+		Symbol:    p.agg.Symbol,
+		Interval:  p.agg.Interval,
+		OpenTime:  p.agg.OpenTime,
+		Closed:    p.agg.IsClosed,
+		Exchanges: make([]ExchangeDivergence, 0, len(p.perExchange)),
+	}
+	closes := make([]float64, 0, len(p.perExchange))
+	var newest int64
+	for ex, c := range p.perExchange {
+		d.Exchanges = append(d.Exchanges, ExchangeDivergence{Exchange: ex, Close: c.Close})
+		v, _ := strconv.ParseFloat(c.Close, 64)
+		closes = append(closes, v)
+		newest = max(newest, p.seen[ex])
+	}
+	if len(closes) == 0 {
+		return d
+	}
+	slices.SortFunc(d.Exchanges, func(x, y ExchangeDivergence) int { return cmp.Compare(x.Exchange, y.Exchange) })
+	slices.Sort(closes)
+
+	n := len(closes)
+	d.Median = closes[n/2]
+	if n%2 == 0 {
+		d.Median = (closes[n/2-1] + closes[n/2]) / 2
+	}
+	d.Spread = closes[n-1] - closes[0]
+	d.SpreadBps = bps(d.Spread, d.Median)
+	d.Alert = th.SpreadBps > 0 && d.SpreadBps > th.SpreadBps
+
+	for i := range d.Exchanges {
+		e := &d.Exchanges[i]
+		v, _ := strconv.ParseFloat(e.Close, 64)
+		e.DeviationBps = bps(v-d.Median, d.Median)
+		if seen, ok := p.seen[e.Exchange]; ok {
+			e.LagMs = newest - seen
+		}
+		_, e.Closed = p.closedBy[e.Exchange]
+		if th.DeviationBps > 0 && math.Abs(e.DeviationBps) > th.DeviationBps {
+			d.Alert = true
+		}
+	}
+	return d
+}
+
+// bps is x in basis points of base, or 0 if base is 0.
+func bps(x, base float64) float64 {
+	if base == 0 {
+		return 0
+	}
+	return x / base * 1e4
+}
+
+// diverge measures the periods the core emitted candles from and queues
+// the measurements for state's divergence subscribers (called under lock).
+func (a *Aggregator) diverge(state *symState, ems []emission) {
+	th := state.market.divergence
+	if state.rs != nil || (len(state.divSubs) == 0 && !th.enabled()) {
+		return
+	}
+	for i := range ems {
+		if ems[i].p == nil {
+			continue
+		}
+		d := measure(ems[i].p, th)
+		metrics.DivergenceSpread.WithLabelValues(state.key).Set(d.SpreadBps)
+		if d.Alert && state.divAlerted != d.OpenTime {
+			state.divAlerted = d.OpenTime
+			metrics.DivergenceAlerts.WithLabelValues(state.key).Inc()
+			log.Printf("warn: aggregator [%s]: exchanges diverge in period %d: spread %.1f bps", state.key, d.OpenTime, d.SpreadBps)
+		}
+		for _, s := range state.divSubs {
+			s.push([]Divergence{d})
+		}
+	}
+}
+
+// SubscribeDivergence registers handler to receive a Divergence for every
+// merged update of symbol/interval, starting the key's exchange
+// subscriptions like Subscribe.  handler runs on a goroutine of its own and
+// must not keep the value after it returns.  Resampled keys are refused.
+func (a *Aggregator) SubscribeDivergence(symbol, interval string, handler func(*Divergence)) (adapter.Token, error) {
+	key := symbol + ":" + interval
+	state := a.getOrCreateState(symbol, interval)
+	if state.rs != nil {
+		return nil, fmt.Errorf("aggregator [%s]: %w", key, ErrResampled)
+	}
+
+	state.mu.Lock()
+	id := state.nextID
+	state.nextID++
+	sub := newSubscriber(id, func(d *Divergence, _ int) { handler(d) }, nil, a.queueLen, metrics.DispatchDropped.WithLabelValues(key))
+	state.divSubs = append(state.divSubs, sub)
+	state.mu.Unlock()
+	go sub.run()
+
+	if err := a.setup(key, symbol, interval, state, true); err != nil {
+		removeSubscriber(state, &state.divSubs, id)
+		return nil, err
+	}
+	return &divergenceToken{id: id, state: state}, nil
+}
+
+// divergenceToken cancels a SubscribeDivergence registration.
+type divergenceToken struct {
+	id    uint64
+	state *symState
+}
+
+// Unsubscribe stops deliveries to the handler, as aggregatorToken's does.
+func (t *divergenceToken) Unsubscribe() {
+	removeSubscriber(t.state, &t.state.divSubs, t.id)
+}
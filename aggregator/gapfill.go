@@ -0,0 +1,88 @@
+package aggregator
+
+import (
+	"time"
+
+	"github.com/yitech/candles/model/candle"
+)
+
+// Gap filling (Config.GapFill) keeps a key's history contiguous when no
+// exchange reports a period, as happens on illiquid markets with no trades.
+// When a period is finalized and the previous candle in history is more than
+// one period older, the periods in between get flat synthetic candles
+// (O=H=L=C=previous close, zero volume, Synthetic set), published and stored
+// ahead of the new candle.  Only periods the key was live for, or that a
+// backfill covered, are filled: periods missed while the server was down had
+// trades nobody saw.  At most HistoryDepth periods are filled per gap, the
+// most recent ones.  Backfill fills the gaps between the periods it returns
+// the same way; a gap before the first one cannot be filled.
+
+// fill appends to out synthetic candles for the periods missing between
+// the newest finalized candle and the period at openTime, from since on,
+// and marks them finalized.
+func (k *core) fill(out []emission, openTime, since int64) []emission {
+	if !k.gapFill || !k.hasLast || since == 0 || k.dur == 0 {
+		return out
+	}
+	for _, c := range flatCandles(&k.last, openTime, time.Duration(k.dur)*time.Millisecond, k.fillLimit) {
+		if c.OpenTime < since {
+			continue
+		}
+		out = k.markFinal(out, c.OpenTime)
+		k.last = c
+		out = append(out, emission{Candle: c})
+	}
+	return out
+}
+
+// fillPeriods inserts a synthetic period into every gap between the
+// consecutive periods of ps.
+func fillPeriods(interval string, ps []Period) []Period {
+	dur, err := candle.IntervalDuration(interval)
+	if err != nil || len(ps) < 2 {
+		return ps
+	}
+	out := make([]Period, 0, len(ps))
+	for i := range ps {
+		if i > 0 {
+			prev := &out[len(out)-1].Aggregated
+			for _, c := range flatCandles(prev, ps[i].Aggregated.OpenTime, dur, -1) {
+				out = append(out, Period{Aggregated: c})
+			}
+		}
+		out = append(out, ps[i])
+	}
+	return out
+}
+
+// flatCandles returns synthetic candles for the periods strictly between
+// prev and the period at next: the last limit of them, or all if limit is
+// negative.
+func flatCandles(prev *candle.Candle, next int64, dur time.Duration, limit int) []candle.Candle {
+	step := dur.Milliseconds()
+	first := prev.OpenTime + step
+	if next <= first {
+		return nil
+	}
+	if n := (next - first) / step; limit >= 0 && n > int64(limit) {
+		first = next - int64(limit)*step
+	}
+	out := make([]candle.Candle, 0, (next-first)/step)
+	for t := first; t < next; t += step {
+		out = append(out, candle.Candle{
+			Exchange:  AggregatedExchange,
+			Symbol:    prev.Symbol,
+			Interval:  prev.Interval,
+			OpenTime:  t,
+			Open:      prev.Close,
+			High:      prev.Close,
+			Low:       prev.Close,
+			Close:     prev.Close,
+			Volume:    "0",
+			CloseTime: t + step - 1,
+			IsClosed:  true,
+			Synthetic: true,
+		})
+	}
+	return out
+}
@@ -0,0 +1,59 @@
+package aggregator
+
+import (
+	"github.com/yitech/candles/model/candle"
+)
+
+// Late data (Config.LateWindow): an exchange update for a period that is
+// already finalized, such as a lagging exchange's true close after the
+// period was force-closed or closed by quorum, is merged into the period if
+// it arrives within LateWindow of the period's close time.  If that changes
+// the merged candle, a revision is published: the closed candle again, with
+// its Revision one higher and a new Seq.  History and the store are updated
+// to the revision.  Updates after the window are dropped, as are all late
+// updates without one.  A resampled key revises its periods the same way
+// from its base key's revisions.
+
+// lateUpdate folds an exchange update for a finalized period into it and
+// appends the revision to out, if any.  ok is false if the period is outside
+// the late-data window.
+func (k *core) lateUpdate(out []emission, c *candle.Candle, now int64) ([]emission, bool) {
+	p, found := k.late[c.OpenTime]
+	if !found || !k.inLateWindow(&p.agg, now) {
+		return out, false
+	}
+	p.set(c, now)
+	next := merge(p.perExchange, k.market.weights)
+	cur := &p.agg
+	if next.Open == cur.Open && next.High == cur.High && next.Low == cur.Low &&
+		next.Close == cur.Close && next.Volume == cur.Volume {
+		return out, true
+	}
+	next.IsClosed = true
+	next.Revision = cur.Revision + 1
+	*cur = next
+	if k.last.OpenTime == next.OpenTime {
+		k.last = next
+	}
+	return append(out, emission{Candle: next, exchanges: k.exchangeRecords(p.perExchange), p: p}), true
+}
+
+// keepLate remembers a finalized period for late updates and forgets those
+// past the window.
+func (k *core) keepLate(openTime int64, p *pendingCandle, now int64) {
+	if k.lateWindow <= 0 {
+		return
+	}
+	for t, old := range k.late {
+		if !k.inLateWindow(&old.agg, now) {
+			delete(k.late, t)
+		}
+	}
+	k.late[openTime] = p
+}
+
+// inLateWindow reports whether late updates for the closed candle c are
+// still merged at now.
+func (k *core) inLateWindow(c *candle.Candle, now int64) bool {
+	return now < c.CloseTime+k.lateWindow
+}
@@ -0,0 +1,141 @@
+package aggregator
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/yitech/candles/adapter"
+	"github.com/yitech/candles/model/candle"
+)
+
+// MarketConfig overrides, for one symbol, which exchanges are aggregated and
+// how.  Zero fields take the defaults, which are the behaviour of a symbol
+// without a MarketConfig.
+type MarketConfig struct {
+	// Exchanges names the adapters taking part, e.g. the venues that list
+	// the symbol (default: every adapter).  The others are neither
+	// subscribed nor backfilled for it.
+	Exchanges []string
+
+	// Quorum is how many of Exchanges must close a period before it is
+	// finalized by consensus; 0 means all of them.  Updates from the
+	// remaining exchanges arrive late and are dropped.
+	Quorum int
+
+	// Weights, if set, makes the merged Open and Close the weighted mean of
+	// the exchanges' opens and closes instead of the first and last
+	// exchange's by name.  Exchanges without a weight weigh 1; a weight of
+	// 0 leaves an exchange's prices out of the mean.  High, Low and Volume
+	// are unaffected.
+	Weights map[string]float64
+
+	// Divergence, if set, replaces Config.Divergence for the symbol.
+	Divergence *DivergenceThresholds
+}
+
+// market is the resolved MarketConfig of a symbol.
+type market struct {
+	adapters []adapter.Adapter // taking part, in adapter order
+	quorum   int
+	weights  map[string]float64 // nil: unweighted
+
+	divergence DivergenceThresholds
+}
+
+// newMarket resolves mc against the aggregator's adapters.
+func newMarket(mc MarketConfig, adapters []adapter.Adapter) (*market, error) {
+	m := &market{adapters: adapters, weights: mc.Weights}
+	if mc.Divergence != nil {
+		if mc.Divergence.SpreadBps < 0 || mc.Divergence.DeviationBps < 0 {
+			return nil, fmt.Errorf("divergence thresholds must not be negative")
+		}
+		m.divergence = *mc.Divergence
+	}
+	if len(mc.Exchanges) > 0 {
+		m.adapters = nil
+		for _, ad := range adapters {
+			if slices.Contains(mc.Exchanges, ad.Name()) {
+				m.adapters = append(m.adapters, ad)
+			}
+		}
+		for _, name := range mc.Exchanges {
+			if !m.has(name) {
+				return nil, fmt.Errorf("unknown exchange %q", name)
+			}
+		}
+	}
+	m.quorum = len(m.adapters)
+	if mc.Quorum != 0 {
+		if mc.Quorum < 1 || mc.Quorum > len(m.adapters) {
+			return nil, fmt.Errorf("quorum %d is not between 1 and %d, or 0 for all", mc.Quorum, len(m.adapters))
+		}
+		m.quorum = mc.Quorum
+	}
+	for name, w := range mc.Weights {
+		if !m.has(name) {
+			return nil, fmt.Errorf("weight for %s, which does not take part", name)
+		}
+		if w < 0 {
+			return nil, fmt.Errorf("weight for %s is negative", name)
+		}
+	}
+	return m, nil
+}
+
+// has reports whether exchange takes part in the market.
+func (m *market) has(exchange string) bool {
+	return slices.ContainsFunc(m.adapters, func(ad adapter.Adapter) bool { return ad.Name() == exchange })
+}
+
+// market returns the resolved configuration of symbol.
+func (a *Aggregator) market(symbol string) *market {
+	if m, ok := a.markets[symbol]; ok {
+		return m
+	}
+	return a.defaultMarket
+}
+
+// weigh replaces the Open and Close of agg with the weighted means of the
+// exchanges' opens and closes, rounded to the finest precision they are
+// quoted in.  agg is left alone if no exchange present has a positive
+// weight.
+func weigh(agg *candle.Candle, perEx map[string]*candle.Candle, weights map[string]float64) {
+	names := make([]string, 0, len(perEx))
+	for ex := range perEx {
+		names = append(names, ex)
+	}
+	slices.Sort(names) // a fixed order keeps the sums reproducible
+
+	var sum, open, cls float64
+	prec := 0
+	for _, ex := range names {
+		w, ok := weights[ex]
+		if !ok {
+			w = 1
+		}
+		if w == 0 {
+			continue
+		}
+		o, _ := strconv.ParseFloat(perEx[ex].Open, 64)
+		c, _ := strconv.ParseFloat(perEx[ex].Close, 64)
+		prec = max(prec, decimals(perEx[ex].Open), decimals(perEx[ex].Close))
+		sum += w
+		open += w * o
+		cls += w * c
+	}
+	if sum == 0 {
+		return
+	}
+	agg.Open = strconv.FormatFloat(open/sum, 'f', prec, 64)
+	agg.Close = strconv.FormatFloat(cls/sum, 'f', prec, 64)
+}
+
+// decimals is the number of digits after the decimal point of price.
+func decimals(price string) int {
+	if i := strings.IndexByte(price, '.'); i >= 0 {
+		return len(price) - i - 1
+	}
+	return 0
+}
@@ -0,0 +1,210 @@
+package aggregator
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/yitech/candles/adapter"
+	"github.com/yitech/candles/model/candle"
+)
+
+// Resampling derives a longer interval from the aggregated candles of the
+// base interval (Config.BaseInterval), so 1h, 4h, 1d or intervals the
+// exchanges do not offer, such as 2h or 3d, need no exchange sockets of
+// their own.  Periods are aligned with candle.PeriodStart: to the Unix
+// epoch, or to Mondays for weeks.
+//
+// A resampled period takes the open of its first base candle, the close of
+// its last, the highest high, the lowest low and the summed volume.  It is
+// closed when the base candle ending the period closes, or force-closed when
+// a base candle of a later period arrives first.  A key starts with the
+// period in progress: the base candles already closed in it are backfilled
+// and replayed from the base key's history, while earlier periods come from
+// the store or Warm like any other key's.
+//
+// The key's core (see core.go) takes the resampled candle of each period as
+// its single exchange, so resampled periods are finalized, gap filled and
+// revised like the base key's.
+
+// resampler is the resampling state of one key.
+type resampler struct {
+	source  string // base interval
+	dur     time.Duration
+	baseDur time.Duration
+
+	// The latest version of every base candle of the periods the core
+	// has pending or may still revise, by period and base OpenTime.
+	parts map[int64]map[int64]candle.Candle
+}
+
+// newResampler returns the resampler for interval, or nil if interval is
+// fed by the exchanges directly.
+func (a *Aggregator) newResampler(interval string) *resampler {
+	if a.baseInterval == "" || interval == a.baseInterval {
+		return nil
+	}
+	d, err := candle.IntervalDuration(interval)
+	if err != nil || d <= a.baseDur || d%a.baseDur != 0 {
+		return nil
+	}
+	return &resampler{source: a.baseInterval, dur: d, baseDur: a.baseDur, parts: make(map[int64]map[int64]candle.Candle)}
+}
+
+// startResample seeds the period in progress and subscribes state to its
+// base key.  The returned token unsubscribes from the base key.
+func (a *Aggregator) startResample(state *symState) (adapter.Token, error) {
+	rs := state.rs
+	now := a.now()
+	start := time.UnixMilli(candle.PeriodStart(now.UnixMilli(), rs.dur))
+
+	// Backfill the base periods already over, in case the base key's
+	// history does not reach back to the start of the period.
+	if now.Sub(start) >= rs.baseDur {
+		groups, err := a.fetch(state.symbol, rs.source, start, now)
+		if err != nil {
+			log.Printf("warn: aggregator [%s]: seed resampled period: %v", state.key, err)
+		}
+		for _, t := range sortedTimes(groups) {
+			if t+rs.baseDur.Milliseconds() > now.UnixMilli() {
+				continue // in progress; the live feed has it
+			}
+			c := merge(groups[t], state.market.weights)
+			c.IsClosed = true
+			a.handleBase(state, &c)
+		}
+	}
+
+	n := int(rs.dur / rs.baseDur)
+	tok, err := a.subscribe(state.symbol, rs.source, SubscribeOptions{History: n}, func(c *candle.Candle, _ int) {
+		a.handleBase(state, c)
+	}, false)
+	if err != nil {
+		return nil, fmt.Errorf("aggregator [%s]: resample from %s: %w", state.key, rs.source, err)
+	}
+	return tok, nil
+}
+
+// handleBase folds a base-key candle into the resampled key state.
+func (a *Aggregator) handleBase(state *symState, c *candle.Candle) {
+	rs, k := state.rs, state.core
+	openTime := candle.PeriodStart(c.OpenTime, rs.dur)
+	now := a.now()
+
+	state.mu.Lock()
+	if k.isFinal(openTime) {
+		// Revise the period if it is within the late-data window.
+		if _, ok := rs.parts[openTime]; !ok {
+			state.mu.Unlock()
+			return
+		}
+	} else {
+		if len(k.pending) == 0 && openTime < candle.PeriodStart(now.UnixMilli(), rs.dur) {
+			// A key starts with the period in progress.
+			state.mu.Unlock()
+			return
+		}
+		state.lastUpdate = now
+	}
+
+	parts := rs.parts[openTime]
+	if parts == nil {
+		parts = make(map[int64]candle.Candle)
+		rs.parts[openTime] = parts
+	}
+	parts[c.OpenTime] = *c
+	agg := resample(parts, state.interval, openTime, rs.dur)
+	last := c.OpenTime+rs.baseDur.Milliseconds() == openTime+rs.dur.Milliseconds()
+	agg.IsClosed = c.IsClosed && last
+	ems, _ := k.update(&agg, now.UnixMilli())
+
+	// Forget the parts of periods the core no longer needs.
+	for t := range rs.parts {
+		if _, ok := k.pending[t]; ok {
+			continue
+		}
+		if _, ok := k.late[t]; !ok {
+			delete(rs.parts, t)
+		}
+	}
+	publishAndUnlock(state, a.emit(state, ems))
+}
+
+// fetchResampled backfills the base interval over whole periods covering
+// [start, end) and resamples each exchange's candles, grouped like fetch.
+func (a *Aggregator) fetchResampled(symbol, interval string, rs *resampler, start, end time.Time) (map[int64]map[string]*candle.Candle, error) {
+	from := candle.PeriodStart(start.UnixMilli(), rs.dur)
+	to := candle.PeriodStart(end.UnixMilli()-1, rs.dur) + rs.dur.Milliseconds()
+	base, err := a.fetch(symbol, rs.source, time.UnixMilli(from), time.UnixMilli(to))
+	if err != nil {
+		return nil, err
+	}
+
+	// period -> exchange -> base OpenTime -> candle
+	parts := make(map[int64]map[string]map[int64]candle.Candle)
+	for t, perEx := range base {
+		p := candle.PeriodStart(t, rs.dur)
+		if parts[p] == nil {
+			parts[p] = make(map[string]map[int64]candle.Candle)
+		}
+		for ex, c := range perEx {
+			if parts[p][ex] == nil {
+				parts[p][ex] = make(map[int64]candle.Candle)
+			}
+			parts[p][ex][t] = *c
+		}
+	}
+	groups := make(map[int64]map[string]*candle.Candle, len(parts))
+	for p, perEx := range parts {
+		groups[p] = make(map[string]*candle.Candle, len(perEx))
+		for ex, cs := range perEx {
+			c := resample(cs, interval, p, rs.dur)
+			groups[p][ex] = &c
+		}
+	}
+	return groups, nil
+}
+
+// resample merges the base candles of one period (keyed by OpenTime) into a
+// candle of interval starting at openTime.  The result keeps the Exchange of
+// the parts, is synthetic only if every part is, and is not closed.
+func resample(parts map[int64]candle.Candle, interval string, openTime int64, dur time.Duration) candle.Candle {
+	var first, last int64 = -1, -1
+	var maxH, minL, sumVol float64
+	var high, low string
+	synthetic := true
+	for t, c := range parts {
+		synthetic = synthetic && c.Synthetic
+		if first < 0 || t < first {
+			first = t
+		}
+		if last < 0 || t > last {
+			last = t
+		}
+		if h, _ := strconv.ParseFloat(c.High, 64); high == "" || h > maxH {
+			maxH, high = h, c.High
+		}
+		if l, _ := strconv.ParseFloat(c.Low, 64); low == "" || l < minL {
+			minL, low = l, c.Low
+		}
+		if v, _ := strconv.ParseFloat(c.Volume, 64); v > 0 {
+			sumVol += v
+		}
+	}
+
+	f, l := parts[first], parts[last]
+	return candle.Candle{
+		Exchange:  f.Exchange,
+		Symbol:    f.Symbol,
+		Interval:  interval,
+		OpenTime:  openTime,
+		Open:      f.Open,
+		High:      high,
+		Low:       low,
+		Close:     l.Close,
+		Volume:    strconv.FormatFloat(sumVol, 'f', -1, 64),
+		CloseTime: openTime + dur.Milliseconds() - 1, // as the exchanges report it
+		Synthetic: synthetic,
+	}
+}
@@ -0,0 +1,35 @@
+package aggregator
+
+import (
+	"testing"
+
+	"github.com/yitech/candles/model/candle"
+)
+
+// TestRunningMarkets checks that the base key behind a resampled key runs
+// without counting as a market until it is subscribed to itself.
+func TestRunningMarkets(t *testing.T) {
+	a := NewWithConfig(Config{BaseInterval: "1m"})
+	defer a.Close()
+	nop := func(*candle.Candle, int) {}
+
+	if _, err := a.SubscribeWith("BTCUSDT", "5m", SubscribeOptions{}, nop); err != nil {
+		t.Fatal(err)
+	}
+	if !a.Running("BTCUSDT", "5m") || a.Running("BTCUSDT", "1m") {
+		t.Errorf("running 5m, 1m = %t, %t; want true, false", a.Running("BTCUSDT", "5m"), a.Running("BTCUSDT", "1m"))
+	}
+	if n := a.RunningMarkets(); n != 1 {
+		t.Errorf("%d markets running, want 1", n)
+	}
+
+	if _, err := a.SubscribeWith("BTCUSDT", "1m", SubscribeOptions{}, nop); err != nil {
+		t.Fatal(err)
+	}
+	if !a.Running("BTCUSDT", "1m") {
+		t.Error("1m not running once subscribed")
+	}
+	if n := a.RunningMarkets(); n != 2 {
+		t.Errorf("%d markets running, want 2", n)
+	}
+}
@@ -0,0 +1,370 @@
+package aggregator
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/yitech/candles/metrics"
+	"github.com/yitech/candles/model/candle"
+	"github.com/yitech/candles/wal"
+)
+
+// DefaultCheckpointInterval is how often the WAL is checkpointed.
+const DefaultCheckpointInterval = time.Minute
+
+// walQueue is the number of records buffered for the WAL.
+const walQueue = 4096
+
+// WAL record kinds.
+const (
+	walUpdate byte = 1 + iota // an accepted exchange update
+	walFlush                  // a period finalized by Flush
+)
+
+// walRecord is one WAL entry: either an accepted exchange update or a
+// period finalized by Flush, and the time it was applied, so that replay
+// makes the same decisions.  Force-closes and consensus closes are not
+// logged; replaying the updates reproduces them.
+type walRecord struct {
+	kind byte
+	at   int64 // Unix ms
+
+	// walUpdate: the exchange candle.  walFlush: only Symbol, Interval and
+	// OpenTime, the period finalized.
+	c candle.Candle
+}
+
+// appendTo appends the binary encoding of r to b: the kind, the apply time
+// and the candle's fields, strings prefixed with their length and integers
+// as varints.
+func (r *walRecord) appendTo(b []byte) []byte {
+	str := func(b []byte, s string) []byte {
+		return append(binary.AppendUvarint(b, uint64(len(s))), s...)
+	}
+	c := &r.c
+	b = append(b, r.kind)
+	b = binary.AppendVarint(b, r.at)
+	b = str(b, c.Symbol)
+	b = str(b, c.Interval)
+	b = binary.AppendVarint(b, c.OpenTime)
+	if r.kind != walUpdate {
+		return b
+	}
+	b = str(b, c.Exchange)
+	for _, s := range []string{c.Open, c.High, c.Low, c.Close, c.Volume} {
+		b = str(b, s)
+	}
+	b = binary.AppendVarint(b, c.CloseTime)
+	var closed byte
+	if c.IsClosed {
+		closed = 1
+	}
+	return append(b, closed)
+}
+
+var errWALRecord = errors.New("malformed record")
+
+// decode parses a record encoded by appendTo.
+func (r *walRecord) decode(b []byte) error {
+	bad := false
+	varint := func() int64 {
+		v, n := binary.Varint(b)
+		if n <= 0 {
+			bad = true
+			return 0
+		}
+		b = b[n:]
+		return v
+	}
+	str := func() string {
+		l, n := binary.Uvarint(b)
+		if n <= 0 || l > uint64(len(b)-n) {
+			bad = true
+			return ""
+		}
+		s := string(b[n : n+int(l)])
+		b = b[n+int(l):]
+		return s
+	}
+
+	if len(b) == 0 {
+		return errWALRecord
+	}
+	r.kind, b = b[0], b[1:]
+	if r.kind != walUpdate && r.kind != walFlush {
+		return fmt.Errorf("%w: kind %d", errWALRecord, r.kind)
+	}
+	c := &r.c
+	r.at = varint()
+	c.Symbol = str()
+	c.Interval = str()
+	c.OpenTime = varint()
+	if r.kind == walUpdate {
+		c.Exchange = str()
+		c.Open, c.High, c.Low, c.Close, c.Volume = str(), str(), str(), str(), str()
+		c.CloseTime = varint()
+		if len(b) != 1 {
+			return errWALRecord
+		}
+		c.IsClosed = b[0] == 1
+		b = b[1:]
+	}
+	if bad || len(b) != 0 {
+		return errWALRecord
+	}
+	return nil
+}
+
+// walCheckpoint is the in-flight state of every key.
+type walCheckpoint struct {
+	Markets []marketCheckpoint `json:"markets"`
+}
+
+type marketCheckpoint struct {
+	Symbol    string              `json:"symbol"`
+	Interval  string              `json:"interval"`
+	Watermark int64               `json:"watermark,omitempty"`
+	Finalized []int64             `json:"finalized"` // at or after Watermark
+	Pending   []pendingCheckpoint `json:"pending"`
+}
+
+type pendingCheckpoint struct {
+	PerExchange []candle.Candle `json:"per_exchange"`
+	ClosedBy    []string        `json:"closed_by"`
+}
+
+// Recover rebuilds in-flight periods from the WAL: it restores the latest
+// checkpoint, replays every update logged after it through the same merge
+// logic as live updates, and then starts periodic checkpoints.  Periods
+// that replay finalizes go to history and the store as usual.  Call it once,
+// before Warm or Subscribe; it returns the number of records replayed.
+func (a *Aggregator) Recover() (int, error) {
+	if a.wal == nil {
+		return 0, nil
+	}
+	n := 0
+	err := a.wal.Replay(a.restoreCheckpoint, func(b []byte) error {
+		var rec walRecord
+		if err := rec.decode(b); err != nil {
+			return err
+		}
+		a.replay(&rec)
+		n++
+		return nil
+	})
+	if err != nil {
+		return n, fmt.Errorf("aggregator recover: %w", err)
+	}
+
+	a.ckptStop = make(chan struct{})
+	a.ckptDone = make(chan struct{})
+	go a.checkpointLoop()
+	return n, nil
+}
+
+// restoreCheckpoint loads a checkpoint written by checkpoint.
+func (a *Aggregator) restoreCheckpoint(b []byte) error {
+	var ck walCheckpoint
+	if err := json.Unmarshal(b, &ck); err != nil {
+		return err
+	}
+	for _, m := range ck.Markets {
+		state := a.getOrCreateState(m.Symbol, m.Interval)
+		state.mu.Lock()
+		k := state.core
+		k.watermark = max(k.watermark, m.Watermark)
+		for _, t := range m.Finalized {
+			k.markFinal(nil, t) // nothing is pending yet
+		}
+		for _, pc := range m.Pending {
+			// Periods already in history from the store are skipped.
+			k.restore(pc.PerExchange, pc.ClosedBy)
+		}
+		state.mu.Unlock()
+	}
+	return nil
+}
+
+// replay applies one logged record at the time it was first applied.
+// Nothing is published: no handler is registered yet.
+func (a *Aggregator) replay(rec *walRecord) {
+	state := a.getOrCreateState(rec.c.Symbol, rec.c.Interval)
+	state.mu.Lock()
+	switch rec.kind {
+	case walUpdate:
+		ems, _ := state.core.update(&rec.c, rec.at)
+		a.emit(state, ems)
+	case walFlush:
+		a.emit(state, state.core.closePending(rec.c.OpenTime, "flush", rec.at))
+	}
+	state.mu.Unlock()
+}
+
+// logUpdate queues an update accepted at now for the WAL (called under the
+// key's lock, so a key's records are logged in the order they were
+// applied).
+func (a *Aggregator) logUpdate(c *candle.Candle, now int64) {
+	a.logWAL(walRecord{kind: walUpdate, at: now, c: *c})
+}
+
+// logFlush queues for the WAL that Flush finalized the period at openTime
+// (called under lock).
+func (a *Aggregator) logFlush(state *symState, openTime, now int64) {
+	a.logWAL(walRecord{kind: walFlush, at: now, c: candle.Candle{
+		Symbol:   state.symbol,
+		Interval: state.interval,
+		OpenTime: openTime,
+	}})
+}
+
+// logWAL queues rec for walWriter.  It blocks only while the queue is full,
+// and drops rec once Close has begun.
+func (a *Aggregator) logWAL(rec walRecord) {
+	if a.wal == nil {
+		return
+	}
+	a.walMu.RLock()
+	defer a.walMu.RUnlock()
+	if !a.walDone {
+		a.walRecs <- rec
+	}
+}
+
+// walWriter appends the queued records to the WAL, encoding each into the
+// same buffer.
+func (a *Aggregator) walWriter() {
+	defer close(a.walExit)
+	var buf []byte
+	for rec := range a.walRecs {
+		buf = rec.appendTo(buf[:0])
+		err := a.wal.Append(buf)
+		if errors.Is(err, wal.ErrClosed) {
+			continue
+		}
+		if err != nil {
+			metrics.WALAppendErrors.Inc()
+		}
+		a.walErrMu.Lock()
+		a.walErr = err
+		a.walErrMu.Unlock()
+	}
+}
+
+// stopWAL appends the records still queued and stops walWriter.
+func (a *Aggregator) stopWAL() {
+	if a.wal == nil {
+		return
+	}
+	a.walMu.Lock()
+	if !a.walDone {
+		a.walDone = true
+		close(a.walRecs)
+	}
+	a.walMu.Unlock()
+	<-a.walExit
+}
+
+// WALErr returns the error of the latest WAL append, or nil if it
+// succeeded or there is no WAL.  Updates accepted while appends fail are
+// lost on a restart.
+func (a *Aggregator) WALErr() error {
+	a.walErrMu.Lock()
+	defer a.walErrMu.Unlock()
+	return a.walErr
+}
+
+// checkpoint rotates the WAL, snapshots every key and stores the snapshot
+// as the checkpoint for the closed segments.  Updates applied before a key's
+// snapshot but appended after the rotation, including those still queued
+// when it happened, are both in the snapshot and in the new segment;
+// replaying them again is harmless because applying the same update twice
+// leaves a key as applying it once.
+func (a *Aggregator) checkpoint() error {
+	seg, err := a.wal.Rotate()
+	if err != nil {
+		return err
+	}
+
+	var ck walCheckpoint
+	for _, state := range a.snapshotStates() {
+		state.mu.Lock()
+		k := state.core
+		m := marketCheckpoint{
+			Symbol:    state.symbol,
+			Interval:  state.interval,
+			Watermark: k.watermark,
+			Finalized: make([]int64, 0, len(k.finalized)),
+		}
+		for t := range k.finalized {
+			m.Finalized = append(m.Finalized, t)
+		}
+		slices.Sort(m.Finalized)
+		for _, p := range k.pending {
+			if state.rs != nil {
+				break // rebuilt from the base key on start
+			}
+			var pc pendingCheckpoint
+			for _, c := range p.perExchange {
+				pc.PerExchange = append(pc.PerExchange, *c)
+			}
+			for ex := range p.closedBy {
+				pc.ClosedBy = append(pc.ClosedBy, ex)
+			}
+			m.Pending = append(m.Pending, pc)
+		}
+		state.mu.Unlock()
+		ck.Markets = append(ck.Markets, m)
+	}
+
+	b, err := json.Marshal(ck)
+	if err != nil {
+		return err
+	}
+	return a.wal.Checkpoint(seg, b)
+}
+
+func (a *Aggregator) checkpointLoop() {
+	defer close(a.ckptDone)
+	t := time.NewTicker(a.ckptInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-a.ckptStop:
+			return
+		case <-t.C:
+			if err := a.checkpoint(); err != nil {
+				log.Printf("warn: aggregator checkpoint: %v", err)
+			}
+		}
+	}
+}
+
+// stopCheckpoints ends the checkpoint loop and writes a final checkpoint so
+// the next start replays as little as possible.
+func (a *Aggregator) stopCheckpoints() {
+	if a.ckptStop == nil {
+		return
+	}
+	close(a.ckptStop)
+	<-a.ckptDone
+	a.ckptStop = nil
+	if err := a.checkpoint(); err != nil && !errors.Is(err, wal.ErrClosed) {
+		log.Printf("warn: aggregator checkpoint: %v", err)
+	}
+}
+
+// snapshotStates returns every key's state.
+func (a *Aggregator) snapshotStates() []*symState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]*symState, 0, len(a.states))
+	for _, state := range a.states {
+		out = append(out, state)
+	}
+	return out
+}
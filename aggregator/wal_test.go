@@ -0,0 +1,92 @@
+package aggregator
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yitech/candles/model/candle"
+	"github.com/yitech/candles/wal"
+)
+
+func TestWALRecordEncoding(t *testing.T) {
+	recs := []walRecord{
+		{kind: walUpdate, at: period(3) + 1500, c: *bar("binance", 3, 100, 110.5, 99, 105, true)},
+		{kind: walUpdate, at: -1, c: *bar("okx", 0, 1, 1, 1, 1, false)},
+		{kind: walFlush, at: period(4), c: candle.Candle{Symbol: "ETHUSDT", Interval: "1h", OpenTime: period(2)}},
+	}
+	var buf []byte
+	for _, rec := range recs {
+		buf = rec.appendTo(buf[:0])
+		var got walRecord
+		if err := got.decode(buf); err != nil {
+			t.Fatalf("decode %+v: %v", rec, err)
+		}
+		if got != rec {
+			t.Fatalf("decoded %+v, want %+v", got, rec)
+		}
+		for n := range len(buf) {
+			var short walRecord
+			if err := short.decode(buf[:n]); !errors.Is(err, errWALRecord) {
+				t.Fatalf("decode of %d of %d bytes: %v, want %v", n, len(buf), err, errWALRecord)
+			}
+		}
+		var long walRecord
+		if err := long.decode(append(buf, 0)); !errors.Is(err, errWALRecord) {
+			t.Fatalf("decode with a trailing byte: %v, want %v", err, errWALRecord)
+		}
+	}
+}
+
+// TestRecoverAtApplyTime checks that replay applies each record at the time
+// it was logged, not at the time of the restart: a revision merged within
+// the late-data window is merged again even when the restart comes after
+// the window.
+func TestRecoverAtApplyTime(t *testing.T) {
+	dir := t.TempDir()
+	now := period(1)
+	open := func() (*Aggregator, *wal.Log) {
+		wl, err := wal.Open(dir, wal.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		a := NewWithConfig(Config{WAL: wl, LateWindow: time.Minute, Now: func() time.Time { return time.UnixMilli(now) }})
+		a.defaultMarket = &market{quorum: 2} // exchanges a and b
+		if _, err := a.Recover(); err != nil {
+			t.Fatal(err)
+		}
+		return a, wl
+	}
+	closeAll := func(a *Aggregator, wl *wal.Log) {
+		a.Close()
+		if err := wl.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a, wl := open()
+	state := a.getOrCreateState("BTCUSDT", "1m")
+	a.handleCandle(state, bar("a", 0, 100, 110, 90, 105, true))
+	a.handleCandle(state, bar("b", 0, 100, 110, 90, 104, false))
+	now += 1000
+	a.handleCandle(state, bar("a", 1, 105, 105, 105, 105, false)) // force-closes period 0
+	now += 1000
+	a.handleCandle(state, bar("b", 0, 100, 120, 90, 107, true)) // a revision, within the window
+	want := state.candles
+	if len(want) != 1 || want[0].Revision != 1 {
+		t.Fatalf("history before the restart: %+v, want period 0 at revision 1", want)
+	}
+	// Stop before the final checkpoint so everything is replayed.
+	a.stopWAL()
+	if err := wl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	now = period(10) // past the late-data window of period 0
+	a, wl = open()
+	defer closeAll(a, wl)
+	got := a.getOrCreateState("BTCUSDT", "1m").candles
+	if len(got) != 1 || got[0].Revision != 1 || got[0].High != want[0].High || got[0].Close != want[0].Close {
+		t.Fatalf("history after replay: %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,56 @@
+package aggregator
+
+// Every key keeps a watermark: periods that opened before it count as
+// finalized without being listed in finalized, and updates for them are
+// dropped as late. The watermark trails the newest finalized period by the
+// late-data window: a period is finalized no earlier than its close, so by
+// then any period that opened more than the window before it is past its
+// own window. finalized thus only lists the periods that may still be
+// revised, at most LateWindow/interval + 1 of them, and a key's bookkeeping
+// stays the same size no matter how long the server runs. Pending periods
+// the watermark passes, which only an exchange replaying old periods can
+// leave behind, are dropped and reported as dropped emissions.
+//
+// A period older than the newest one pending or finalized is final too,
+// even if no exchange reported it: opening it then would close it after a
+// newer period and put history out of order.
+
+// markFinal records the period at openTime as finalized, advances the
+// watermark past older periods and appends to out the pending periods it
+// drops.
+func (k *core) markFinal(out []emission, openTime int64) []emission {
+	k.finalized[openTime] = struct{}{}
+	k.newest = max(k.newest, openTime)
+
+	w := openTime - k.lateWindow
+	if k.dur == 0 || w <= k.watermark {
+		return out
+	}
+	k.watermark = w
+	for t := range k.finalized {
+		if t < w {
+			delete(k.finalized, t)
+		}
+	}
+	for t := range k.late {
+		if t < w {
+			delete(k.late, t)
+		}
+	}
+	for t, p := range k.pending {
+		if t < w {
+			out = append(out, emission{Candle: p.agg, dropped: true})
+			delete(k.pending, t)
+		}
+	}
+	return out
+}
+
+// isFinal reports whether the period at openTime is finalized.
+func (k *core) isFinal(openTime int64) bool {
+	if openTime < k.watermark || openTime < k.newest {
+		return true
+	}
+	_, ok := k.finalized[openTime]
+	return ok
+}
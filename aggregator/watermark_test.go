@@ -0,0 +1,67 @@
+package aggregator
+
+import (
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+// TestWatermarkSteadyState runs a key through many periods, with a lagging
+// exchange whose closes arrive late, and checks that its bookkeeping never
+// outgrows the late-data window.
+func TestWatermarkSteadyState(t *testing.T) {
+	for _, lateWindow := range []time.Duration{0, 30 * time.Second, 3 * time.Minute} {
+		t.Run(lateWindow.String(), func(t *testing.T) {
+			rng := rand.New(rand.NewPCG(41, 0))
+			k := newTestCore(2, lateWindow, true)
+			bound := int(lateWindow.Milliseconds()/testDur) + 1
+			check := func(i int) {
+				t.Helper()
+				if len(k.finalized) > bound || len(k.late) > bound || len(k.pending) > bound {
+					t.Fatalf("period %d: %d finalized, %d late, %d pending; want at most %d each",
+						i, len(k.finalized), len(k.late), len(k.pending), bound)
+				}
+			}
+
+			const periods = 20_000
+			for i := 0; i < periods; i++ {
+				if rng.IntN(50) == 0 {
+					i += 1 + rng.IntN(5) // nobody traded: a gap to fill
+				}
+				now := period(i)
+				k.update(randomBar(rng, "a", i, false), now+1000)
+				k.update(randomBar(rng, "b", i, false), now+2000)
+				k.update(randomBar(rng, "a", i, true), now+testDur)
+				check(i)
+				switch rng.IntN(3) {
+				case 0: // b confirms in time
+					k.update(randomBar(rng, "b", i, true), now+testDur+10)
+				case 1: // b confirms after the next period started
+					k.update(randomBar(rng, "a", i+1, false), now+testDur+100)
+					k.update(randomBar(rng, "b", i, true), now+testDur+5000)
+				}
+				k.flush(now + testDur + 10_000)
+				check(i)
+			}
+			if k.newest < period(periods-1) {
+				t.Fatalf("newest period %d, want at least %d", k.newest, period(periods-1))
+			}
+		})
+	}
+}
+
+// TestWatermarkDropsPending checks that a pending period the watermark
+// passes is handed back as dropped, ahead of the period that moved it.
+func TestWatermarkDropsPending(t *testing.T) {
+	k := newTestCore(2, 0, false)
+	if ems, _ := k.update(bar("a", 0, 10, 12, 9, 11, false), period(0)+1000); len(ems) != 1 {
+		t.Fatalf("open period 0: %v", describe(ems))
+	}
+	ems := k.backfilled(nil, *bar("", 3, 10, 12, 9, 11, true), nil, 0)
+	if len(ems) != 2 || !ems[0].dropped || ems[0].OpenTime != period(0) || ems[1].dropped || ems[1].OpenTime != period(3) {
+		t.Fatalf("backfill of period 3: %v, want period 0 dropped, then period 3", describe(ems))
+	}
+	if len(k.pending) != 0 {
+		t.Fatalf("%d periods still pending", len(k.pending))
+	}
+}
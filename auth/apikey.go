@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+)
+
+// APIKey is one static key.  Only its SHA-256 is kept.
+type APIKey struct {
+	Name    string
+	Hash    [sha256.Size]byte
+	Markets []string
+}
+
+// HashKey returns the SHA-256 of key.
+func HashKey(key string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(key))
+}
+
+// ParseKeyHash decodes a hex SHA-256, as printed by sha256sum.
+func ParseKeyHash(s string) ([sha256.Size]byte, error) {
+	var h [sha256.Size]byte
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(h) {
+		return h, fmt.Errorf("auth: %q is not a hex SHA-256", s)
+	}
+	copy(h[:], b)
+	return h, nil
+}
+
+// APIKeys authenticates callers by static key.
+type APIKeys struct {
+	keys []APIKey
+}
+
+// NewAPIKeys returns an authenticator accepting keys.
+func NewAPIKeys(keys []APIKey) *APIKeys {
+	return &APIKeys{keys: keys}
+}
+
+// Authenticate returns the principal of the key matching credential, or
+// ErrUnrecognized.  Every key is compared in constant time.
+func (a *APIKeys) Authenticate(credential string) (*Principal, error) {
+	h := HashKey(credential)
+	var found *APIKey
+	for i := range a.keys {
+		if subtle.ConstantTimeCompare(h[:], a.keys[i].Hash[:]) == 1 {
+			found = &a.keys[i]
+		}
+	}
+	if found == nil {
+		return nil, ErrUnrecognized
+	}
+	return &Principal{Name: found.Name, Markets: found.Markets}, nil
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAPIKeys(t *testing.T) {
+	aliceHash := HashKey("alice-key")
+	a := NewAPIKeys([]APIKey{
+		{Name: "alice", Hash: aliceHash, Markets: []string{"BTCUSDT:*"}},
+		{Name: "bob", Hash: HashKey("bob-key")},
+		{Name: "carol", Hash: HashKey("shared-key")},
+		{Name: "dave", Hash: HashKey("shared-key")},
+	})
+	for _, tc := range []struct {
+		credential string
+		name       string
+		markets    []string
+	}{
+		{"alice-key", "alice", []string{"BTCUSDT:*"}},
+		{"bob-key", "bob", nil},
+		// The search does not stop at a match, so its time does not tell
+		// which key matched; of duplicates, the last wins.
+		{"shared-key", "dave", nil},
+		{"alice-ke", "", nil},
+		{"alice-key ", "", nil},
+		{"ALICE-KEY", "", nil},
+		{"", "", nil},
+		{hex.EncodeToString(aliceHash[:]), "", nil}, // the hash is not the key
+	} {
+		p, err := a.Authenticate(tc.credential)
+		if tc.name == "" {
+			if !errors.Is(err, ErrUnrecognized) {
+				t.Errorf("%q: got %v, %v; want %v", tc.credential, p, err, ErrUnrecognized)
+			}
+			continue
+		}
+		if err != nil || p.Name != tc.name || !slices.Equal(p.Markets, tc.markets) {
+			t.Errorf("%q: got %+v, %v; want %s with %v", tc.credential, p, err, tc.name, tc.markets)
+		}
+	}
+}
+
+func TestParseKeyHash(t *testing.T) {
+	sum := HashKey("key")
+	for _, tc := range []struct {
+		s  string
+		ok bool
+	}{
+		{hex.EncodeToString(sum[:]), true},
+		{strings.ToUpper(hex.EncodeToString(sum[:])), true},
+		{hex.EncodeToString(sum[:31]), false},
+		{hex.EncodeToString(sum[:]) + "00", false},
+		{"key", false},
+		{"", false},
+	} {
+		h, err := ParseKeyHash(tc.s)
+		if (err == nil) != tc.ok || (tc.ok && h != sum) {
+			t.Errorf("ParseKeyHash(%q) = %x, %v; want ok=%t", tc.s, h, err, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Package auth authenticates API callers and decides which markets they
+// may access.
+//
+// A caller presents one credential, a static API key or a JSON Web Token,
+// which an Authenticator maps to a Principal.  A Principal carries the
+// markets it is allowed, as "SYMBOL:INTERVAL" patterns in path.Match
+// syntax ("BTCUSDT:*", "*:1h", "*"); no patterns allow every market.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+)
+
+var (
+	// ErrUnrecognized is returned by an Authenticator for a credential
+	// that is not of its kind, so a Chain tries the next one.
+	ErrUnrecognized = errors.New("auth: unrecognized credentials")
+
+	// ErrInvalid is wrapped by the errors of credentials that were
+	// recognized but rejected, e.g. an expired token.
+	ErrInvalid = errors.New("auth: invalid credentials")
+)
+
+// Principal is an authenticated caller.
+type Principal struct {
+	Name    string   // API key name or token subject
+	Markets []string // allowed market patterns; empty allows all
+}
+
+// Allows reports whether p may access the market symbol/interval.
+func (p *Principal) Allows(symbol, interval string) bool {
+	if len(p.Markets) == 0 {
+		return true
+	}
+	market := symbol + ":" + interval
+	for _, pat := range p.Markets {
+		if ok, _ := path.Match(pat, market); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidatePattern reports whether pat is a well-formed market pattern.
+func ValidatePattern(pat string) error {
+	if _, err := path.Match(pat, ""); err != nil {
+		return fmt.Errorf("market pattern %q: %w", pat, err)
+	}
+	return nil
+}
+
+// Authenticator maps a credential to the Principal presenting it.
+type Authenticator interface {
+	Authenticate(credential string) (*Principal, error)
+}
+
+// Chain tries its authenticators in order; the first that recognizes the
+// credential decides.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(credential string) (*Principal, error) {
+	for _, a := range c {
+		p, err := a.Authenticate(credential)
+		if !errors.Is(err, ErrUnrecognized) {
+			return p, err
+		}
+	}
+	return nil, ErrUnrecognized
+}
+
+type principalKey struct{}
+
+// NewContext returns a copy of ctx carrying p.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the Principal in ctx, if any.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAllows(t *testing.T) {
+	for _, tc := range []struct {
+		markets  []string
+		symbol   string
+		interval string
+		want     bool
+	}{
+		{nil, "BTCUSDT", "1m", true},
+		{[]string{}, "ETHUSDT", "1h", true},
+		{[]string{"*"}, "BTCUSDT", "1m", true},
+		{[]string{"*:*"}, "BTCUSDT", "1m", true},
+		{[]string{"BTCUSDT:*"}, "BTCUSDT", "1h", true},
+		{[]string{"BTCUSDT:*"}, "ETHUSDT", "1h", false},
+		{[]string{"*:1h"}, "ETHUSDT", "1h", true},
+		{[]string{"*:1h"}, "ETHUSDT", "1m", false},
+		{[]string{"ETHUSDT:1m", "BTC*:5m"}, "BTCUSDC", "5m", true},
+		{[]string{"BTCUSDT:1m"}, "BTCUSDT", "1mx", false},
+	} {
+		p := &Principal{Name: "p", Markets: tc.markets}
+		if got := p.Allows(tc.symbol, tc.interval); got != tc.want {
+			t.Errorf("%v allows %s:%s = %t, want %t", tc.markets, tc.symbol, tc.interval, got, tc.want)
+		}
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	for pat, ok := range map[string]bool{
+		"BTCUSDT:*": true,
+		"*:1h":      true,
+		"[BE]*:1m":  true,
+		"[":         false,
+		"BTC[:1m":   false,
+	} {
+		if err := ValidatePattern(pat); (err == nil) != ok {
+			t.Errorf("ValidatePattern(%q) = %v, want ok=%t", pat, err, ok)
+		}
+	}
+}
+
+// staticAuth accepts one credential and does not recognize the others.
+type staticAuth struct {
+	credential string
+	err        error // returned for the credential instead of a principal
+}
+
+func (a staticAuth) Authenticate(credential string) (*Principal, error) {
+	if credential != a.credential {
+		return nil, ErrUnrecognized
+	}
+	if a.err != nil {
+		return nil, a.err
+	}
+	return &Principal{Name: a.credential}, nil
+}
+
+func TestChain(t *testing.T) {
+	rejected := errors.New("rejected")
+	c := Chain{staticAuth{credential: "a"}, staticAuth{credential: "b", err: rejected}, staticAuth{credential: "b"}}
+	for _, tc := range []struct {
+		credential string
+		name       string
+		err        error
+	}{
+		{"a", "a", nil},
+		{"b", "", rejected}, // the first to recognize it decides
+		{"c", "", ErrUnrecognized},
+	} {
+		p, err := c.Authenticate(tc.credential)
+		if !errors.Is(err, tc.err) || (p == nil) != (tc.name == "") || (p != nil && p.Name != tc.name) {
+			t.Errorf("%q: got %v, %v; want %q, %v", tc.credential, p, err, tc.name, tc.err)
+		}
+	}
+}
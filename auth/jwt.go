@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// jwtAlgorithms are the signature algorithms accepted.  Tokens must be
+// signed with a public key from the JWKS; shared secrets are not
+// supported.
+var jwtAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+}
+
+// DefaultMarketsClaim is the claim listing a token's markets.
+const DefaultMarketsClaim = "markets"
+
+// JWTOptions configures a JWT authenticator.
+type JWTOptions struct {
+	// JWKSFile is a JSON Web Key Set holding the issuer's public keys.
+	// It is read again when it changes, so keys can be rotated in place.
+	JWKSFile string
+
+	Issuer   string // required "iss"; empty accepts any
+	Audience string // required in "aud"; empty accepts any
+
+	// MarketsClaim names the claim listing the token's market patterns,
+	// as an array or a space-separated string; a token without it may
+	// access every market.  Empty means DefaultMarketsClaim.
+	MarketsClaim string
+}
+
+// JWT authenticates callers by signed JSON Web Token.  A token must name
+// its key in "kid" and carry an expiry; "sub" is the principal's name.
+type JWT struct {
+	opts JWTOptions
+
+	mu      sync.Mutex
+	keys    *jose.JSONWebKeySet
+	modTime time.Time
+}
+
+// NewJWT loads the key set in opts.JWKSFile.
+func NewJWT(opts JWTOptions) (*JWT, error) {
+	if opts.MarketsClaim == "" {
+		opts.MarketsClaim = DefaultMarketsClaim
+	}
+	j := &JWT{opts: opts}
+	fi, err := os.Stat(opts.JWKSFile)
+	if err != nil {
+		return nil, fmt.Errorf("auth: jwks: %w", err)
+	}
+	if err := j.load(fi.ModTime()); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// load reads the key set (called under lock, or before j is shared).
+func (j *JWT) load(modTime time.Time) error {
+	data, err := os.ReadFile(j.opts.JWKSFile)
+	if err != nil {
+		return fmt.Errorf("auth: jwks: %w", err)
+	}
+	var set jose.JSONWebKeySet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("auth: jwks %s: %w", j.opts.JWKSFile, err)
+	}
+	for _, k := range set.Keys {
+		if !k.IsPublic() {
+			return fmt.Errorf("auth: jwks %s: key %q is not a public key", j.opts.JWKSFile, k.KeyID)
+		}
+	}
+	j.keys = &set
+	j.modTime = modTime
+	return nil
+}
+
+// keySet returns the current key set, reloading the file if it changed.
+// A file that fails to load leaves the previous keys in place.
+func (j *JWT) keySet() *jose.JSONWebKeySet {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if fi, err := os.Stat(j.opts.JWKSFile); err == nil && !fi.ModTime().Equal(j.modTime) {
+		if err := j.load(fi.ModTime()); err != nil {
+			j.modTime = fi.ModTime() // warn once per change
+			log.Printf("warn: %v (keeping the previous keys)", err)
+		} else {
+			log.Printf("auth: reloaded %s", j.opts.JWKSFile)
+		}
+	}
+	return j.keys
+}
+
+// Authenticate verifies credential as a JWT.  Anything that does not parse
+// as a signed token is ErrUnrecognized.
+func (j *JWT) Authenticate(credential string) (*Principal, error) {
+	tok, err := jwt.ParseSigned(credential, jwtAlgorithms)
+	if err != nil {
+		return nil, ErrUnrecognized
+	}
+	var claims jwt.Claims
+	var extra map[string]any
+	if err := tok.Claims(j.keySet(), &claims, &extra); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if claims.Expiry == nil {
+		return nil, fmt.Errorf("%w: token has no expiry", ErrInvalid)
+	}
+	want := jwt.Expected{Issuer: j.opts.Issuer, Time: time.Now()}
+	if j.opts.Audience != "" {
+		want.AnyAudience = jwt.Audience{j.opts.Audience}
+	}
+	if err := claims.Validate(want); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	markets, err := marketsClaim(extra[j.opts.MarketsClaim])
+	if err != nil {
+		return nil, fmt.Errorf("%w: claim %q: %v", ErrInvalid, j.opts.MarketsClaim, err)
+	}
+	return &Principal{Name: claims.Subject, Markets: markets}, nil
+}
+
+// marketsClaim reads market patterns from an array of strings or a
+// space-separated string.
+func marketsClaim(v any) ([]string, error) {
+	var out []string
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		out = strings.Fields(v)
+	case []any:
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("not a list of strings")
+			}
+			out = append(out, s)
+		}
+	default:
+		return nil, fmt.Errorf("not a list of strings")
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("lists no markets")
+	}
+	for _, pat := range out {
+		if err := ValidatePattern(pat); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// testKey is a signing key and the public half served in the JWKS.
+type testKey struct {
+	id   string
+	priv *ecdsa.PrivateKey
+}
+
+func newTestKey(t *testing.T, id string) testKey {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKey{id: id, priv: priv}
+}
+
+func (k testKey) public() jose.JSONWebKey {
+	return jose.JSONWebKey{Key: &k.priv.PublicKey, KeyID: k.id, Algorithm: string(jose.ES256), Use: "sig"}
+}
+
+// writeJWKS writes the public keys of keys to path, with a modification
+// time of mod.
+func writeJWKS(t *testing.T, path string, mod time.Time, keys ...jose.JSONWebKey) {
+	t.Helper()
+	data, err := json.Marshal(jose.JSONWebKeySet{Keys: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mod, mod); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// sign returns a token of claims signed by key with alg, naming kid.
+func sign(t *testing.T, alg jose.SignatureAlgorithm, key any, kid string, claims ...any) string {
+	t.Helper()
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", kid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := jwt.Signed(sig)
+	for _, c := range claims {
+		b = b.Claims(c)
+	}
+	tok, err := b.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+func TestJWT(t *testing.T) {
+	k1, other := newTestKey(t, "k1"), newTestKey(t, "k2")
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, time.Now(), k1.public())
+	j, err := NewJWT(JWTOptions{JWKSFile: path, Issuer: "https://issuer", Audience: "candles"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	valid := func() jwt.Claims {
+		return jwt.Claims{
+			Subject:  "alice",
+			Issuer:   "https://issuer",
+			Audience: jwt.Audience{"other", "candles"},
+			Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+		}
+	}
+	with := func(f func(*jwt.Claims)) jwt.Claims {
+		c := valid()
+		f(&c)
+		return c
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(&k1.priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+	payload, _ := json.Marshal(valid())
+
+	for _, tc := range []struct {
+		name    string
+		token   string
+		err     error // nil accepts the token
+		markets []string
+	}{
+		{"valid", sign(t, jose.ES256, k1.priv, "k1", valid()), nil, nil},
+		{"markets as an array", sign(t, jose.ES256, k1.priv, "k1", valid(), map[string]any{"markets": []string{"BTCUSDT:*", "*:1h"}}), nil, []string{"BTCUSDT:*", "*:1h"}},
+		{"markets as a string", sign(t, jose.ES256, k1.priv, "k1", valid(), map[string]any{"markets": "BTCUSDT:* *:1h"}), nil, []string{"BTCUSDT:*", "*:1h"}},
+		{"empty markets", sign(t, jose.ES256, k1.priv, "k1", valid(), map[string]any{"markets": []string{}}), ErrInvalid, nil},
+		{"bad market pattern", sign(t, jose.ES256, k1.priv, "k1", valid(), map[string]any{"markets": "["}), ErrInvalid, nil},
+		{"markets not strings", sign(t, jose.ES256, k1.priv, "k1", valid(), map[string]any{"markets": []int{1}}), ErrInvalid, nil},
+
+		{"signed by another key", sign(t, jose.ES256, other.priv, "k1", valid()), ErrInvalid, nil},
+		{"unknown kid", sign(t, jose.ES256, other.priv, "k2", valid()), ErrInvalid, nil},
+		{"tampered payload", func() string {
+			tok := sign(t, jose.ES256, k1.priv, "k1", valid())
+			parts := strings.Split(tok, ".")
+			forged, _ := json.Marshal(with(func(c *jwt.Claims) { c.Subject = "root" }))
+			return parts[0] + "." + b64(forged) + "." + parts[2]
+		}(), ErrInvalid, nil},
+
+		{"expired", sign(t, jose.ES256, k1.priv, "k1", with(func(c *jwt.Claims) { c.Expiry = jwt.NewNumericDate(now.Add(-time.Hour)) })), ErrInvalid, nil},
+		{"no expiry", sign(t, jose.ES256, k1.priv, "k1", with(func(c *jwt.Claims) { c.Expiry = nil })), ErrInvalid, nil},
+		{"not yet valid", sign(t, jose.ES256, k1.priv, "k1", with(func(c *jwt.Claims) { c.NotBefore = jwt.NewNumericDate(now.Add(time.Hour)) })), ErrInvalid, nil},
+		{"valid since", sign(t, jose.ES256, k1.priv, "k1", with(func(c *jwt.Claims) { c.NotBefore = jwt.NewNumericDate(now.Add(-time.Hour)) })), nil, nil},
+		{"wrong issuer", sign(t, jose.ES256, k1.priv, "k1", with(func(c *jwt.Claims) { c.Issuer = "https://evil" })), ErrInvalid, nil},
+		{"no issuer", sign(t, jose.ES256, k1.priv, "k1", with(func(c *jwt.Claims) { c.Issuer = "" })), ErrInvalid, nil},
+		{"wrong audience", sign(t, jose.ES256, k1.priv, "k1", with(func(c *jwt.Claims) { c.Audience = jwt.Audience{"other"} })), ErrInvalid, nil},
+		{"no audience", sign(t, jose.ES256, k1.priv, "k1", with(func(c *jwt.Claims) { c.Audience = nil })), ErrInvalid, nil},
+
+		// The public key used as an HMAC secret, and no signature at all.
+		{"HS256 with the public key", sign(t, jose.HS256, pubDER, "k1", valid()), ErrUnrecognized, nil},
+		{"alg none", b64([]byte(`{"alg":"none","kid":"k1","typ":"JWT"}`)) + "." + b64(payload) + ".", ErrUnrecognized, nil},
+
+		{"not a token", "alice-key", ErrUnrecognized, nil},
+	} {
+		p, err := j.Authenticate(tc.token)
+		if tc.err != nil {
+			if !errors.Is(err, tc.err) || p != nil {
+				t.Errorf("%s: got %v, %v; want %v", tc.name, p, err, tc.err)
+			}
+			continue
+		}
+		if err != nil || p.Name != "alice" || !slices.Equal(p.Markets, tc.markets) {
+			t.Errorf("%s: got %+v, %v; want alice with %v", tc.name, p, err, tc.markets)
+		}
+	}
+}
+
+// TestJWTReload checks that keys are rotated by rewriting the JWKS file,
+// and that a file that does not load keeps the previous keys.
+func TestJWTReload(t *testing.T) {
+	k1, k2 := newTestKey(t, "k1"), newTestKey(t, "k2")
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	mod := time.Now().Add(-time.Hour)
+	writeJWKS(t, path, mod, k1.public())
+	j, err := NewJWT(JWTOptions{JWKSFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := jwt.Claims{Subject: "alice", Expiry: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+	tok1 := sign(t, jose.ES256, k1.priv, "k1", claims)
+	tok2 := sign(t, jose.ES256, k2.priv, "k2", claims)
+	check := func(what string, ok1, ok2 bool) {
+		t.Helper()
+		if _, err := j.Authenticate(tok1); (err == nil) != ok1 {
+			t.Errorf("%s: k1 token: %v, want ok=%t", what, err, ok1)
+		}
+		if _, err := j.Authenticate(tok2); (err == nil) != ok2 {
+			t.Errorf("%s: k2 token: %v, want ok=%t", what, err, ok2)
+		}
+	}
+	check("initial keys", true, false)
+
+	mod = mod.Add(time.Minute)
+	writeJWKS(t, path, mod, k1.public(), k2.public())
+	check("k2 added", true, true)
+
+	mod = mod.Add(time.Minute)
+	writeJWKS(t, path, mod, k2.public())
+	check("k1 retired", false, true)
+
+	mod = mod.Add(time.Minute)
+	if err := os.WriteFile(path, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mod, mod); err != nil {
+		t.Fatal(err)
+	}
+	check("broken file", false, true)
+
+	mod = mod.Add(time.Minute)
+	private := jose.JSONWebKey{Key: k1.priv, KeyID: "k1", Algorithm: string(jose.ES256)}
+	writeJWKS(t, path, mod, private)
+	check("private key", false, true)
+
+	if _, err := NewJWT(JWTOptions{JWKSFile: path}); err == nil {
+		t.Error("NewJWT accepted a JWKS holding a private key")
+	}
+}
@@ -0,0 +1,521 @@
+package main
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/yitech/candles/aggregator"
+	"github.com/yitech/candles/metrics"
+	"github.com/yitech/candles/model/candle"
+	"github.com/yitech/candles/store"
+)
+
+const day = 24 * time.Hour
+
+// unavailableRetry is how long a period an exchange returned nothing for
+// is left alone before it is requested again.
+const unavailableRetry = day
+
+// maxUnavailable caps the unavailable periods remembered per series.  Past
+// it, further periods without data are requested again on every scan.
+const maxUnavailable = 10_000
+
+// market is one archived symbol and interval.
+type market struct {
+	symbol, interval string
+	d                time.Duration
+	dur              int64    // interval length in ms
+	series           []string // exchanges taking part, then aggregator.AggregatedExchange
+
+	live atomic.Int64 // closed periods received from the aggregator
+}
+
+func newMarket(symbol, interval string, exchanges []string) *market {
+	d, _ := candle.IntervalDuration(interval) // validated
+	return &market{
+		symbol:   symbol,
+		interval: interval,
+		d:        d,
+		dur:      d.Milliseconds(),
+		series:   append(slices.Clone(exchanges), aggregator.AggregatedExchange),
+	}
+}
+
+func (m *market) String() string { return m.symbol + ":" + m.interval }
+
+// floor returns the OpenTime of the period containing t.
+func (m *market) floor(t int64) int64 { return candle.PeriodStart(t, m.d) }
+
+// ceil returns the first OpenTime at or after t.
+func (m *market) ceil(t int64) int64 {
+	if f := m.floor(t); f != t {
+		return f + m.dur
+	}
+	return t
+}
+
+// end is the OpenTime of the period in progress: every earlier period is
+// closed and expected in the store.
+func (m *market) end() int64 { return m.floor(time.Now().UnixMilli()) }
+
+// archiver keeps the store complete for every market: scan fills holes
+// since start, verify rewrites recent periods the exchanges revised, and the
+// aggregator writes live periods as they close.  Scan, verify and report run
+// on one goroutine, so the series states need no locking.
+type archiver struct {
+	st       store.Store
+	agg      *aggregator.Aggregator
+	markets  []*market
+	start    int64
+	window   time.Duration
+	fullScan time.Duration // how often scan checks the whole archive
+
+	series   map[store.Series]*seriesState
+	lastFull time.Time // start of the last full scan
+}
+
+// seriesState is what the archiver remembers of one series between scans.
+type seriesState struct {
+	// complete is the OpenTime before which every period since start is
+	// stored or unavailable.  Scans between full scans start there, so
+	// they read only the recent end of the series.
+	complete int64
+
+	// first is the oldest OpenTime stored since start as of the last full
+	// scan; -1 for none.
+	first int64
+
+	// unavailable holds the periods the exchange returned nothing for when
+	// they were filled, and when.  They are requested again after
+	// unavailableRetry.
+	unavailable map[int64]time.Time
+}
+
+func newArchiver(cfg Config, st store.Store, agg *aggregator.Aggregator) *archiver {
+	start, _ := parseTime(cfg.Start) // validated
+	a := &archiver{
+		st:       st,
+		agg:      agg,
+		start:    start.UnixMilli(),
+		window:   cfg.Verify.Window,
+		fullScan: cfg.FullScanInterval,
+		series:   make(map[store.Series]*seriesState),
+	}
+	for _, mc := range cfg.Markets {
+		for _, iv := range mc.Intervals {
+			a.markets = append(a.markets, newMarket(mc.Symbol, iv, mc.exchanges(cfg.Exchanges)))
+		}
+	}
+	return a
+}
+
+// follow subscribes every market on the aggregator, which stores each
+// period as it closes.
+func (a *archiver) follow() {
+	for _, m := range a.markets {
+		_, err := a.agg.Subscribe(m.symbol, m.interval, func(c *candle.Candle) {
+			if c.IsClosed {
+				m.live.Add(1)
+			}
+		})
+		if err != nil {
+			log.Printf("warn: archiver [%s]: follow: %v", m, err)
+		}
+	}
+}
+
+// run scans, verifies and reports until ctx is done.
+func (a *archiver) run(ctx context.Context, scanEvery, verifyEvery, reportEvery time.Duration) {
+	a.scan(ctx)
+	a.report()
+
+	scanT := time.NewTicker(scanEvery)
+	defer scanT.Stop()
+	verifyT := time.NewTicker(verifyEvery)
+	defer verifyT.Stop()
+	reportT := time.NewTicker(reportEvery)
+	defer reportT.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-scanT.C:
+			a.scan(ctx)
+		case <-verifyT.C:
+			a.verify(ctx)
+		case <-reportT.C:
+			a.report()
+		}
+	}
+}
+
+func (a *archiver) seriesOf(m *market, ex string) store.Series {
+	return store.Series{Exchange: ex, Symbol: m.symbol, Interval: m.interval}
+}
+
+// state returns the state of series ex of m.
+func (a *archiver) state(m *market, ex string) *seriesState {
+	s := a.seriesOf(m, ex)
+	ss := a.series[s]
+	if ss == nil {
+		ss = &seriesState{complete: m.ceil(a.start), first: -1, unavailable: make(map[int64]time.Time)}
+		a.series[s] = ss
+	}
+	return ss
+}
+
+// scan fills every hole of every market: since start on the first scan and
+// every fullScan, and since the first known hole of each series otherwise.
+func (a *archiver) scan(ctx context.Context) {
+	full := time.Since(a.lastFull) >= a.fullScan
+	if full {
+		a.lastFull = time.Now()
+	}
+	for _, m := range a.markets {
+		if ctx.Err() != nil {
+			return
+		}
+		a.fillHoles(ctx, m, full)
+	}
+}
+
+// fillHoles backfills the periods of m missing from any series, in runs of
+// consecutive periods at most a day long.
+func (a *archiver) fillHoles(ctx context.Context, m *market, full bool) {
+	start, end := m.ceil(a.start), m.end()
+	holes := make(map[int64]struct{})
+	missing := make(map[string][]int64, len(m.series))
+	for _, ex := range m.series {
+		ss := a.state(m, ex)
+		ss.expire(time.Now())
+		from := ss.complete
+		if full {
+			from = start
+		}
+		have, err := a.st.OpenTimes(a.seriesOf(m, ex), from, end)
+		if err != nil {
+			log.Printf("warn: archiver [%s]: %v", m, err)
+			return
+		}
+		missing[ex] = ss.missing(m, have, from, end)
+		if full {
+			ss.first = -1
+			if len(have) > 0 {
+				ss.first = have[0]
+			}
+		}
+		ss.complete = end
+		if len(missing[ex]) > 0 {
+			ss.complete = missing[ex][0]
+		}
+		for _, t := range missing[ex] {
+			holes[t] = struct{}{}
+		}
+	}
+	if len(holes) == 0 {
+		return
+	}
+	times := make([]int64, 0, len(holes))
+	for t := range holes {
+		times = append(times, t)
+	}
+	slices.Sort(times)
+	log.Printf("archiver [%s]: %d periods to fill", m, len(times))
+
+	chunk := max(day.Milliseconds(), m.dur)
+	for i := 0; i < len(times); {
+		if ctx.Err() != nil {
+			return
+		}
+		j := i + 1
+		for j < len(times) && times[j] == times[j-1]+m.dur && times[j]+m.dur-times[i] <= chunk {
+			j++
+		}
+		left, ok := a.fill(m, times[i:j])
+		if ok {
+			a.advance(m, missing, left, times[i], times[j-1]+m.dur)
+		}
+		i = j
+	}
+}
+
+// advance moves complete past [from, to) for every series whose first
+// missing period fill just handled there.  left holds, by series, the first
+// period fill could neither store nor mark unavailable.
+func (a *archiver) advance(m *market, missing map[string][]int64, left map[string]int64, from, to int64) {
+	end := m.end()
+	for ex, times := range missing {
+		ss := a.state(m, ex)
+		if ss.complete < from || ss.complete >= to {
+			continue // held back by an earlier run, or not in this one
+		}
+		if t, ok := left[ex]; ok {
+			ss.complete = t
+			continue
+		}
+		ss.complete = end
+		if i, _ := slices.BinarySearch(times, to); i < len(times) {
+			ss.complete = times[i]
+		}
+	}
+}
+
+// missing returns the periods of m in [start, end) that are neither in
+// have, the OpenTimes stored, nor unavailable.  Unavailable periods found
+// stored since, live or by verify, are forgotten.
+func (ss *seriesState) missing(m *market, have []int64, start, end int64) []int64 {
+	var out []int64
+	i := 0
+	for t := start; t < end; t += m.dur {
+		for i < len(have) && have[i] < t {
+			i++
+		}
+		if i < len(have) && have[i] == t {
+			delete(ss.unavailable, t)
+			continue
+		}
+		if _, ok := ss.unavailable[t]; !ok {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// expire forgets the unavailable periods marked unavailableRetry or more
+// before now, so that the next scan requests them again.
+func (ss *seriesState) expire(now time.Time) {
+	for t, at := range ss.unavailable {
+		if now.Sub(at) >= unavailableRetry {
+			delete(ss.unavailable, t)
+			ss.complete = min(ss.complete, t)
+		}
+	}
+}
+
+// markUnavailable records that the exchange returned nothing for the
+// period at t, unless maxUnavailable periods are recorded already, and
+// reports whether it did.
+func (ss *seriesState) markUnavailable(t int64, now time.Time) bool {
+	if len(ss.unavailable) >= maxUnavailable {
+		return false
+	}
+	ss.unavailable[t] = now
+	return true
+}
+
+// fill backfills the consecutive periods in times and stores, for every
+// series, the candles it was missing.  Periods still missing afterwards are
+// marked unavailable for that series; left holds, by series, the first
+// that could not be for the cap.  ok is false if nothing was stored.
+func (a *archiver) fill(m *market, times []int64) (left map[string]int64, ok bool) {
+	from, to := times[0], times[len(times)-1]+m.dur
+	periods, err := a.agg.BackfillPeriods(m.symbol, m.interval, time.UnixMilli(from), time.UnixMilli(to))
+	if err != nil {
+		log.Printf("warn: archiver [%s]: fill %s: %v", m, span(from, to), err)
+		return nil, false // retried on the next scan
+	}
+
+	got := make(map[string]map[int64]candle.Candle, len(m.series))
+	for _, p := range periods {
+		if p.Aggregated.OpenTime < from || p.Aggregated.OpenTime >= to {
+			continue
+		}
+		for _, c := range append([]candle.Candle{p.Aggregated}, p.Exchanges...) {
+			if got[c.Exchange] == nil {
+				got[c.Exchange] = make(map[int64]candle.Candle)
+			}
+			got[c.Exchange][c.OpenTime] = c
+		}
+	}
+
+	var put []candle.Candle
+	filled := make(map[string]int, len(m.series))
+	left = make(map[string]int64)
+	now := time.Now()
+	for _, ex := range m.series {
+		have, err := a.st.OpenTimes(a.seriesOf(m, ex), from, to)
+		if err != nil {
+			log.Printf("warn: archiver [%s]: %v", m, err)
+			return nil, false
+		}
+		ss := a.state(m, ex)
+		for _, t := range ss.missing(m, have, from, to) {
+			c, ok := got[ex][t]
+			if !ok {
+				if _, seen := left[ex]; !seen && !ss.markUnavailable(t, now) {
+					left[ex] = t
+				}
+				continue
+			}
+			put = append(put, c)
+			filled[ex]++
+		}
+	}
+	if err := a.st.Put(put); err != nil {
+		log.Printf("warn: archiver [%s]: %v", m, err)
+		return nil, false
+	}
+	for ex, n := range filled {
+		metrics.ArchiveFilled.WithLabelValues(ex, m.String()).Add(float64(n))
+	}
+	if len(put) > 0 {
+		log.Printf("archiver [%s]: filled %s: %d candles", m, span(from, to), len(put))
+	}
+	return left, true
+}
+
+// verify re-fetches the last window of every market and rewrites the
+// stored candles that differ from what the exchanges now report.  This also
+// replaces live periods that were force-closed before every exchange
+// confirmed them.
+func (a *archiver) verify(ctx context.Context) {
+	if a.window == 0 {
+		return
+	}
+	for _, m := range a.markets {
+		if ctx.Err() != nil {
+			return
+		}
+		end := m.end()
+		start := max(m.ceil(end-a.window.Milliseconds()), m.ceil(a.start))
+		if start >= end {
+			continue
+		}
+		a.verifyRange(m, start, end)
+	}
+}
+
+func (a *archiver) verifyRange(m *market, start, end int64) {
+	periods, err := a.agg.BackfillPeriods(m.symbol, m.interval, time.UnixMilli(start), time.UnixMilli(end))
+	if err != nil {
+		log.Printf("warn: archiver [%s]: verify %s: %v", m, span(start, end), err)
+		return
+	}
+	fresh := make(map[string][]candle.Candle, len(m.series))
+	for _, p := range periods {
+		if p.Aggregated.OpenTime < start || p.Aggregated.OpenTime >= end {
+			continue
+		}
+		for _, c := range append([]candle.Candle{p.Aggregated}, p.Exchanges...) {
+			fresh[c.Exchange] = append(fresh[c.Exchange], c)
+		}
+	}
+
+	var put []candle.Candle
+	revised := make(map[string]int, len(m.series))
+	for _, ex := range m.series {
+		stored, err := a.st.Range(a.seriesOf(m, ex), start, end)
+		if err != nil {
+			log.Printf("warn: archiver [%s]: %v", m, err)
+			return
+		}
+		old := make(map[int64]candle.Candle, len(stored))
+		for _, c := range stored {
+			old[c.OpenTime] = c
+		}
+		for _, c := range fresh[ex] {
+			prev, ok := old[c.OpenTime]
+			if ok && sameValues(&prev, &c) {
+				continue
+			}
+			if ok {
+				// Keep the Seq the aggregator published it under, so resume
+				// tokens stay valid, and store it as the next revision.
+				c.Seq = prev.Seq
+				c.Revision = prev.Revision + 1
+				revised[ex]++
+				log.Printf("archiver [%s]: %s revised %s: o=%s h=%s l=%s c=%s v=%s (was o=%s h=%s l=%s c=%s v=%s)",
+					m, ex, time.UnixMilli(c.OpenTime).UTC().Format(time.RFC3339),
+					c.Open, c.High, c.Low, c.Close, c.Volume,
+					prev.Open, prev.High, prev.Low, prev.Close, prev.Volume)
+			}
+			put = append(put, c)
+			delete(a.state(m, ex).unavailable, c.OpenTime)
+		}
+	}
+	if err := a.st.Put(put); err != nil {
+		log.Printf("warn: archiver [%s]: %v", m, err)
+		return
+	}
+	for ex, n := range revised {
+		metrics.ArchiveRevised.WithLabelValues(ex, m.String()).Add(float64(n))
+	}
+}
+
+// sameValues reports whether x and y carry the same prices and volume.
+// Values are compared as numbers because REST and WebSocket feeds may format
+// them differently.
+func sameValues(x, y *candle.Candle) bool {
+	pairs := [][2]string{
+		{x.Open, y.Open}, {x.High, y.High}, {x.Low, y.Low}, {x.Close, y.Close}, {x.Volume, y.Volume},
+	}
+	for _, p := range pairs {
+		a, errA := strconv.ParseFloat(p[0], 64)
+		b, errB := strconv.ParseFloat(p[1], 64)
+		if errA != nil || errB != nil || a != b {
+			return false
+		}
+	}
+	return true
+}
+
+// report logs and exports the coverage of every market and series.  Only
+// the periods from where the series is complete on are read from the store;
+// every one before is stored unless unavailable.
+func (a *archiver) report() {
+	for _, m := range a.markets {
+		start, end := m.ceil(a.start), m.end()
+		if start >= end {
+			continue
+		}
+		expected := (end - start) / m.dur
+		for _, ex := range m.series {
+			s, ss := a.seriesOf(m, ex), a.state(m, ex)
+			complete := min(ss.complete, end)
+			tail, err := a.st.OpenTimes(s, complete, end)
+			if err == nil {
+				tail = slices.DeleteFunc(tail, func(t int64) bool { return m.floor(t) != t })
+			}
+			var last []candle.Candle
+			if err == nil {
+				last, err = a.st.Last(s, end, 1)
+			}
+			if err != nil {
+				log.Printf("warn: archiver [%s]: %v", m, err)
+				continue
+			}
+			stored := (complete-start)/m.dur + int64(len(tail))
+			for t := range ss.unavailable {
+				if t >= start && t < complete {
+					stored--
+				}
+			}
+			ratio := float64(stored) / float64(expected)
+			metrics.ArchiveCoverage.WithLabelValues(ex, m.String()).Set(ratio)
+			metrics.ArchiveMissing.WithLabelValues(ex, m.String()).Set(float64(expected - stored))
+
+			first, lastTime := "-", "-"
+			switch {
+			case ss.first >= 0:
+				first = time.UnixMilli(ss.first).UTC().Format(time.RFC3339)
+			case len(tail) > 0:
+				first = time.UnixMilli(tail[0]).UTC().Format(time.RFC3339)
+			}
+			if len(last) > 0 && last[0].OpenTime >= start {
+				lastTime = time.UnixMilli(last[0].OpenTime).UTC().Format(time.RFC3339)
+			}
+			log.Printf("coverage [%s] %s: %d/%d (%.2f%%), missing %d (%d unavailable), first %s, last %s",
+				m, ex, stored, expected, 100*ratio, expected-stored, len(ss.unavailable), first, lastTime)
+		}
+		log.Printf("coverage [%s]: %d live periods since start", m, m.live.Load())
+	}
+}
+
+// span formats [from, to) for logs.
+func span(from, to int64) string {
+	return time.UnixMilli(from).UTC().Format(time.RFC3339) + "–" + time.UnixMilli(to).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/yitech/candles/adapter"
+	"github.com/yitech/candles/aggregator"
+	"github.com/yitech/candles/metrics"
+	"github.com/yitech/candles/model/candle"
+	"github.com/yitech/candles/store"
+)
+
+// fakeExchange serves flat closed candles of every period from listed on.
+type fakeExchange struct {
+	name      string
+	listed    int64
+	backfills atomic.Int32
+}
+
+func (f *fakeExchange) Name() string { return f.name }
+
+func (f *fakeExchange) Subscribe(string, string, adapter.CandleHandler) (adapter.Token, error) {
+	return nopToken{}, nil
+}
+
+func (f *fakeExchange) Backfill(symbol, interval string, start, end time.Time) ([]*candle.Candle, error) {
+	f.backfills.Add(1)
+	d, err := candle.IntervalDuration(interval)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixMilli()
+	var out []*candle.Candle
+	for t := max(candle.PeriodStart(start.UnixMilli(), d), f.listed); t <= end.UnixMilli() && t+d.Milliseconds() <= now; t += d.Milliseconds() {
+		out = append(out, &candle.Candle{
+			Exchange: f.name, Symbol: symbol, Interval: interval, OpenTime: t, CloseTime: t + d.Milliseconds() - 1,
+			Open: "1", High: "1", Low: "1", Close: "1", Volume: "1", IsClosed: true,
+		})
+	}
+	return out, nil
+}
+
+func (f *fakeExchange) Status() []adapter.SubscriptionStatus { return nil }
+func (f *fakeExchange) Close() error                         { return nil }
+
+type nopToken struct{}
+
+func (nopToken) Unsubscribe() {}
+
+// newTestArchiver archives BTCUSDT at interval from start, from exchanges
+// a and b, in a fresh store.
+func newTestArchiver(t *testing.T, interval string, start time.Time, a, b *fakeExchange) (*archiver, store.Store) {
+	t.Helper()
+	st, err := store.OpenBolt(filepath.Join(t.TempDir(), "archive.db"), store.BoltOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	cfg := defaultConfig()
+	cfg.Start = start.UTC().Format(time.RFC3339)
+	cfg.Exchanges = []string{a.name, b.name}
+	cfg.Markets = []MarketConfig{{Symbol: "BTCUSDT", Intervals: []string{interval}}}
+	agg := aggregator.NewWithConfig(aggregator.Config{}, a, b)
+	t.Cleanup(func() { agg.Close() })
+	return newArchiver(cfg, st, agg), st
+}
+
+func stored(t *testing.T, st store.Store, m *market, ex string) int {
+	t.Helper()
+	times, err := st.OpenTimes(store.Series{Exchange: ex, Symbol: m.symbol, Interval: m.interval}, 0, 1<<62)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(times)
+}
+
+func TestArchiverScan(t *testing.T) {
+	today := time.Now().UTC().Truncate(day)
+	start := today.Add(-60 * day)
+	a := &fakeExchange{name: "a"}
+	b := &fakeExchange{name: "b", listed: start.Add(30 * day).UnixMilli()}
+	arch, st := newTestArchiver(t, "1d", start, a, b)
+	m := arch.markets[0]
+	ctx := context.Background()
+
+	arch.scan(ctx)
+	for ex, want := range map[string]int{"a": 60, "b": 30, aggregator.AggregatedExchange: 60} {
+		if n := stored(t, st, m, ex); n != want {
+			t.Errorf("%s: %d periods stored, want %d", ex, n, want)
+		}
+		if ss := arch.state(m, ex); ss.complete != m.end() {
+			t.Errorf("%s: complete up to %d, want %d", ex, ss.complete, m.end())
+		}
+	}
+	if n := len(arch.state(m, "b").unavailable); n != 30 {
+		t.Errorf("b: %d periods unavailable, want 30", n)
+	}
+
+	// A scan between full scans reads only past the complete periods, so
+	// it neither finds anything to fill nor sees periods deleted before.
+	calls := a.backfills.Load()
+	if _, err := st.Prune("1d", start.Add(10*day).UnixMilli()); err != nil {
+		t.Fatal(err)
+	}
+	arch.scan(ctx)
+	if a.backfills.Load() != calls {
+		t.Errorf("incremental scan backfilled %d times, want none", a.backfills.Load()-calls)
+	}
+	if n := stored(t, st, m, "a"); n != 50 {
+		t.Errorf("a: %d periods stored after pruning, want 50", n)
+	}
+
+	// A full scan does.
+	arch.lastFull = time.Time{}
+	arch.scan(ctx)
+	if n := stored(t, st, m, "a"); n != 60 {
+		t.Errorf("a: %d periods stored after a full scan, want 60", n)
+	}
+
+	// Unavailable periods are requested again once they expire.
+	ss := arch.state(m, "b")
+	for t := range ss.unavailable {
+		ss.unavailable[t] = time.Now().Add(-unavailableRetry)
+	}
+	calls = b.backfills.Load()
+	arch.scan(ctx)
+	if b.backfills.Load() == calls {
+		t.Error("expired unavailable periods were not requested again")
+	}
+	if n := len(ss.unavailable); n != 30 {
+		t.Errorf("b: %d periods unavailable after retrying, want 30", n)
+	}
+
+	arch.report()
+	for ex, want := range map[string]float64{"a": 0, "b": 30, aggregator.AggregatedExchange: 0} {
+		if got := testutil.ToFloat64(metrics.ArchiveMissing.WithLabelValues(ex, m.String())); got != want {
+			t.Errorf("%s: %v periods reported missing, want %v", ex, got, want)
+		}
+	}
+}
+
+// TestArchiverUnavailableCap checks that a series remembers at most
+// maxUnavailable periods without data, and scans from the first of those
+// it does not.
+func TestArchiverUnavailableCap(t *testing.T) {
+	start := time.Now().UTC().Truncate(time.Minute).Add(-time.Duration(maxUnavailable+500) * time.Minute)
+	a := &fakeExchange{name: "a"}
+	b := &fakeExchange{name: "b", listed: 1 << 62} // never listed
+	arch, _ := newTestArchiver(t, "1m", start, a, b)
+	m := arch.markets[0]
+	ctx := context.Background()
+
+	arch.scan(ctx)
+	ss := arch.state(m, "b")
+	if n := len(ss.unavailable); n != maxUnavailable {
+		t.Fatalf("%d periods unavailable, want %d", n, maxUnavailable)
+	}
+	if want := start.UnixMilli() + maxUnavailable*m.dur; ss.complete != want {
+		t.Errorf("complete up to %d, want %d", ss.complete, want)
+	}
+
+	calls := b.backfills.Load()
+	arch.scan(ctx)
+	if b.backfills.Load() == calls {
+		t.Error("periods past the cap were not requested again")
+	}
+	if n := len(ss.unavailable); n != maxUnavailable {
+		t.Errorf("%d periods unavailable after another scan, want %d", n, maxUnavailable)
+	}
+
+	arch.report()
+	if got, want := testutil.ToFloat64(metrics.ArchiveMissing.WithLabelValues("b", m.String())), float64((m.end()-start.UnixMilli())/m.dur); got != want {
+		t.Errorf("b: %v periods reported missing, want %v", got, want)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/yitech/candles/adapter"
+	"github.com/yitech/candles/adapter/binance"
+	"github.com/yitech/candles/adapter/bybit"
+	"github.com/yitech/candles/adapter/okx"
+	"github.com/yitech/candles/aggregator"
+	"github.com/yitech/candles/model/candle"
+)
+
+// Config is the archiver configuration, read from the YAML file given by
+// -config.  Flags override the file.
+type Config struct {
+	// Store is the bbolt file the archive is kept in.
+	Store string `yaml:"store"`
+
+	// Start is the first day (YYYY-MM-DD, UTC) or instant (RFC 3339) the
+	// archive must cover.
+	Start string `yaml:"start"`
+
+	Exchanges []string       `yaml:"exchanges"`
+	Markets   []MarketConfig `yaml:"markets"`
+
+	// ScanInterval is how often the archive is scanned for holes: each
+	// series from its first known hole on, and all of it every
+	// FullScanInterval.
+	ScanInterval     time.Duration `yaml:"scan_interval"`
+	FullScanInterval time.Duration `yaml:"full_scan_interval"`
+
+	Verify VerifyConfig `yaml:"verify"`
+
+	// ReportInterval is how often coverage is logged and exported.
+	ReportInterval time.Duration `yaml:"report_interval"`
+
+	Metrics MetricsConfig `yaml:"metrics"`
+}
+
+// MarketConfig is one symbol and the intervals archived for it.  Exchanges,
+// Quorum and Weights are as in cmd/srv's markets.symbols, and must be the
+// same on every entry of a symbol.
+type MarketConfig struct {
+	Symbol    string   `yaml:"symbol"`
+	Intervals []string `yaml:"intervals"`
+
+	Exchanges []string           `yaml:"exchanges"` // default: all of them
+	Quorum    int                `yaml:"quorum"`
+	Weights   map[string]float64 `yaml:"weights"`
+}
+
+// VerifyConfig controls re-fetching of recent periods, which exchanges may
+// still revise and which live candles may have stored incomplete.
+type VerifyConfig struct {
+	Window   time.Duration `yaml:"window"`   // how far back from now
+	Interval time.Duration `yaml:"interval"` // how often
+}
+
+// MetricsConfig controls the Prometheus endpoint.
+type MetricsConfig struct {
+	Listen string `yaml:"listen"` // HTTP listen address; empty disables it
+	Path   string `yaml:"path"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		Exchanges:        []string{"binance", "bybit", "okx"},
+		ScanInterval:     time.Hour,
+		FullScanInterval: 24 * time.Hour,
+		Verify:           VerifyConfig{Window: 3 * time.Hour, Interval: 15 * time.Minute},
+		ReportInterval:   15 * time.Minute,
+		Metrics:          MetricsConfig{Path: "/metrics"},
+	}
+}
+
+// loadConfig reads the config file named by -config and applies the
+// remaining flags, then validates the result.
+func loadConfig(args []string) (Config, error) {
+	cfg := defaultConfig()
+
+	fs := flag.NewFlagSet("archiver", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv("CANDLES_ARCHIVER_CONFIG"), "path to the YAML config file (required)")
+	storePath := fs.String("store", "", "archive store file")
+	start := fs.String("start", "", "start of the archive: YYYY-MM-DD or RFC 3339")
+	metricsListen := fs.String("metrics-listen", "", "Prometheus endpoint address")
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+	if fs.NArg() > 0 {
+		return cfg, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if *path == "" {
+		return cfg, errors.New("-config is required")
+	}
+	if err := readConfigFile(*path, &cfg); err != nil {
+		return cfg, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "store":
+			cfg.Store = *storePath
+		case "start":
+			cfg.Start = *start
+		case "metrics-listen":
+			cfg.Metrics.Listen = *metricsListen
+		}
+	})
+	return cfg, cfg.validate()
+}
+
+func readConfigFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && err != io.EOF {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	return nil
+}
+
+// validate reports every problem with cfg at once.
+func (cfg *Config) validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if cfg.Store == "" {
+		fail("store: is required")
+	}
+	if cfg.Start == "" {
+		fail("start: is required")
+	} else if t, err := parseTime(cfg.Start); err != nil {
+		fail("start: %v", err)
+	} else if !t.Before(time.Now()) {
+		fail("start: %s is in the future", cfg.Start)
+	}
+
+	if len(cfg.Exchanges) == 0 {
+		fail("exchanges: at least one exchange is required")
+	}
+	seen := make(map[string]bool)
+	for i, name := range cfg.Exchanges {
+		name = strings.ToLower(strings.TrimSpace(name))
+		cfg.Exchanges[i] = name
+		switch {
+		case name != "binance" && name != "bybit" && name != "okx":
+			fail("exchanges: unknown exchange %q", name)
+		case seen[name]:
+			fail("exchanges: %s listed twice", name)
+		}
+		seen[name] = true
+	}
+
+	if len(cfg.Markets) == 0 {
+		fail("markets: at least one market is required")
+	}
+	bySymbol := make(map[string]MarketConfig)
+	for i, m := range cfg.Markets {
+		if m.Symbol == "" {
+			fail("markets[%d].symbol: is required", i)
+		}
+		for _, name := range m.Exchanges {
+			if !seen[name] {
+				fail("markets[%d].exchanges: %s is not in exchanges", i, name)
+			}
+		}
+		if n := len(m.exchanges(cfg.Exchanges)); m.Quorum < 0 || m.Quorum > n {
+			fail("markets[%d].quorum: must be between 1 and %d, got %d", i, n, m.Quorum)
+		}
+		for name, w := range m.Weights {
+			if !slices.Contains(m.exchanges(cfg.Exchanges), name) {
+				fail("markets[%d].weights: %s does not take part", i, name)
+			} else if w < 0 {
+				fail("markets[%d].weights.%s: must not be negative", i, name)
+			}
+		}
+		if prev, ok := bySymbol[m.Symbol]; ok && !m.sameAggregation(prev) {
+			fail("markets[%d]: %s is aggregated differently in another entry", i, m.Symbol)
+		}
+		bySymbol[m.Symbol] = m
+		if len(m.Intervals) == 0 {
+			fail("markets[%d].intervals: at least one interval is required", i)
+		}
+		for _, iv := range m.Intervals {
+			if _, err := candle.IntervalDuration(iv); err != nil {
+				fail("markets[%d].intervals: %v", i, err)
+			}
+		}
+	}
+
+	if cfg.ScanInterval <= 0 {
+		fail("scan_interval: must be positive")
+	}
+	if cfg.FullScanInterval <= 0 {
+		fail("full_scan_interval: must be positive")
+	}
+	if cfg.Verify.Window < 0 {
+		fail("verify.window: must not be negative")
+	}
+	if cfg.Verify.Interval <= 0 {
+		fail("verify.interval: must be positive")
+	}
+	if cfg.ReportInterval <= 0 {
+		fail("report_interval: must be positive")
+	}
+	if cfg.Metrics.Listen != "" && !strings.HasPrefix(cfg.Metrics.Path, "/") {
+		fail("metrics.path: %q must start with /", cfg.Metrics.Path)
+	}
+	return errors.Join(errs...)
+}
+
+// exchanges returns the exchanges taking part in m, out of all.
+func (m MarketConfig) exchanges(all []string) []string {
+	if len(m.Exchanges) == 0 {
+		return all
+	}
+	return m.Exchanges
+}
+
+// sameAggregation reports whether m and o configure the aggregation alike.
+func (m MarketConfig) sameAggregation(o MarketConfig) bool {
+	return slices.Equal(m.Exchanges, o.Exchanges) && m.Quorum == o.Quorum && maps.Equal(m.Weights, o.Weights)
+}
+
+// aggregatorMarkets returns the per-symbol settings for the aggregator.
+func (cfg *Config) aggregatorMarkets() map[string]aggregator.MarketConfig {
+	out := make(map[string]aggregator.MarketConfig)
+	for _, m := range cfg.Markets {
+		out[m.Symbol] = aggregator.MarketConfig{Exchanges: m.Exchanges, Quorum: m.Quorum, Weights: m.Weights}
+	}
+	return out
+}
+
+// parseTime accepts a UTC date or an RFC 3339 timestamp.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// adapters builds the configured exchange adapters.  As with cmd/srv, the
+// endpoints can be overridden with CANDLES_<EXCHANGE>_REST_URL and
+// CANDLES_<EXCHANGE>_WS_URL.
+func (cfg *Config) adapters() []adapter.Adapter {
+	out := make([]adapter.Adapter, 0, len(cfg.Exchanges))
+	for _, name := range cfg.Exchanges {
+		prefix := "CANDLES_" + strings.ToUpper(name) + "_"
+		restURL, wsURL := os.Getenv(prefix+"REST_URL"), os.Getenv(prefix+"WS_URL")
+		switch name {
+		case "binance":
+			out = append(out, binance.NewWithOptions(binance.Options{RESTURL: restURL, WSURL: wsURL}))
+		case "bybit":
+			out = append(out, bybit.NewWithOptions(bybit.Options{RESTURL: restURL, WSURL: wsURL}))
+		case "okx":
+			out = append(out, okx.NewWithOptions(okx.Options{RESTURL: restURL, WSURL: wsURL}))
+		}
+	}
+	return out
+}
@@ -0,0 +1,93 @@
+// Command archiver keeps a local candle store complete.
+//
+//	archiver -config archiver.example.yaml
+//
+// For every configured market and interval it backfills each exchange's
+// candles and the aggregated candle from the start date up to now, then
+// keeps going: live periods are stored by the aggregator as they close, a
+// periodic scan fills any hole (after downtime, or where a live period was
+// lost), and a shorter verify pass re-fetches the most recent window and
+// rewrites candles the exchanges have since revised.  Coverage per market
+// and exchange is logged and exported as Prometheus metrics.
+//
+// The store has the layout cmd/srv uses, so cmd/srv can serve history from
+// an archive (though not while the archiver has it open).
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/yitech/candles/aggregator"
+	"github.com/yitech/candles/store"
+)
+
+func main() {
+	cfg, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	st, err := store.OpenBolt(cfg.Store, store.BoltOptions{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("archive: %s, since %s", cfg.Store, cfg.Start)
+
+	adapters := cfg.adapters()
+	agg := aggregator.NewWithConfig(aggregator.Config{
+		Store:   st,
+		Markets: cfg.aggregatorMarkets(),
+	}, adapters...)
+	arch := newArchiver(cfg, st, agg)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var metricsSrv *http.Server
+	if cfg.Metrics.Listen != "" {
+		metricsSrv = serveMetrics(cfg.Metrics.Listen, cfg.Metrics.Path)
+	}
+
+	arch.follow()
+	arch.run(ctx, cfg.ScanInterval, cfg.Verify.Interval, cfg.ReportInterval)
+	stop() // a second signal kills the process
+
+	log.Printf("shutting down")
+	agg.Close()
+	if err := st.Close(); err != nil {
+		log.Printf("warn: store: %v", err)
+	}
+	for _, ad := range adapters {
+		ad.Close()
+	}
+	if metricsSrv != nil {
+		metricsSrv.Close()
+	}
+}
+
+// serveMetrics exposes the default Prometheus registry on addr/path.
+func serveMetrics(addr, path string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle(path, promhttp.Handler())
+	srv := &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		log.Printf("metrics listening on %s%s", addr, path)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("warn: metrics server: %v", err)
+		}
+	}()
+	return srv
+}
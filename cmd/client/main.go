@@ -2,15 +2,23 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"io"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 
 	pb "github.com/yitech/candles/model/protobuf"
 )
@@ -20,8 +28,14 @@ func main() {
 	symbol   := getEnv("SYMBOL",      "BTCUSDT")
 	interval := getEnv("INTERVAL",    "1m")
 	nKline   := getEnvInt("N_KLINE",  48)
+	policy   := getEnvPolicy("BACKPRESSURE", pb.BackpressurePolicy_BACKPRESSURE_POLICY_UNSPECIFIED)
+	inds     := getEnvList("INDICATORS")
 
-	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	opts, err := dialOptions()
+	if err != nil {
+		log.Fatalf("failed to configure client: %v", err)
+	}
+	conn, err := grpc.NewClient(addr, opts...)
 	if err != nil {
 		log.Fatalf("failed to create client: %v", err)
 	}
@@ -31,11 +45,21 @@ func main() {
 
 	ch := make(chan *pb.Candle, 128)
 	go func() {
+		// resume is the seq of the last candle received; on reconnect the
+		// server replays the closed candles missed in between.
+		var resume uint64
 		for {
-			if err := streamCandles(client, symbol, interval, ch); err != nil {
-				log.Printf("stream error: %v — retrying in 3s", err)
+			err := streamCandles(client, symbol, interval, policy, nKline, inds, &resume, ch)
+			if status.Code(err) == codes.OutOfRange {
+				log.Printf("cannot resume: %v — resubscribing live", err)
+				resume = 0
+				continue
+			}
+			delay := retryDelay(err, 3*time.Second)
+			if err != nil {
+				log.Printf("stream error: %v — retrying in %v", err, delay)
 			}
-			time.Sleep(3 * time.Second)
+			time.Sleep(delay)
 		}
 	}()
 
@@ -48,10 +72,14 @@ func main() {
 	}
 }
 
-func streamCandles(client pb.CandleServiceClient, symbol, interval string, ch chan<- *pb.Candle) error {
+func streamCandles(client pb.CandleServiceClient, symbol, interval string, policy pb.BackpressurePolicy, history int, inds []string, resume *uint64, ch chan<- *pb.Candle) error {
 	stream, err := client.Subscribe(context.Background(), &pb.SubscribeRequest{
-		Symbol:   symbol,
-		Interval: interval,
+		Symbol:       symbol,
+		Interval:     interval,
+		Backpressure: policy,
+		ResumeToken:  *resume,
+		History:      uint32(history),
+		Indicators:   inds,
 	})
 	if err != nil {
 		return err
@@ -64,10 +92,77 @@ func streamCandles(client pb.CandleServiceClient, symbol, interval string, ch ch
 		if err != nil {
 			return err
 		}
+		if c.Dropped > 0 {
+			log.Printf("server dropped %d candles (slow consumer)", c.Dropped)
+		}
+		if c.Seq > *resume {
+			*resume = c.Seq
+		}
 		ch <- c
 	}
 }
 
+// dialOptions sets up TLS and credentials from the environment: TLS_CA_FILE
+// (or TLS=1 for the system roots) turns on TLS, TLS_CERT_FILE and
+// TLS_KEY_FILE add a client certificate for mTLS, and AUTH_TOKEN, an API
+// key or a JWT, is sent as a bearer token with every call.
+func dialOptions() ([]grpc.DialOption, error) {
+	caFile   := os.Getenv("TLS_CA_FILE")
+	certFile := os.Getenv("TLS_CERT_FILE")
+	keyFile  := os.Getenv("TLS_KEY_FILE")
+	useTLS, _ := strconv.ParseBool(os.Getenv("TLS"))
+
+	var opts []grpc.DialOption
+	if useTLS || caFile != "" || certFile != "" {
+		tc := &tls.Config{ServerName: os.Getenv("TLS_SERVER_NAME"), MinVersion: tls.VersionTLS12}
+		if caFile != "" {
+			pem, err := os.ReadFile(caFile)
+			if err != nil {
+				return nil, err
+			}
+			tc.RootCAs = x509.NewCertPool()
+			if !tc.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("%s holds no PEM certificates", caFile)
+			}
+		}
+		if certFile != "" {
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				return nil, err
+			}
+			tc.Certificates = []tls.Certificate{cert}
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tc)))
+	} else {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	if token := os.Getenv("AUTH_TOKEN"); token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(bearerToken(token)))
+	}
+	return opts, nil
+}
+
+// bearerToken sends an API key or JWT in the authorization header. It is
+// allowed over plaintext connections so a local server can require keys.
+type bearerToken string
+
+func (t bearerToken) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t bearerToken) RequireTransportSecurity() bool { return false }
+
+// retryDelay returns the reconnect hint the server attached to err (sent
+// when it shuts down), or fallback.
+func retryDelay(err error, fallback time.Duration) time.Duration {
+	for _, d := range status.Convert(err).Details() {
+		if ri, ok := d.(*errdetails.RetryInfo); ok && ri.RetryDelay != nil {
+			return ri.RetryDelay.AsDuration()
+		}
+	}
+	return fallback
+}
+
 func getEnv(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -83,3 +178,26 @@ func getEnvInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+// getEnvList splits a ";"-separated list such as "ema(50);bb(20,2)".
+func getEnvList(key string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(key), ";") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// getEnvPolicy parses a backpressure policy name such as "conflate" or
+// "drop_oldest".
+func getEnvPolicy(key string, fallback pb.BackpressurePolicy) pb.BackpressurePolicy {
+	if v := os.Getenv(key); v != "" {
+		if p, ok := pb.BackpressurePolicy_value["BACKPRESSURE_POLICY_"+strings.ToUpper(v)]; ok {
+			return pb.BackpressurePolicy(p)
+		}
+		log.Printf("unknown %s=%q, using server default", key, v)
+	}
+	return fallback
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"math"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -100,15 +101,20 @@ func waitForCandle(ch <-chan *pb.Candle) tea.Cmd {
 	}
 }
 
-// addOrUpdate merges into the last candle if openTime matches, else appends.
+// addOrUpdate merges into the candle with the same openTime, else inserts in
+// chronological order (replayed candles can arrive after newer ones).
 func (m *model) addOrUpdate(c *pb.Candle) {
-	if n := len(m.candles); n > 0 && m.candles[n-1].OpenTime == c.OpenTime {
-		m.candles[n-1] = c
-	} else {
-		m.candles = append(m.candles, c)
-		if len(m.candles) > m.nKline {
-			m.candles = m.candles[len(m.candles)-m.nKline:]
-		}
+	i := len(m.candles)
+	for i > 0 && m.candles[i-1].OpenTime > c.OpenTime {
+		i--
+	}
+	if i > 0 && m.candles[i-1].OpenTime == c.OpenTime {
+		m.candles[i-1] = c
+		return
+	}
+	m.candles = slices.Insert(m.candles, i, c)
+	if len(m.candles) > m.nKline {
+		m.candles = m.candles[len(m.candles)-m.nKline:]
 	}
 }
 
@@ -123,12 +129,33 @@ func (m model) renderHeader() string {
 	if c.IsClosed {
 		status = "closed"
 	}
+	if c.Synthetic {
+		status += ", no trades"
+	}
 	return headerStyle.Render(fmt.Sprintf(
 		"%s  %s  [%s]  O:%s  H:%s  L:%s  C:%s  V:%s  %d/%d",
 		m.symbol, m.interval, status,
 		c.Open, c.High, c.Low, c.Close, c.Volume,
 		len(m.candles), m.nKline,
-	))
+	) + renderIndicators(c))
+}
+
+// renderIndicators formats the indicator values carried by c, e.g.
+// "  ema(50)=64012.51  bb(20,2)=63990.10/64120.42/63859.78"; "…" while an
+// indicator is warming up.
+func renderIndicators(c *pb.Candle) string {
+	var b strings.Builder
+	for _, iv := range c.Indicators {
+		vs := make([]string, len(iv.Values))
+		for i, v := range iv.Values {
+			vs[i] = strconv.FormatFloat(v, 'f', 2, 64)
+		}
+		if len(vs) == 0 {
+			vs = []string{"…"}
+		}
+		fmt.Fprintf(&b, "  %s=%s", iv.Name, strings.Join(vs, "/"))
+	}
+	return b.String()
 }
 
 // ── chart ─────────────────────────────────────────────────────────────────────
@@ -226,6 +253,9 @@ func renderCandle(grid [][]string, c *pb.Candle, x, chartH int, hi, lo float64)
 	if !bullish {
 		style = bearStyle
 	}
+	if c.Synthetic {
+		style = wickStyle // no trades: a flat grey bar
+	}
 
 	fH := float64(chartH)
 	bodyTop := priceToRow(math.Max(open, cls), fH, hi, lo)
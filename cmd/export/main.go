@@ -0,0 +1,325 @@
+// Command export backfills candles for one market from the exchanges and
+// writes them as CSV, JSON Lines or Parquet.
+//
+//	export -symbol BTCUSDT -interval 1m -from 2024-01-01 -to 2025-01-01 \
+//	       -format parquet -out ./data
+//
+// The range is fetched one UTC day at a time and a failed day is retried
+// with backoff.  With -out, each day goes to its own file, written
+// atomically, and finished days whose file already exists are skipped, so
+// an interrupted export resumes where it stopped.  Without -out everything is
+// written to stdout.
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yitech/candles/adapter"
+	"github.com/yitech/candles/adapter/binance"
+	"github.com/yitech/candles/adapter/bybit"
+	"github.com/yitech/candles/adapter/okx"
+	"github.com/yitech/candles/aggregator"
+	"github.com/yitech/candles/model/candle"
+)
+
+const day = 24 * time.Hour
+
+type options struct {
+	symbol      string
+	interval    string
+	dur         time.Duration
+	from, to    time.Time
+	exchanges   []string
+	perExchange bool
+	gapFill     bool
+	format      string
+	out         string // directory; empty writes to stdout
+	retries     int
+}
+
+func main() {
+	opts, err := parseFlags(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	adapters := make([]adapter.Adapter, 0, len(opts.exchanges))
+	for _, name := range opts.exchanges {
+		adapters = append(adapters, newAdapter(name))
+	}
+	defer func() {
+		for _, ad := range adapters {
+			ad.Close()
+		}
+	}()
+
+	if err := run(opts, adapters); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func parseFlags(args []string) (options, error) {
+	var opts options
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.StringVar(&opts.symbol, "symbol", "BTCUSDT", "market symbol")
+	fs.StringVar(&opts.interval, "interval", "1m", "candle interval")
+	from := fs.String("from", "", "start of the range: YYYY-MM-DD or RFC 3339 (required)")
+	to := fs.String("to", "", "end of the range, exclusive (default now)")
+	exchanges := fs.String("exchanges", "binance,bybit,okx", "comma-separated exchanges to fetch")
+	fs.BoolVar(&opts.perExchange, "per-exchange", false, "write each exchange's candles instead of the aggregate")
+	fs.BoolVar(&opts.gapFill, "gap-fill", false, "add flat synthetic candles for periods no exchange reported")
+	fs.StringVar(&opts.format, "format", "csv", "output format: csv, jsonl or parquet")
+	fs.StringVar(&opts.out, "out", "", "directory for one file per UTC day (default stdout)")
+	fs.IntVar(&opts.retries, "retries", 3, "attempts per day before giving up")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+
+	var errs []error
+	var err error
+	if opts.dur, err = candle.IntervalDuration(opts.interval); err != nil {
+		errs = append(errs, fmt.Errorf("-interval: %w", err))
+	}
+	if *from == "" {
+		errs = append(errs, errors.New("-from is required"))
+	} else if opts.from, err = parseTime(*from); err != nil {
+		errs = append(errs, fmt.Errorf("-from: %w", err))
+	}
+	opts.to = time.Now()
+	if *to != "" {
+		if opts.to, err = parseTime(*to); err != nil {
+			errs = append(errs, fmt.Errorf("-to: %w", err))
+		}
+	}
+	if !opts.from.IsZero() && !opts.to.After(opts.from) {
+		errs = append(errs, errors.New("-to must be after -from"))
+	}
+	for _, name := range strings.Split(*exchanges, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "":
+		case "binance", "bybit", "okx":
+			opts.exchanges = append(opts.exchanges, name)
+		default:
+			errs = append(errs, fmt.Errorf("-exchanges: unknown exchange %q", name))
+		}
+	}
+	if len(opts.exchanges) == 0 {
+		errs = append(errs, errors.New("-exchanges: at least one exchange is required"))
+	}
+	if _, ok := formats[opts.format]; !ok {
+		errs = append(errs, fmt.Errorf("-format: unknown format %q", opts.format))
+	}
+	if opts.gapFill && opts.perExchange {
+		errs = append(errs, errors.New("-gap-fill applies to the aggregate only, not -per-exchange"))
+	}
+	if opts.retries < 1 {
+		errs = append(errs, errors.New("-retries must be at least 1"))
+	}
+	return opts, errors.Join(errs...)
+}
+
+// parseTime accepts a UTC date or an RFC 3339 timestamp.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// newAdapter builds the named adapter.  As with cmd/srv, the REST endpoint
+// can be overridden with CANDLES_<EXCHANGE>_REST_URL.
+func newAdapter(name string) adapter.Adapter {
+	restURL := os.Getenv("CANDLES_" + strings.ToUpper(name) + "_REST_URL")
+	switch name {
+	case "binance":
+		return binance.NewWithOptions(binance.Options{RESTURL: restURL})
+	case "bybit":
+		return bybit.NewWithOptions(bybit.Options{RESTURL: restURL})
+	default:
+		return okx.NewWithOptions(okx.Options{RESTURL: restURL})
+	}
+}
+
+// run exports [opts.from, opts.to) one UTC day at a time.
+func run(opts options, adapters []adapter.Adapter) error {
+	agg := aggregator.NewWithConfig(aggregator.Config{GapFill: opts.gapFill}, adapters...)
+
+	var stdout sink
+	if opts.out == "" {
+		var err error
+		if stdout, err = formats[opts.format](os.Stdout); err != nil {
+			return err
+		}
+	} else if err := os.MkdirAll(opts.out, 0o755); err != nil {
+		return err
+	}
+
+	total := 0
+	for d := opts.from.UTC().Truncate(day); d.Before(opts.to); d = d.Add(day) {
+		start, end := maxTime(d, opts.from), minTime(d.Add(day), opts.to)
+
+		var path string
+		if opts.out != "" {
+			path = filepath.Join(opts.out, fileName(opts, d))
+			// Only a whole, finished day is final; partial days are
+			// rewritten on every run.
+			whole := start.Equal(d) && end.Equal(d.Add(day)) && !end.After(time.Now())
+			if _, err := os.Stat(path); err == nil && whole {
+				log.Printf("%s: exists, skipping", path)
+				continue
+			}
+		}
+
+		cs, err := fetchDay(opts, agg, adapters, start, end)
+		if err != nil {
+			return fmt.Errorf("%s: %w", d.Format(time.DateOnly), err)
+		}
+
+		if path == "" {
+			err = writeAll(stdout, cs)
+		} else {
+			err = writeFile(path, opts.format, cs)
+		}
+		if err != nil {
+			return err
+		}
+		total += len(cs)
+		log.Printf("%s: %d candles", d.Format(time.DateOnly), len(cs))
+	}
+
+	if stdout != nil {
+		if err := stdout.Close(); err != nil {
+			return err
+		}
+	}
+	log.Printf("exported %d candles", total)
+	return nil
+}
+
+// fetchDay backfills [start, end), retrying with backoff, and returns the
+// closed candles sorted by open time (then exchange).
+func fetchDay(opts options, agg *aggregator.Aggregator, adapters []adapter.Adapter, start, end time.Time) ([]candle.Candle, error) {
+	backoff := 2 * time.Second
+	var err error
+	for attempt := 1; ; attempt++ {
+		var cs []candle.Candle
+		if cs, err = fetch(opts, agg, adapters, start, end); err == nil {
+			return cs, nil
+		}
+		if attempt == opts.retries {
+			return nil, err
+		}
+		log.Printf("warn: %v: retrying in %v", err, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func fetch(opts options, agg *aggregator.Aggregator, adapters []adapter.Adapter, start, end time.Time) ([]candle.Candle, error) {
+	var raw []*candle.Candle
+	if opts.perExchange {
+		for _, ad := range adapters {
+			batch, err := ad.Backfill(opts.symbol, opts.interval, start, end)
+			if err != nil {
+				return nil, err
+			}
+			raw = append(raw, batch...)
+		}
+	} else {
+		var err error
+		if raw, err = agg.Backfill(opts.symbol, opts.interval, start, end); err != nil {
+			return nil, err
+		}
+	}
+
+	// Exchanges treat the end bound inclusively and may return the period
+	// still in progress; keep only closed candles inside the range.
+	now := time.Now().UnixMilli()
+	out := make([]candle.Candle, 0, len(raw))
+	for _, c := range raw {
+		if c.OpenTime < start.UnixMilli() || c.OpenTime >= end.UnixMilli() || c.OpenTime+opts.dur.Milliseconds() > now {
+			continue
+		}
+		cc := *c
+		cc.IsClosed = true
+		out = append(out, cc)
+	}
+	slices.SortFunc(out, func(x, y candle.Candle) int {
+		if c := cmp.Compare(x.OpenTime, y.OpenTime); c != 0 {
+			return c
+		}
+		return strings.Compare(x.Exchange, y.Exchange)
+	})
+	return out, nil
+}
+
+// fileName is the per-day output file, e.g. "BTCUSDT_1m_2024-01-01.csv" or
+// "BTCUSDT_1m_exchanges_2024-01-01.csv" with -per-exchange.
+func fileName(opts options, d time.Time) string {
+	kind := ""
+	if opts.perExchange {
+		kind = "_exchanges"
+	}
+	return fmt.Sprintf("%s_%s%s_%s.%s", opts.symbol, opts.interval, kind, d.Format(time.DateOnly), opts.format)
+}
+
+// writeFile writes cs to path via a temporary file, so a day file exists
+// only once it is complete.
+func writeFile(path, format string, cs []candle.Candle) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	s, err := formats[format](f)
+	if err == nil {
+		err = writeAll(s, cs)
+	}
+	if err == nil {
+		err = s.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func writeAll(s sink, cs []candle.Candle) error {
+	for i := range cs {
+		if err := s.Write(&cs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
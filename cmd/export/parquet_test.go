@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+
+	"github.com/yitech/candles/model/candle"
+)
+
+// TestParquetRoundTrip writes candles spanning several row groups through
+// the Parquet sink and reads them back.
+func TestParquetRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewPCG(35, 0))
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	exchanges := []string{"binance", "okx", "bybit", "aggregated", ""}
+	candles := make([]candle.Candle, 300_000)
+	for i := range candles {
+		open := rng.Float64() * 1e5
+		candles[i] = candle.Candle{
+			Exchange:  exchanges[rng.IntN(len(exchanges))],
+			Symbol:    []string{"BTCUSDT", "ETH-USDT", "ÄÖÜ"}[rng.IntN(3)],
+			Interval:  "1m",
+			OpenTime:  1_700_000_040_000 + int64(i)*60_000,
+			Open:      f(open),
+			High:      f(open * (1 + rng.Float64()/100)),
+			Low:       f(open * (1 - rng.Float64()/100)),
+			Close:     f(open * (1 + rng.NormFloat64()/200)),
+			Volume:    f(rng.ExpFloat64()),
+			CloseTime: 1_700_000_040_000 + int64(i)*60_000 + 59_999,
+			Synthetic: rng.IntN(5) == 0,
+		}
+	}
+	candles[0].Volume = "0"
+	candles[1].Open, candles[1].High = f(math.MaxFloat64), f(math.SmallestNonzeroFloat64)
+
+	var buf bytes.Buffer
+	s, err := newParquetSink(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range candles {
+		if err := s.Write(&candles[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	pr, err := reader.NewParquetReader(&memFile{bytes.NewReader(buf.Bytes())}, new(parquetRow), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.ReadStop()
+	if got := pr.GetNumRows(); got != int64(len(candles)) {
+		t.Fatalf("%d rows, want %d", got, len(candles))
+	}
+	if got := len(pr.Footer.RowGroups); got < 2 {
+		t.Errorf("%d row groups, want several", got)
+	}
+	for _, el := range pr.Footer.Schema[1:] {
+		if el.GetRepetitionType() != parquet.FieldRepetitionType_REQUIRED {
+			t.Errorf("column %s is %v, want REQUIRED", el.GetName(), el.GetRepetitionType())
+		}
+	}
+
+	rows := make([]parquetRow, len(candles))
+	if err := pr.Read(&rows); err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range candles {
+		want := parquetRow{
+			Exchange:  c.Exchange,
+			Symbol:    c.Symbol,
+			Interval:  c.Interval,
+			OpenTime:  c.OpenTime,
+			Open:      parseFloat(c.Open),
+			High:      parseFloat(c.High),
+			Low:       parseFloat(c.Low),
+			Close:     parseFloat(c.Close),
+			Volume:    parseFloat(c.Volume),
+			CloseTime: c.CloseTime,
+			Synthetic: c.Synthetic,
+		}
+		if rows[i] != want {
+			t.Fatalf("row %d: got %+v, want %+v", i, rows[i], want)
+		}
+	}
+	if !slices.ContainsFunc(rows, func(r parquetRow) bool { return r.Synthetic }) {
+		t.Fatal("no synthetic row read back")
+	}
+}
+
+// memFile is a read-only source.ParquetFile in memory.
+type memFile struct{ *bytes.Reader }
+
+func (f *memFile) Open(string) (source.ParquetFile, error) {
+	r := *f.Reader
+	return &memFile{&r}, nil
+}
+
+func (f *memFile) Create(string) (source.ParquetFile, error) { return nil, errors.ErrUnsupported }
+func (f *memFile) Write([]byte) (int, error)                 { return 0, errors.ErrUnsupported }
+func (f *memFile) Close() error                              { return nil }
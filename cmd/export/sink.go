@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+
+	"github.com/yitech/candles/model/candle"
+)
+
+// sink writes candles in one output format.  Close flushes it but does not
+// close the underlying writer.
+type sink interface {
+	Write(c *candle.Candle) error
+	Close() error
+}
+
+// formats maps a -format name to its sink constructor.
+var formats = map[string]func(io.Writer) (sink, error){
+	"csv":     newCSVSink,
+	"jsonl":   newJSONLSink,
+	"parquet": newParquetSink,
+}
+
+// ── CSV ──────────────────────────────────────────────────────────────────────
+
+type csvSink struct{ w *csv.Writer }
+
+var csvHeader = []string{
+	"exchange", "symbol", "interval", "open_time", "open", "high", "low", "close", "volume", "close_time", "synthetic",
+}
+
+func newCSVSink(w io.Writer) (sink, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	return &csvSink{w: cw}, nil
+}
+
+func (s *csvSink) Write(c *candle.Candle) error {
+	return s.w.Write([]string{
+		c.Exchange, c.Symbol, c.Interval,
+		strconv.FormatInt(c.OpenTime, 10),
+		c.Open, c.High, c.Low, c.Close, c.Volume,
+		strconv.FormatInt(c.CloseTime, 10),
+		strconv.FormatBool(c.Synthetic),
+	})
+}
+
+func (s *csvSink) Close() error {
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// ── JSON Lines ───────────────────────────────────────────────────────────────
+
+type jsonlSink struct{ enc *json.Encoder }
+
+// jsonCandle is the JSON Lines record; prices stay strings as reported by
+// the exchanges.
+type jsonCandle struct {
+	Exchange  string `json:"exchange"`
+	Symbol    string `json:"symbol"`
+	Interval  string `json:"interval"`
+	OpenTime  int64  `json:"open_time"`
+	Open      string `json:"open"`
+	High      string `json:"high"`
+	Low       string `json:"low"`
+	Close     string `json:"close"`
+	Volume    string `json:"volume"`
+	CloseTime int64  `json:"close_time"`
+	Synthetic bool   `json:"synthetic"`
+}
+
+func newJSONLSink(w io.Writer) (sink, error) {
+	return &jsonlSink{enc: json.NewEncoder(w)}, nil
+}
+
+func (s *jsonlSink) Write(c *candle.Candle) error {
+	return s.enc.Encode(jsonCandle{
+		Exchange:  c.Exchange,
+		Symbol:    c.Symbol,
+		Interval:  c.Interval,
+		OpenTime:  c.OpenTime,
+		Open:      c.Open,
+		High:      c.High,
+		Low:       c.Low,
+		Close:     c.Close,
+		Volume:    c.Volume,
+		CloseTime: c.CloseTime,
+		Synthetic: c.Synthetic,
+	})
+}
+
+func (s *jsonlSink) Close() error { return nil }
+
+// ── Parquet ──────────────────────────────────────────────────────────────────
+
+// parquetRow is the Parquet schema: prices and volume are DOUBLE so the
+// files load straight into analysis tools, and times are Unix milliseconds.
+type parquetRow struct {
+	Exchange  string  `parquet:"name=exchange, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Symbol    string  `parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Interval  string  `parquet:"name=interval, type=BYTE_ARRAY, convertedtype=UTF8"`
+	OpenTime  int64   `parquet:"name=open_time, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Open      float64 `parquet:"name=open, type=DOUBLE"`
+	High      float64 `parquet:"name=high, type=DOUBLE"`
+	Low       float64 `parquet:"name=low, type=DOUBLE"`
+	Close     float64 `parquet:"name=close, type=DOUBLE"`
+	Volume    float64 `parquet:"name=volume, type=DOUBLE"`
+	CloseTime int64   `parquet:"name=close_time, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Synthetic bool    `parquet:"name=synthetic, type=BOOLEAN"`
+}
+
+// parquetRowGroup bounds the bytes of rows buffered in memory before they
+// are written as a row group.
+const parquetRowGroup = 16 << 20
+
+type parquetSink struct{ pw *writer.ParquetWriter }
+
+func newParquetSink(w io.Writer) (sink, error) {
+	pw, err := writer.NewParquetWriterFromWriter(w, new(parquetRow), 1)
+	if err != nil {
+		return nil, err
+	}
+	pw.RowGroupSize = parquetRowGroup
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+	return &parquetSink{pw: pw}, nil
+}
+
+func (s *parquetSink) Write(c *candle.Candle) error {
+	return s.pw.Write(parquetRow{
+		Exchange:  c.Exchange,
+		Symbol:    c.Symbol,
+		Interval:  c.Interval,
+		OpenTime:  c.OpenTime,
+		Open:      parseFloat(c.Open),
+		High:      parseFloat(c.High),
+		Low:       parseFloat(c.Low),
+		Close:     parseFloat(c.Close),
+		Volume:    parseFloat(c.Volume),
+		CloseTime: c.CloseTime,
+		Synthetic: c.Synthetic,
+	})
+}
+
+func (s *parquetSink) Close() error { return s.pw.WriteStop() }
+
+func parseFloat(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
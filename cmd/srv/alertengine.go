@@ -0,0 +1,614 @@
+package main
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/yitech/candles/adapter"
+	"github.com/yitech/candles/aggregator"
+	"github.com/yitech/candles/indicators"
+	"github.com/yitech/candles/metrics"
+	"github.com/yitech/candles/model/candle"
+	pb "github.com/yitech/candles/model/protobuf"
+)
+
+// alertRetry is how long the engine waits before subscribing a rule again
+// after the aggregator refused.
+const alertRetry = 30 * time.Second
+
+// minAlertCooldown is the least cooldown a rule or alerts.cooldown may set,
+// so that a threshold rule evaluated on every update cannot fire on each.
+const minAlertCooldown = time.Second
+
+// alertSaveDelay is how long after a rule fires the rules file is written
+// to record it.  Firings within it are written together; a crash loses
+// them, and a rule may then fire again within its cooldown after restart.
+const alertSaveDelay = 5 * time.Second
+
+// Errors returned by the alert engine for bad requests.
+var (
+	errAlertNotFound = errors.New("alerts: no such rule")
+	errInvalidAlert  = errors.New("alerts: invalid rule")
+)
+
+// alertEngine evaluates alert rules on the aggregator's candles.  Each
+// enabled rule holds an aggregator subscription of its own, seeded with
+// enough history for its indicator, so adding or changing a rule never
+// disturbs the others, and counts as a stream of its owner against the
+// client limits.  Rules and their state are only touched under mu;
+// every change is written to path before it takes effect, except when rules
+// last fired, which is written alertSaveDelay after a firing.
+type alertEngine struct {
+	agg      *aggregator.Aggregator
+	limits   *limiter      // nil counts rules against no limits
+	path     string        // rules file; empty keeps rules in memory only
+	cooldown time.Duration // for rules that set none
+	hooks    *webhookSender
+	watchBuf int // per-WatchAlerts-stream buffer
+
+	// saveMu orders writes of the rules file; it is taken before mu.
+	saveMu sync.Mutex
+
+	mu          sync.Mutex
+	rules       map[string]*alertRule
+	watchers    map[uint64]*alertWatcher
+	nextWatcher uint64
+	closed      bool
+	fired       bool        // a rule fired since the rules file was written
+	saveTimer   *time.Timer // pending saveFired; nil if none
+}
+
+// alertRule is a rule and its evaluation state.  A changed rule is a new
+// alertRule; callbacks of the one it replaced find it gone from the engine
+// and do nothing.
+type alertRule struct {
+	*pb.AlertRule
+	ind    *indicators.Indicator // nil compares the close
+	output int                   // index into the indicator's values
+
+	tok     adapter.Token // nil while disabled or not subscribed yet
+	release func()        // ends its count against the limits; nil if none
+
+	prev    float64 // value at the previous evaluation
+	hasPrev bool
+	ref     float64 // value at the previous closed candle
+	hasRef  bool
+}
+
+// alertWatcher is one WatchAlerts stream.
+type alertWatcher struct {
+	ids map[string]bool // empty watches every rule
+	ch  chan *pb.AlertEvent
+}
+
+func newAlertEngine(agg *aggregator.Aggregator, limits *limiter, cfg AlertsConfig, watchBuf int) *alertEngine {
+	allowed := make([]netip.Prefix, len(cfg.WebhookAllowedNetworks))
+	for i, n := range cfg.WebhookAllowedNetworks {
+		allowed[i], _ = netip.ParsePrefix(n) // validated
+	}
+	return &alertEngine{
+		agg:      agg,
+		limits:   limits,
+		path:     cfg.Path,
+		cooldown: cfg.Cooldown,
+		hooks:    newWebhookSender(cfg.WebhookTimeout, cfg.WebhookRetries, allowed),
+		watchBuf: watchBuf,
+		rules:    make(map[string]*alertRule),
+		watchers: make(map[uint64]*alertWatcher),
+	}
+}
+
+// load reads the rules file, if any, and subscribes the enabled rules.
+// They are counted against their owners' limits even where those are
+// exceeded.
+func (e *alertEngine) load() error {
+	if e.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(e.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("alerts: %w", err)
+	}
+	var list pb.ListAlertsResponse
+	if err := protojson.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("alerts: %s: %w", e.path, err)
+	}
+	var rules []*alertRule
+	e.mu.Lock()
+	for _, pr := range list.Rules {
+		r, err := newAlertRule(pr)
+		if err != nil {
+			e.mu.Unlock()
+			return fmt.Errorf("alerts: %s: rule %s: %w", e.path, pr.Id, err)
+		}
+		e.hold(r, false)
+		e.rules[r.Id] = r
+		rules = append(rules, r)
+	}
+	metrics.AlertRules.Set(float64(len(e.rules)))
+	e.mu.Unlock()
+
+	for _, r := range rules {
+		e.activate(r)
+	}
+	return nil
+}
+
+// newAlertRule validates pr and returns its initial state.
+func newAlertRule(pr *pb.AlertRule) (*alertRule, error) {
+	r := &alertRule{AlertRule: pr}
+	if pr.Symbol == "" {
+		return nil, errors.New("symbol: must not be empty")
+	}
+	if _, err := candle.IntervalDuration(pr.Interval); err != nil {
+		return nil, fmt.Errorf("interval: %w", err)
+	}
+	if pr.CooldownMs != 0 && pr.CooldownMs < minAlertCooldown.Milliseconds() {
+		return nil, fmt.Errorf("cooldown_ms: must be 0 for the server's default, or at least %d", minAlertCooldown.Milliseconds())
+	}
+	if pr.WebhookUrl != "" {
+		if err := checkURL(pr.WebhookUrl, "http", "https"); err != nil {
+			return nil, fmt.Errorf("webhook_url: %w", err)
+		}
+	}
+	cond := pr.Condition
+	if cond == nil {
+		return nil, errors.New("condition: missing")
+	}
+	switch cond.Operator {
+	case pb.AlertOperator_ALERT_OPERATOR_ABOVE, pb.AlertOperator_ALERT_OPERATOR_BELOW,
+		pb.AlertOperator_ALERT_OPERATOR_CROSSES_ABOVE, pb.AlertOperator_ALERT_OPERATOR_CROSSES_BELOW:
+	case pb.AlertOperator_ALERT_OPERATOR_MOVES_PERCENT:
+		if cond.Value <= 0 {
+			return nil, errors.New("condition.value: a percent move must be positive")
+		}
+	default:
+		return nil, fmt.Errorf("condition.operator: unsupported operator %s", cond.Operator)
+	}
+	if math.IsNaN(cond.Value) || math.IsInf(cond.Value, 0) {
+		return nil, errors.New("condition.value: must be finite")
+	}
+	if cond.Indicator == "" {
+		if cond.Output != "" {
+			return nil, errors.New("condition.output: set without an indicator")
+		}
+		return r, nil
+	}
+	spec, err := indicators.Parse(cond.Indicator)
+	if err != nil {
+		return nil, fmt.Errorf("condition.indicator: %w", err)
+	}
+	if cond.Output != "" {
+		r.output = slices.Index(spec.Outputs(), cond.Output)
+		if r.output < 0 {
+			return nil, fmt.Errorf("condition.output: %s has no output %q (want one of %v)", spec.Name, cond.Output, spec.Outputs())
+		}
+	}
+	cond.Indicator = spec.String()
+	r.ind = indicators.New(spec)
+	return r, nil
+}
+
+// activate subscribes r to its market unless it is disabled, priming it
+// with history first.  If the aggregator refuses, it tries again after
+// alertRetry for as long as r is current.
+func (e *alertEngine) activate(r *alertRule) {
+	if r.Disabled {
+		return
+	}
+	var seed int
+	if r.ind != nil {
+		dur, _ := candle.IntervalDuration(r.Interval)
+		seed = min(r.ind.Spec().Warmup(dur), maxIndicatorSeed)
+	}
+	opts := aggregator.SubscribeOptions{
+		Seed: max(seed, 1), // the previous close, for crosses and moves
+		OnSeed: func(cs []candle.Candle) {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			for i := range cs {
+				r.observe(&cs[i])
+			}
+		},
+	}
+	tok, err := e.agg.SubscribeWith(r.Symbol, r.Interval, opts, func(c *candle.Candle, _ int) {
+		e.onCandle(r, c)
+	})
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	current := !e.closed && e.rules[r.Id] == r
+	switch {
+	case err != nil && current:
+		log.Printf("warn: alerts: rule %s: subscribe %s:%s: %v (retrying in %v)", r.Id, r.Symbol, r.Interval, err, alertRetry)
+		time.AfterFunc(alertRetry, func() { e.activate(r) })
+	case err != nil:
+	case current:
+		r.tok = tok
+	default:
+		tok.Unsubscribe() // replaced or deleted meanwhile
+	}
+}
+
+// hold counts r, unless it is disabled, against the limits of its owner;
+// with enforce, it fails if they are exhausted (called under lock).
+func (e *alertEngine) hold(r *alertRule, enforce bool) error {
+	if e.limits == nil || r.Disabled {
+		return nil
+	}
+	release, err := e.limits.count(r.Owner, r.Symbol+":"+r.Interval, enforce)
+	if err != nil {
+		return err
+	}
+	r.release = release
+	return nil
+}
+
+// deactivate stops r's deliveries and its count against the limits
+// (called under lock).
+func (r *alertRule) deactivate() {
+	if r.tok != nil {
+		r.tok.Unsubscribe()
+		r.tok = nil
+	}
+	if r.release != nil {
+		r.release()
+		r.release = nil
+	}
+}
+
+// onCandle evaluates r on c and fires it if its condition holds.
+func (e *alertEngine) onCandle(r *alertRule, c *candle.Candle) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed || e.rules[r.Id] != r {
+		return
+	}
+	now := time.Now()
+	fire, x, ref := r.observe(c)
+	cooldown := time.Duration(r.CooldownMs) * time.Millisecond
+	if cooldown == 0 {
+		cooldown = e.cooldown
+	}
+	if !fire || (r.LastFiredAt != 0 && now.Sub(time.UnixMilli(r.LastFiredAt)) < cooldown) {
+		return
+	}
+
+	r.LastFiredAt = now.UnixMilli()
+	ev := &pb.AlertEvent{
+		Rule:      proto.Clone(r.AlertRule).(*pb.AlertRule),
+		Value:     x,
+		Reference: ref,
+		Candle:    toProto(c),
+		FiredAt:   r.LastFiredAt,
+	}
+	metrics.AlertsFired.WithLabelValues(r.Symbol + ":" + r.Interval).Inc()
+	log.Printf("alert: rule %s fired on %s:%s: %s %v, value %v", r.Id, r.Symbol, r.Interval,
+		r.Condition.Operator, r.Condition.Value, x)
+	e.fired = true
+	if e.path != "" && e.saveTimer == nil {
+		e.saveTimer = time.AfterFunc(alertSaveDelay, e.saveFired)
+	}
+	for _, w := range e.watchers {
+		if len(w.ids) > 0 && !w.ids[r.Id] {
+			continue
+		}
+		select {
+		case w.ch <- ev:
+		default:
+			metrics.AlertDeliveryFailures.WithLabelValues("stream").Inc()
+		}
+	}
+	if r.WebhookUrl != "" {
+		e.hooks.send(r.WebhookUrl, ev)
+	}
+}
+
+// observe folds c into r and reports whether its condition holds, with
+// the compared value and, for a percent move, the value it moved from.
+// Revisions update the state but never fire.
+func (r *alertRule) observe(c *candle.Candle) (fire bool, x, ref float64) {
+	x, ok := r.value(c)
+	if !ok || (r.OnClose && !c.IsClosed) {
+		return false, 0, 0
+	}
+	v := r.Condition.Value
+	switch r.Condition.Operator {
+	case pb.AlertOperator_ALERT_OPERATOR_ABOVE:
+		fire = x > v
+	case pb.AlertOperator_ALERT_OPERATOR_BELOW:
+		fire = x < v
+	case pb.AlertOperator_ALERT_OPERATOR_CROSSES_ABOVE:
+		fire = r.hasPrev && r.prev <= v && x > v
+	case pb.AlertOperator_ALERT_OPERATOR_CROSSES_BELOW:
+		fire = r.hasPrev && r.prev > v && x <= v
+	case pb.AlertOperator_ALERT_OPERATOR_MOVES_PERCENT:
+		ref = r.ref
+		fire = r.hasRef && ref != 0 && math.Abs(x-ref)/math.Abs(ref)*100 >= v
+	}
+	r.prev, r.hasPrev = x, true
+	if c.IsClosed {
+		r.ref, r.hasRef = x, true
+	}
+	return fire && c.Revision == 0, x, ref
+}
+
+// value returns the compared value of c: its close, or the indicator's
+// output once it has one.
+func (r *alertRule) value(c *candle.Candle) (float64, bool) {
+	if r.ind == nil {
+		x, err := strconv.ParseFloat(c.Close, 64)
+		return x, err == nil
+	}
+	vs := r.ind.Update(c)
+	if vs == nil {
+		return 0, false
+	}
+	return vs[r.output], true
+}
+
+// create stores pr as a new rule of owner and subscribes it, unless the
+// rule would exceed owner's limits.
+func (e *alertEngine) create(pr *pb.AlertRule, owner string) (*pb.AlertRule, error) {
+	pr = proto.Clone(pr).(*pb.AlertRule)
+	pr.Id = newAlertID()
+	pr.CreatedAt = time.Now().UnixMilli()
+	pr.LastFiredAt = 0
+	pr.Owner = owner
+	r, err := newAlertRule(pr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidAlert, err)
+	}
+
+	e.saveMu.Lock()
+	e.mu.Lock()
+	if err := e.hold(r, true); err != nil {
+		e.mu.Unlock()
+		e.saveMu.Unlock()
+		return nil, err
+	}
+	e.rules[r.Id] = r
+	if err := e.save(); err != nil {
+		delete(e.rules, r.Id)
+		r.deactivate()
+		e.mu.Unlock()
+		e.saveMu.Unlock()
+		return nil, err
+	}
+	metrics.AlertRules.Set(float64(len(e.rules)))
+	out := proto.Clone(r.AlertRule).(*pb.AlertRule)
+	e.mu.Unlock()
+	e.saveMu.Unlock()
+
+	e.activate(r)
+	return out, nil
+}
+
+// update replaces the rule with pr's id by pr, keeping its owner and when
+// it was created and last fired, and restarts its evaluation.
+func (e *alertEngine) update(pr *pb.AlertRule) (*pb.AlertRule, error) {
+	pr = proto.Clone(pr).(*pb.AlertRule)
+	e.saveMu.Lock()
+	defer e.saveMu.Unlock()
+	e.mu.Lock()
+	old, ok := e.rules[pr.Id]
+	if !ok {
+		e.mu.Unlock()
+		return nil, errAlertNotFound
+	}
+	pr.CreatedAt, pr.LastFiredAt, pr.Owner = old.CreatedAt, old.LastFiredAt, old.Owner
+	r, err := newAlertRule(pr)
+	if err != nil {
+		e.mu.Unlock()
+		return nil, fmt.Errorf("%w: %w", errInvalidAlert, err)
+	}
+	// Count r in place of old, which is counted again should r fail.
+	release := old.release
+	old.release = nil
+	if release != nil {
+		release()
+	}
+	if err := e.hold(r, true); err != nil {
+		e.hold(old, false)
+		e.mu.Unlock()
+		return nil, err
+	}
+	e.rules[r.Id] = r
+	if err := e.save(); err != nil {
+		e.rules[r.Id] = old
+		r.deactivate()
+		e.hold(old, false)
+		e.mu.Unlock()
+		return nil, err
+	}
+	old.deactivate()
+	out := proto.Clone(r.AlertRule).(*pb.AlertRule)
+	e.mu.Unlock()
+
+	e.activate(r)
+	return out, nil
+}
+
+// remove deletes the rule id and returns it.
+func (e *alertEngine) remove(id string) (*pb.AlertRule, error) {
+	e.saveMu.Lock()
+	defer e.saveMu.Unlock()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	r, ok := e.rules[id]
+	if !ok {
+		return nil, errAlertNotFound
+	}
+	delete(e.rules, id)
+	if err := e.save(); err != nil {
+		e.rules[id] = r
+		return nil, err
+	}
+	metrics.AlertRules.Set(float64(len(e.rules)))
+	r.deactivate()
+	return proto.Clone(r.AlertRule).(*pb.AlertRule), nil
+}
+
+// get returns a copy of the rule id.
+func (e *alertEngine) get(id string) (*pb.AlertRule, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	r, ok := e.rules[id]
+	if !ok {
+		return nil, errAlertNotFound
+	}
+	return proto.Clone(r.AlertRule).(*pb.AlertRule), nil
+}
+
+// list returns copies of the rules of symbol and interval (empty for any),
+// sorted by id.
+func (e *alertEngine) list(symbol, interval string) []*pb.AlertRule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var out []*pb.AlertRule
+	for _, r := range e.rules {
+		if (symbol == "" || r.Symbol == symbol) && (interval == "" || r.Interval == interval) {
+			out = append(out, proto.Clone(r.AlertRule).(*pb.AlertRule))
+		}
+	}
+	slices.SortFunc(out, func(x, y *pb.AlertRule) int { return cmp.Compare(x.Id, y.Id) })
+	return out
+}
+
+// watch registers a WatchAlerts stream for ids (empty for all) and returns
+// its events and a function that unregisters it.
+func (e *alertEngine) watch(ids []string) (<-chan *pb.AlertEvent, func()) {
+	w := &alertWatcher{ids: make(map[string]bool, len(ids)), ch: make(chan *pb.AlertEvent, e.watchBuf)}
+	for _, id := range ids {
+		w.ids[id] = true
+	}
+	e.mu.Lock()
+	id := e.nextWatcher
+	e.nextWatcher++
+	e.watchers[id] = w
+	e.mu.Unlock()
+	return w.ch, func() {
+		e.mu.Lock()
+		delete(e.watchers, id)
+		e.mu.Unlock()
+	}
+}
+
+// save writes every rule to the rules file, replacing it atomically
+// (called under saveMu and mu).
+func (e *alertEngine) save() error {
+	if e.path == "" {
+		return nil
+	}
+	data, err := e.marshal()
+	if err == nil {
+		err = e.write(data)
+	}
+	if err != nil {
+		return err
+	}
+	e.fired = false
+	return nil
+}
+
+// saveFired writes the rules file if a rule fired since it was last
+// written.  onCandle schedules it, so a firing never waits for the disk
+// and firings in quick succession are written once.
+func (e *alertEngine) saveFired() {
+	e.saveMu.Lock()
+	defer e.saveMu.Unlock()
+	e.mu.Lock()
+	e.saveTimer = nil
+	if !e.fired || e.path == "" {
+		e.mu.Unlock()
+		return
+	}
+	e.fired = false
+	data, err := e.marshal()
+	e.mu.Unlock()
+	if err == nil {
+		err = e.write(data)
+	}
+	if err != nil {
+		log.Printf("warn: %v", err)
+	}
+}
+
+// marshal encodes every rule for the rules file (called under lock).
+func (e *alertEngine) marshal() ([]byte, error) {
+	list := &pb.ListAlertsResponse{Rules: make([]*pb.AlertRule, 0, len(e.rules))}
+	for _, r := range e.rules {
+		list.Rules = append(list.Rules, r.AlertRule)
+	}
+	slices.SortFunc(list.Rules, func(x, y *pb.AlertRule) int { return cmp.Compare(x.Id, y.Id) })
+	data, err := protojson.MarshalOptions{Multiline: true, UseProtoNames: true}.Marshal(list)
+	if err != nil {
+		return nil, fmt.Errorf("alerts: save: %w", err)
+	}
+	return data, nil
+}
+
+// write replaces the rules file with data atomically (called under
+// saveMu).
+func (e *alertEngine) write(data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(e.path), filepath.Base(e.path)+".*")
+	if err != nil {
+		return fmt.Errorf("alerts: save: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), e.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("alerts: save: %w", err)
+	}
+	return nil
+}
+
+// close stops evaluating rules, records when they last fired and delivers
+// the webhooks already queued.
+func (e *alertEngine) close() {
+	e.mu.Lock()
+	e.closed = true
+	for _, r := range e.rules {
+		r.deactivate()
+	}
+	if e.saveTimer != nil {
+		e.saveTimer.Stop()
+	}
+	e.mu.Unlock()
+	e.saveFired()
+	e.hooks.close()
+}
+
+// newAlertID returns a random rule id.
+func newAlertID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
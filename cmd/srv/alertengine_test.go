@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/yitech/candles/model/candle"
+	pb "github.com/yitech/candles/model/protobuf"
+)
+
+func TestAlertCooldownMinimum(t *testing.T) {
+	for _, tc := range []struct {
+		cooldownMs int64
+		ok         bool
+	}{
+		{0, true}, // the server's default
+		{1, false},
+		{999, false},
+		{1000, true},
+		{-1000, false},
+	} {
+		_, err := newAlertRule(&pb.AlertRule{
+			Symbol:     "BTCUSDT",
+			Interval:   "1m",
+			CooldownMs: tc.cooldownMs,
+			Condition:  &pb.AlertCondition{Operator: pb.AlertOperator_ALERT_OPERATOR_ABOVE, Value: 1},
+		})
+		if (err == nil) != tc.ok {
+			t.Errorf("cooldown_ms %d: err = %v, want ok=%t", tc.cooldownMs, err, tc.ok)
+		}
+	}
+}
+
+// TestAlertFiredSavedLater checks that a firing does not write the rules
+// file itself, and that close writes when the rule last fired.
+func TestAlertFiredSavedLater(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts.json")
+	e := newAlertEngine(nil, nil, AlertsConfig{Path: path, Cooldown: time.Minute, WebhookTimeout: time.Second}, 1)
+	r, err := newAlertRule(&pb.AlertRule{
+		Id:        "r1",
+		Symbol:    "BTCUSDT",
+		Interval:  "1m",
+		Condition: &pb.AlertCondition{Operator: pb.AlertOperator_ALERT_OPERATOR_ABOVE, Value: 100},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.rules[r.Id] = r
+
+	e.onCandle(r, &candle.Candle{Symbol: "BTCUSDT", Interval: "1m", Close: "101"})
+	if r.LastFiredAt == 0 {
+		t.Fatal("rule did not fire")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("rules file written on firing: %v", err)
+	}
+
+	e.close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list pb.ListAlertsResponse
+	if err := protojson.Unmarshal(data, &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Rules) != 1 || list.Rules[0].LastFiredAt != r.LastFiredAt {
+		t.Fatalf("saved %v, want r1 last fired at %d", list.Rules, r.LastFiredAt)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/yitech/candles/auth"
+	pb "github.com/yitech/candles/model/protobuf"
+)
+
+// CreateAlert stores a new alert rule and starts evaluating it. The server
+// assigns its id, owner and creation time; an enabled rule counts as a
+// stream of the caller against its limits.
+func (s *server) CreateAlert(ctx context.Context, req *pb.AlertRule) (*pb.AlertRule, error) {
+	if err := authorize(ctx, req.Symbol, req.Interval); err != nil {
+		return nil, err
+	}
+	opened, err := s.openAlertMarket(req)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.alerts.create(req, clientID(ctx))
+	opened()
+	if err != nil {
+		return nil, alertStatus(err)
+	}
+	log.Printf("alerts: created rule %s on %s:%s", r.Id, r.Symbol, r.Interval)
+	return r, nil
+}
+
+// GetAlert returns one alert rule.
+func (s *server) GetAlert(ctx context.Context, req *pb.AlertRequest) (*pb.AlertRule, error) {
+	return s.alertOf(ctx, req.Id)
+}
+
+// alertOf returns the rule id if the caller owns it and may access its
+// market.  Other callers' rules are reported as not found.
+func (s *server) alertOf(ctx context.Context, id string) (*pb.AlertRule, error) {
+	r, err := s.alerts.get(id)
+	if err == nil && !owns(ctx, r) {
+		err = errAlertNotFound
+	}
+	if err != nil {
+		return nil, alertStatus(err)
+	}
+	if err := authorize(ctx, r.Symbol, r.Interval); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// owns reports whether the caller in ctx created rule r.  Without
+// authentication every caller owns every rule.
+func owns(ctx context.Context, r *pb.AlertRule) bool {
+	p, ok := auth.FromContext(ctx)
+	return !ok || r.Owner == p.Name
+}
+
+// ListAlerts returns the alert rules, optionally of one market, that the
+// caller owns and may access.
+func (s *server) ListAlerts(ctx context.Context, req *pb.ListAlertsRequest) (*pb.ListAlertsResponse, error) {
+	rules := s.alerts.list(req.Symbol, req.Interval)
+	rules = slices.DeleteFunc(rules, func(r *pb.AlertRule) bool {
+		return !owns(ctx, r) || !allowed(ctx, r.Symbol, r.Interval)
+	})
+	return &pb.ListAlertsResponse{Rules: rules}, nil
+}
+
+// UpdateAlert replaces the alert rule with req's id. Its evaluation starts
+// over, seeded from history like a new rule's; the cooldown still counts
+// from its last firing.
+func (s *server) UpdateAlert(ctx context.Context, req *pb.AlertRule) (*pb.AlertRule, error) {
+	if _, err := s.alertOf(ctx, req.Id); err != nil {
+		return nil, err
+	}
+	if err := authorize(ctx, req.Symbol, req.Interval); err != nil {
+		return nil, err
+	}
+	opened, err := s.openAlertMarket(req)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.alerts.update(req)
+	opened()
+	if err != nil {
+		return nil, alertStatus(err)
+	}
+	log.Printf("alerts: updated rule %s", r.Id)
+	return r, nil
+}
+
+// DeleteAlert removes an alert rule.
+func (s *server) DeleteAlert(ctx context.Context, req *pb.AlertRequest) (*pb.AlertRule, error) {
+	if _, err := s.alertOf(ctx, req.Id); err != nil {
+		return nil, err
+	}
+	r, err := s.alerts.remove(req.Id)
+	if err != nil {
+		return nil, alertStatus(err)
+	}
+	log.Printf("alerts: deleted rule %s", r.Id)
+	return r, nil
+}
+
+// openAlertMarket admits the start of the market of rule r, which the
+// engine subscribes unless r is disabled, as limiter.open does.
+func (s *server) openAlertMarket(r *pb.AlertRule) (done func(), err error) {
+	if r.Disabled {
+		return func() {}, nil
+	}
+	return s.limits.open(r.Symbol, r.Interval)
+}
+
+// WatchAlerts streams alert events as the caller's rules fire, for the
+// markets it may access. Up to buffers.stream events are buffered per
+// stream; when the client falls behind, new ones are dropped and counted in
+// candles_alerts_delivery_failures_total.
+func (s *server) WatchAlerts(req *pb.WatchAlertsRequest, stream pb.CandleService_WatchAlertsServer) error {
+	select {
+	case <-s.drain:
+		return s.shutdownStatus()
+	default:
+	}
+	release, err := s.limits.acquire(stream.Context(), "")
+	if err != nil {
+		return err
+	}
+	defer release()
+	log.Printf("watch alerts: rules=%v", req.RuleIds)
+	events, stop := s.alerts.watch(req.RuleIds)
+	defer stop()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			log.Printf("disconnect: watch alerts")
+			return stream.Context().Err()
+		case <-s.flushed:
+			return s.shutdownStatus()
+		case ev := <-events:
+			if !owns(stream.Context(), ev.Rule) || !allowed(stream.Context(), ev.Rule.Symbol, ev.Rule.Interval) {
+				continue
+			}
+			if err := stream.Send(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// alertStatus maps an alert engine error to a gRPC status: unknown ids to
+// NOT_FOUND, rules that do not validate to INVALID_ARGUMENT, and failures
+// to persist the rules to INTERNAL.  Statuses, such as those of the
+// limits, are returned as they are.
+func alertStatus(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch {
+	case errors.Is(err, errAlertNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, errInvalidAlert):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/yitech/candles/aggregator"
+	"github.com/yitech/candles/auth"
+	pb "github.com/yitech/candles/model/protobuf"
+)
+
+// TestAlertOwner checks that with authentication a caller only sees its
+// own rules, and without it every rule.
+func TestAlertOwner(t *testing.T) {
+	e := newAlertEngine(nil, nil, AlertsConfig{Cooldown: time.Minute, WebhookTimeout: time.Second}, 1)
+	defer e.close()
+	s := &server{alerts: e}
+	create := func(owner string) string {
+		r, err := e.create(&pb.AlertRule{
+			Symbol:    "BTCUSDT",
+			Interval:  "1m",
+			Disabled:  true,
+			Condition: &pb.AlertCondition{Operator: pb.AlertOperator_ALERT_OPERATOR_ABOVE, Value: 1},
+		}, owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r.Id
+	}
+	alice, bob := create("alice"), create("bob")
+	ctx := auth.NewContext(context.Background(), &auth.Principal{Name: "alice"})
+
+	if _, err := s.GetAlert(ctx, &pb.AlertRequest{Id: alice}); err != nil {
+		t.Errorf("alice gets her rule: %v", err)
+	}
+	if _, err := s.GetAlert(ctx, &pb.AlertRequest{Id: bob}); status.Code(err) != codes.NotFound {
+		t.Errorf("alice gets bob's rule: %v, want NotFound", err)
+	}
+	if _, err := s.DeleteAlert(ctx, &pb.AlertRequest{Id: bob}); status.Code(err) != codes.NotFound {
+		t.Errorf("alice deletes bob's rule: %v, want NotFound", err)
+	}
+	list, err := s.ListAlerts(ctx, &pb.ListAlertsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Rules) != 1 || list.Rules[0].Id != alice || list.Rules[0].Owner != "alice" {
+		t.Errorf("alice lists %v, want only her rule", list.Rules)
+	}
+
+	list, err = s.ListAlerts(context.Background(), &pb.ListAlertsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Rules) != 2 {
+		t.Errorf("without authentication %d rules listed, want 2", len(list.Rules))
+	}
+}
+
+// TestAlertLimits checks that enabled rules count as streams of their
+// owner, and stop counting once disabled or deleted.
+func TestAlertLimits(t *testing.T) {
+	agg := aggregator.NewWithConfig(aggregator.Config{})
+	defer agg.Close()
+	e := newAlertEngine(agg, newLimiter(agg, LimitsConfig{StreamsPerClient: 1}), AlertsConfig{Cooldown: time.Minute, WebhookTimeout: time.Second}, 1)
+	defer e.close()
+	rule := func(disabled bool) *pb.AlertRule {
+		return &pb.AlertRule{
+			Symbol:    "BTCUSDT",
+			Interval:  "1m",
+			Disabled:  disabled,
+			Condition: &pb.AlertCondition{Operator: pb.AlertOperator_ALERT_OPERATOR_ABOVE, Value: 1},
+		}
+	}
+
+	r, err := e.create(rule(false), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.create(rule(false), "alice"); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("second enabled rule: %v, want ResourceExhausted", err)
+	}
+	if _, err := e.create(rule(true), "alice"); err != nil {
+		t.Fatalf("disabled rule: %v", err)
+	}
+	if _, err := e.create(rule(false), "bob"); err != nil {
+		t.Fatalf("another owner's rule: %v", err)
+	}
+
+	// Updating the rule counts it once, not twice.
+	pr := rule(false)
+	pr.Id = r.Id
+	if _, err := e.update(pr); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	pr.Disabled = true
+	if _, err := e.update(pr); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	r2, err := e.create(rule(false), "alice")
+	if err != nil {
+		t.Fatalf("enabled rule after disabling the first: %v", err)
+	}
+	if _, err := e.remove(r2.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.create(rule(false), "alice"); err != nil {
+		t.Fatalf("enabled rule after deleting the second: %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/yitech/candles/auth"
+	"github.com/yitech/candles/metrics"
+)
+
+// Authentication runs in front of both the gRPC service and the gateway:
+// the interceptors and withAuth resolve the caller's credential to an
+// auth.Principal and put it in the request context, and the handlers
+// check the markets a request touches with authorize.  With no
+// authenticator configured, contexts carry no principal and every market
+// is allowed.
+
+// authExempt lists the gRPC services callable without credentials, so
+// load balancers can probe health.
+var authExempt = []string{"/grpc.health.v1.Health/"}
+
+// newAuthenticator builds the authenticator cfg describes, or nil if it
+// configures none.
+func newAuthenticator(cfg AuthConfig) (auth.Authenticator, error) {
+	var chain auth.Chain
+	if len(cfg.APIKeys) > 0 {
+		keys := make([]auth.APIKey, len(cfg.APIKeys))
+		for i, k := range cfg.APIKeys {
+			keys[i] = auth.APIKey{Name: k.Name, Markets: k.Markets}
+			if k.Key != "" {
+				keys[i].Hash = auth.HashKey(k.Key)
+			} else {
+				keys[i].Hash, _ = auth.ParseKeyHash(k.KeySHA256) // validated
+			}
+		}
+		chain = append(chain, auth.NewAPIKeys(keys))
+	}
+	if cfg.JWT.JWKSFile != "" {
+		j, err := auth.NewJWT(auth.JWTOptions{
+			JWKSFile:     cfg.JWT.JWKSFile,
+			Issuer:       cfg.JWT.Issuer,
+			Audience:     cfg.JWT.Audience,
+			MarketsClaim: cfg.JWT.MarketsClaim,
+		})
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, j)
+	}
+	if len(chain) == 0 {
+		return nil, nil
+	}
+	return chain, nil
+}
+
+// authenticate resolves credential, logging and counting failures.  The
+// returned error is a gRPC status.
+func authenticate(a auth.Authenticator, credential, caller string) (*auth.Principal, error) {
+	if credential == "" {
+		metrics.AuthFailures.WithLabelValues("missing").Inc()
+		return nil, status.Error(codes.Unauthenticated, "credentials required: send an API key or a bearer token")
+	}
+	p, err := a.Authenticate(credential)
+	if err != nil {
+		metrics.AuthFailures.WithLabelValues("invalid").Inc()
+		log.Printf("warn: auth: %s: %v", caller, err)
+		if errors.Is(err, auth.ErrUnrecognized) {
+			return nil, status.Error(codes.Unauthenticated, "unknown API key or token")
+		}
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return p, nil
+}
+
+// grpcCredential returns the credential in an "authorization: Bearer"
+// or "x-api-key" header.
+func grpcCredential(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get("authorization"); len(v) > 0 {
+		if token, ok := cutBearer(v[0]); ok {
+			return token
+		}
+	}
+	if v := md.Get("x-api-key"); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func cutBearer(h string) (string, bool) {
+	scheme, token, ok := strings.Cut(h, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+func grpcCaller(ctx context.Context, method string) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String() + " " + method
+	}
+	return method
+}
+
+func isExempt(method string) bool {
+	for _, prefix := range authExempt {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// authUnary authenticates unary RPCs with a.
+func authUnary(a auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if isExempt(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		p, err := authenticate(a, grpcCredential(ctx), grpcCaller(ctx, info.FullMethod))
+		if err != nil {
+			return nil, err
+		}
+		return handler(auth.NewContext(ctx, p), req)
+	}
+}
+
+// authStream authenticates streaming RPCs with a.
+func authStream(a auth.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if isExempt(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		ctx := ss.Context()
+		p, err := authenticate(a, grpcCredential(ctx), grpcCaller(ctx, info.FullMethod))
+		if err != nil {
+			return err
+		}
+		return handler(srv, &principalStream{ServerStream: ss, ctx: auth.NewContext(ctx, p)})
+	}
+}
+
+// principalStream is a grpc.ServerStream whose context carries the
+// caller's principal.
+type principalStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *principalStream) Context() context.Context { return s.ctx }
+
+// withAuth authenticates gateway requests with a.  The credential is read
+// from an "Authorization: Bearer" or "X-API-Key" header or, for browsers'
+// EventSource and WebSocket, which cannot set headers, from the
+// access_token query parameter.
+func withAuth(h http.Handler, a auth.Authenticator) http.Handler {
+	if a == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		credential := r.Header.Get("X-API-Key")
+		if token, ok := cutBearer(r.Header.Get("Authorization")); ok {
+			credential = token
+		}
+		if q := r.URL.Query(); q.Has("access_token") {
+			credential = q.Get("access_token")
+			q.Del("access_token")
+			r.URL.RawQuery = q.Encode()
+		}
+		p, err := authenticate(a, credential, r.RemoteAddr+" "+r.Method+" "+r.URL.Path)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, err)
+			return
+		}
+		h.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), p)))
+	})
+}
+
+// allowed reports whether the caller in ctx may access the market
+// symbol/interval.
+func allowed(ctx context.Context, symbol, interval string) bool {
+	p, ok := auth.FromContext(ctx)
+	return !ok || p.Allows(symbol, interval)
+}
+
+// authorize fails with PERMISSION_DENIED unless the caller in ctx may
+// access the market symbol/interval.
+func authorize(ctx context.Context, symbol, interval string) error {
+	if allowed(ctx, symbol, interval) {
+		return nil
+	}
+	metrics.AuthFailures.WithLabelValues("forbidden").Inc()
+	return status.Errorf(codes.PermissionDenied, "not allowed to access %s:%s", symbol, interval)
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/yitech/candles/auth"
+)
+
+func TestAuthorize(t *testing.T) {
+	restricted := &auth.Principal{Name: "r", Markets: []string{"BTCUSDT:*", "*:1h"}}
+	for _, tc := range []struct {
+		name     string
+		p        *auth.Principal // nil: no authentication
+		symbol   string
+		interval string
+		ok       bool
+	}{
+		{"no authentication", nil, "ETHUSDT", "1m", true},
+		{"nil market list", &auth.Principal{Name: "a"}, "ETHUSDT", "1m", true},
+		{"empty market list", &auth.Principal{Name: "a", Markets: []string{}}, "ETHUSDT", "1m", true},
+		{"restricted, symbol allowed", restricted, "BTCUSDT", "1m", true},
+		{"restricted, interval allowed", restricted, "ETHUSDT", "1h", true},
+		{"restricted, neither", restricted, "ETHUSDT", "1m", false},
+	} {
+		ctx := context.Background()
+		if tc.p != nil {
+			ctx = auth.NewContext(ctx, tc.p)
+		}
+		err := authorize(ctx, tc.symbol, tc.interval)
+		if tc.ok && err != nil || !tc.ok && status.Code(err) != codes.PermissionDenied {
+			t.Errorf("%s: authorize %s:%s = %v, want ok=%t", tc.name, tc.symbol, tc.interval, err, tc.ok)
+		}
+		if allowed(ctx, tc.symbol, tc.interval) != tc.ok {
+			t.Errorf("%s: allowed %s:%s != %t", tc.name, tc.symbol, tc.interval, tc.ok)
+		}
+	}
+}
+
+func testAuthenticator() auth.Authenticator {
+	return auth.Chain{auth.NewAPIKeys([]auth.APIKey{{Name: "alice", Hash: auth.HashKey("alice-key")}})}
+}
+
+func TestAuthUnary(t *testing.T) {
+	intercept := authUnary(testAuthenticator())
+	for _, tc := range []struct {
+		name   string
+		method string
+		md     metadata.MD
+		code   codes.Code
+		caller string // principal seen by the handler
+	}{
+		{"bearer", "/candle.CandleService/GetStatus", metadata.Pairs("authorization", "Bearer alice-key"), codes.OK, "alice"},
+		{"bearer, any case", "/candle.CandleService/GetStatus", metadata.Pairs("authorization", "bearer  alice-key "), codes.OK, "alice"},
+		{"x-api-key", "/candle.CandleService/GetStatus", metadata.Pairs("x-api-key", "alice-key"), codes.OK, "alice"},
+		{"basic", "/candle.CandleService/GetStatus", metadata.Pairs("authorization", "Basic alice-key"), codes.Unauthenticated, ""},
+		{"unknown key", "/candle.CandleService/GetStatus", metadata.Pairs("x-api-key", "mallory-key"), codes.Unauthenticated, ""},
+		{"no credential", "/candle.CandleService/GetStatus", nil, codes.Unauthenticated, ""},
+		{"health, exempt", "/grpc.health.v1.Health/Check", nil, codes.OK, ""},
+	} {
+		ctx := metadata.NewIncomingContext(context.Background(), tc.md)
+		var caller string
+		_, err := intercept(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tc.method}, func(ctx context.Context, _ any) (any, error) {
+			if p, ok := auth.FromContext(ctx); ok {
+				caller = p.Name
+			}
+			return nil, nil
+		})
+		if status.Code(err) != tc.code || caller != tc.caller {
+			t.Errorf("%s: %v, caller %q; want %v, caller %q", tc.name, err, caller, tc.code, tc.caller)
+		}
+	}
+}
+
+func TestWithAuth(t *testing.T) {
+	var caller, query string
+	h := withAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller, query = "", r.URL.RawQuery
+		if p, ok := auth.FromContext(r.Context()); ok {
+			caller = p.Name
+		}
+	}), testAuthenticator())
+	for _, tc := range []struct {
+		name   string
+		target string
+		header http.Header
+		code   int
+		caller string
+		query  string
+	}{
+		{"bearer", "/v1/status", http.Header{"Authorization": {"Bearer alice-key"}}, http.StatusOK, "alice", ""},
+		{"x-api-key", "/v1/status", http.Header{"X-Api-Key": {"alice-key"}}, http.StatusOK, "alice", ""},
+		{"access_token, removed", "/v1/candles?symbol=BTCUSDT&access_token=alice-key", nil, http.StatusOK, "alice", "symbol=BTCUSDT"},
+		{"unknown key", "/v1/status", http.Header{"X-Api-Key": {"mallory-key"}}, http.StatusUnauthorized, "", ""},
+		{"no credential", "/v1/status", nil, http.StatusUnauthorized, "", ""},
+	} {
+		caller, query = "", ""
+		r := httptest.NewRequest("GET", tc.target, nil)
+		for k, v := range tc.header {
+			r.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tc.code || caller != tc.caller || query != tc.query {
+			t.Errorf("%s: %d, caller %q, query %q; want %d, %q, %q", tc.name, w.Code, caller, query, tc.code, tc.caller, tc.query)
+		}
+		if tc.code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("%s: no WWW-Authenticate challenge", tc.name)
+		}
+	}
+}
+
+// TestServerTLS checks that with client_ca_file set the server accepts
+// only clients with a certificate from that CA.
+func TestServerTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := newCert(t, "ca", nil, nil)
+	otherCA, otherKey := newCert(t, "other ca", nil, nil)
+	srvCert, srvKey := newCert(t, "localhost", ca, caKey)
+	writePEM(t, filepath.Join(dir, "ca.crt"), "CERTIFICATE", ca.Raw)
+	writePEM(t, filepath.Join(dir, "srv.crt"), "CERTIFICATE", srvCert.Raw)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(srvKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, filepath.Join(dir, "srv.key"), "PRIVATE KEY", keyDER)
+
+	cfg := TLSConfig{CertFile: filepath.Join(dir, "srv.crt"), KeyFile: filepath.Join(dir, "srv.key")}
+	if tc, err := cfg.serverTLS(); err != nil || tc.ClientAuth != tls.NoClientCert {
+		t.Fatalf("without client_ca_file: %v, client auth %v", err, tc.ClientAuth)
+	}
+	cfg.ClientCAFile = filepath.Join(dir, "ca.crt")
+	tc, err := cfg.serverTLS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.ClientAuth != tls.RequireAndVerifyClientCert || tc.MinVersion < tls.VersionTLS12 {
+		t.Fatalf("client auth %v, min version %x", tc.ClientAuth, tc.MinVersion)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	clientCert := func(issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey) []tls.Certificate {
+		c, k := newCert(t, "client", issuer, issuerKey)
+		return []tls.Certificate{{Certificate: [][]byte{c.Raw}, PrivateKey: k}}
+	}
+	for _, c := range []struct {
+		name  string
+		certs []tls.Certificate
+		ok    bool
+	}{
+		{"client certificate from the CA", clientCert(ca, caKey), true},
+		{"no client certificate", nil, false},
+		{"client certificate from another CA", clientCert(otherCA, otherKey), false},
+	} {
+		err := handshake(t, tc, &tls.Config{ServerName: "localhost", RootCAs: roots, Certificates: c.certs})
+		if (err == nil) != c.ok {
+			t.Errorf("%s: handshake %v, want ok=%t", c.name, err, c.ok)
+		}
+	}
+
+	cfg.ClientCAFile = filepath.Join(dir, "srv.key")
+	if _, err := cfg.serverTLS(); err == nil {
+		t.Error("serverTLS accepted a client_ca_file without certificates")
+	}
+}
+
+// handshake connects a client with cc to a server with sc and returns the
+// server's handshake error.
+func handshake(t *testing.T, sc, cc *tls.Config) error {
+	t.Helper()
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	done := make(chan error, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer conn.Close()
+		done <- conn.(*tls.Conn).Handshake()
+	}()
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}, "tcp", lis.Addr().String(), cc)
+	if err == nil {
+		conn.Read(make([]byte, 1)) // TLS 1.3 reports a refused client certificate on read
+		conn.Close()
+	}
+	return <-done
+}
+
+// newCert returns a certificate for name signed by issuer, or a
+// self-signed CA if issuer is nil, and its key.
+func newCert(t *testing.T, name string, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{name},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if issuer == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		issuer, issuerKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, &key.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,981 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/yitech/candles/adapter"
+	"github.com/yitech/candles/adapter/binance"
+	"github.com/yitech/candles/adapter/bybit"
+	"github.com/yitech/candles/adapter/okx"
+	"github.com/yitech/candles/aggregator"
+	"github.com/yitech/candles/auth"
+	"github.com/yitech/candles/model/candle"
+	pb "github.com/yitech/candles/model/protobuf"
+	"github.com/yitech/candles/store"
+	"github.com/yitech/candles/wal"
+)
+
+// Config is the server configuration.
+//
+// Precedence, lowest first: built-in defaults, the YAML file given by
+// -config (or CANDLES_CONFIG), CANDLES_* environment variables, flags.
+type Config struct {
+	Listen    string          `yaml:"listen"`
+	Exchanges ExchangesConfig `yaml:"exchanges"`
+	Markets   MarketsConfig   `yaml:"markets"`
+	Buffers   BuffersConfig   `yaml:"buffers"`
+	History   HistoryConfig   `yaml:"history"`
+	Store     StoreConfig     `yaml:"store"`
+	WAL       WALConfig       `yaml:"wal"`
+	Alerts    AlertsConfig    `yaml:"alerts"`
+	Shutdown  ShutdownConfig  `yaml:"shutdown"`
+	Metrics   MetricsConfig   `yaml:"metrics"`
+	HTTP      HTTPConfig      `yaml:"http"`
+	TLS       TLSConfig       `yaml:"tls"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Limits    LimitsConfig    `yaml:"limits"`
+	Log       LogConfig       `yaml:"log"`
+}
+
+// ExchangesConfig lists the supported exchanges.
+type ExchangesConfig struct {
+	Binance ExchangeConfig `yaml:"binance"`
+	Bybit   ExchangeConfig `yaml:"bybit"`
+	OKX     ExchangeConfig `yaml:"okx"`
+}
+
+// ExchangeConfig configures one exchange adapter.
+type ExchangeConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	RESTURL     string        `yaml:"rest_url"`
+	WSURL       string        `yaml:"ws_url"`
+	HTTPTimeout time.Duration `yaml:"http_timeout"`
+	Category    string        `yaml:"category,omitempty"` // bybit only
+}
+
+// MarketsConfig holds market-level settings.
+type MarketsConfig struct {
+	Defaults MarketDefaults `yaml:"defaults"`
+
+	// BaseInterval, if set, is the only interval subscribed on the
+	// exchanges; longer intervals that are multiples of it are resampled
+	// from it.  Empty subscribes every interval on the exchanges.
+	BaseInterval string `yaml:"base_interval"`
+
+	// GapFill publishes flat synthetic candles for periods no exchange
+	// reported, so history has no holes.
+	GapFill bool `yaml:"gap_fill"`
+
+	// LateWindow is how long after a period ends exchange updates for it
+	// are still merged, publishing a revision of the closed candle; zero
+	// drops them.
+	LateWindow time.Duration `yaml:"late_window"`
+
+	// Warm lists "SYMBOL:INTERVAL" markets subscribed at startup, seeded
+	// with history.depth closed candles and kept hot without clients.
+	Warm []string `yaml:"warm"`
+
+	// Divergence holds the thresholds beyond which the exchanges of a
+	// market are reported as diverging.
+	Divergence DivergenceConfig `yaml:"divergence"`
+
+	// Symbols overrides, per symbol, the exchanges aggregated, the quorum
+	// that closes a period, the merge weights and the divergence
+	// thresholds.
+	Symbols map[string]SymbolConfig `yaml:"symbols"`
+}
+
+// DivergenceConfig sets the divergence alert thresholds, in basis points of
+// the median exchange close.  Zero disables a threshold.
+type DivergenceConfig struct {
+	// SpreadBps limits the spread between the highest and lowest close.
+	SpreadBps float64 `yaml:"spread_bps"`
+
+	// DeviationBps limits any one exchange's distance from the median.
+	DeviationBps float64 `yaml:"deviation_bps"`
+}
+
+// SymbolConfig configures the aggregation of one symbol.  Zero fields take
+// the defaults: every enabled exchange, all of them needed to close a
+// period, and an unweighted merge.
+type SymbolConfig struct {
+	// Exchanges lists the enabled exchanges taking part, e.g. the venues
+	// that list the symbol.
+	Exchanges []string `yaml:"exchanges,omitempty"`
+
+	// Quorum is how many of them must close a period before it is final;
+	// 0, or leaving it out, means all of them.
+	Quorum int `yaml:"quorum,omitempty"`
+
+	// Weights makes the merged open and close a weighted mean of the
+	// exchanges'; exchanges not listed weigh 1.
+	Weights map[string]float64 `yaml:"weights,omitempty"`
+
+	// Divergence replaces markets.divergence for the symbol.
+	Divergence *DivergenceConfig `yaml:"divergence,omitempty"`
+}
+
+// MarketDefaults apply to every market unless a request overrides them.
+type MarketDefaults struct {
+	// Backpressure is the policy used when SubscribeRequest leaves it unset:
+	// drop_newest, drop_oldest, drop_updates, conflate or disconnect.
+	Backpressure string `yaml:"backpressure"`
+}
+
+// BuffersConfig sizes the server's internal buffers.
+type BuffersConfig struct {
+	Stream int `yaml:"stream"` // per-stream candle buffer
+}
+
+// HistoryConfig controls the aggregator's in-memory history.
+type HistoryConfig struct {
+	Depth int `yaml:"depth"` // finalized candles kept per market
+}
+
+// StoreConfig controls the on-disk candle store.
+type StoreConfig struct {
+	// Path of the database file; empty keeps history in memory only.
+	Path string `yaml:"path"`
+
+	// Retention maps an interval to how long its candles are kept, e.g.
+	// {"1m": 720h}.  Intervals not listed are kept forever.
+	Retention store.Retention `yaml:"retention"`
+}
+
+// WALConfig controls the write-ahead log of in-flight periods.
+type WALConfig struct {
+	// Dir holds the log segments and checkpoints; empty disables the log.
+	Dir string `yaml:"dir"`
+
+	// CheckpointInterval is how often the log is checkpointed and
+	// compacted.
+	CheckpointInterval time.Duration `yaml:"checkpoint_interval"`
+
+	// SyncInterval is how often the log is fsynced.
+	SyncInterval time.Duration `yaml:"sync_interval"`
+}
+
+// AlertsConfig controls the alert engine.
+type AlertsConfig struct {
+	// Path of the file alert rules are kept in; empty keeps them in memory
+	// only.
+	Path string `yaml:"path"`
+
+	// Cooldown is the least time between two firings of a rule that sets
+	// no cooldown of its own; at least a second.
+	Cooldown time.Duration `yaml:"cooldown"`
+
+	// WebhookTimeout bounds each webhook request.
+	WebhookTimeout time.Duration `yaml:"webhook_timeout"`
+
+	// WebhookRetries is how many more times a failed webhook request is
+	// tried, with exponential backoff from one second.
+	WebhookRetries int `yaml:"webhook_retries"`
+
+	// WebhookAllowedNetworks lists the CIDR networks webhooks may reach
+	// besides public addresses; loopback, private and link-local addresses
+	// are refused otherwise.
+	WebhookAllowedNetworks []string `yaml:"webhook_allowed_networks"`
+}
+
+// ShutdownConfig controls the drain on SIGINT/SIGTERM.
+type ShutdownConfig struct {
+	// Timeout bounds the whole drain; streams and exchange sockets still
+	// open when it expires are closed forcibly.
+	Timeout time.Duration `yaml:"timeout"`
+
+	// RetryDelay is the reconnect hint sent to clients in the final
+	// UNAVAILABLE status.
+	RetryDelay time.Duration `yaml:"retry_delay"`
+}
+
+// MetricsConfig controls the Prometheus endpoint.
+type MetricsConfig struct {
+	Listen string `yaml:"listen"` // HTTP listen address; off unless set
+	Path   string `yaml:"path"`
+}
+
+// HTTPConfig controls the HTTP/JSON gateway and its WebSocket endpoint.
+type HTTPConfig struct {
+	Listen string `yaml:"listen"` // listen address; off unless set
+
+	// CORSOrigins lists the browser origins allowed to call the gateway;
+	// "*" allows any.
+	CORSOrigins []string `yaml:"cors_origins,omitempty"`
+}
+
+// TLSConfig enables TLS on the gRPC listener and the HTTP gateway.
+type TLSConfig struct {
+	// CertFile and KeyFile hold the server's PEM certificate chain and
+	// private key; empty serves plaintext.
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+
+	// ClientCAFile, if set, enables mutual TLS: clients must present a
+	// certificate signed by one of the PEM CAs it holds.
+	ClientCAFile string `yaml:"client_ca_file"`
+}
+
+// AuthConfig controls client authentication on the gRPC service and the
+// HTTP gateway.  With no API keys and no JWKS file, every caller is
+// allowed every market.
+type AuthConfig struct {
+	APIKeys []APIKeyConfig `yaml:"api_keys,omitempty"`
+	JWT     JWTConfig      `yaml:"jwt"`
+}
+
+// APIKeyConfig is one static API key.
+type APIKeyConfig struct {
+	Name string `yaml:"name"` // identifies the key in logs
+
+	// Key is the secret clients send.  KeySHA256, its hex SHA-256, keeps
+	// the secret out of the config; set exactly one of them.
+	Key       string `yaml:"key,omitempty"`
+	KeySHA256 string `yaml:"key_sha256,omitempty"`
+
+	// Markets lists the "SYMBOL:INTERVAL" patterns the key may access,
+	// e.g. "BTCUSDT:*" or "*:1h"; empty allows every market.
+	Markets []string `yaml:"markets,omitempty"`
+}
+
+// JWTConfig validates bearer tokens against a local JSON Web Key Set.
+type JWTConfig struct {
+	// JWKSFile holds the issuer's public keys; empty disables JWTs.  It is
+	// read again when it changes.
+	JWKSFile string `yaml:"jwks_file"`
+
+	Issuer   string `yaml:"issuer"`   // required "iss"; empty accepts any
+	Audience string `yaml:"audience"` // required in "aud"; empty accepts any
+
+	// MarketsClaim names the claim listing the market patterns a token
+	// may access; a token without it may access every market.
+	MarketsClaim string `yaml:"markets_claim"`
+}
+
+// LimitsConfig caps what clients may open, to keep the exchanges from
+// banning the server.  A client is an API key or token subject, or else an
+// IP address.  Zero disables a limit.
+type LimitsConfig struct {
+	// StreamsPerClient caps a client's concurrent streams, WebSocket
+	// subscriptions included.
+	StreamsPerClient int `yaml:"streams_per_client"`
+
+	// MarketsPerClient caps the distinct markets a client streams at once.
+	MarketsPerClient int `yaml:"markets_per_client"`
+
+	// MaxMarkets caps the markets the server runs.  A market, with its
+	// exchange sockets, runs from its first subscription until shutdown.
+	MaxMarkets int `yaml:"max_markets"`
+
+	// NewMarketsPerMinute is how many markets may be started per minute,
+	// in bursts of up to NewMarketsBurst.
+	NewMarketsPerMinute int `yaml:"new_markets_per_minute"`
+	NewMarketsBurst     int `yaml:"new_markets_burst"`
+}
+
+// LogConfig controls the standard logger.
+type LogConfig struct {
+	Output       string `yaml:"output"` // "stderr", "stdout" or a file path
+	UTC          bool   `yaml:"utc"`
+	Microseconds bool   `yaml:"microseconds"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		Listen: ":50051",
+		Exchanges: ExchangesConfig{
+			Binance: ExchangeConfig{
+				Enabled:     true,
+				RESTURL:     binance.DefaultRESTURL,
+				WSURL:       binance.DefaultWSURL,
+				HTTPTimeout: 30 * time.Second,
+			},
+			Bybit: ExchangeConfig{
+				Enabled:     true,
+				RESTURL:     bybit.DefaultRESTURL,
+				WSURL:       bybit.DefaultWSURL,
+				HTTPTimeout: 30 * time.Second,
+				Category:    bybit.DefaultCategory,
+			},
+			OKX: ExchangeConfig{
+				Enabled:     true,
+				RESTURL:     okx.DefaultRESTURL,
+				WSURL:       okx.DefaultWSURL,
+				HTTPTimeout: 30 * time.Second,
+			},
+		},
+		Markets: MarketsConfig{
+			Defaults: MarketDefaults{Backpressure: "drop_newest"},
+		},
+		Buffers: BuffersConfig{Stream: 64},
+		History: HistoryConfig{Depth: aggregator.MaxRequestLimit},
+		WAL: WALConfig{
+			CheckpointInterval: aggregator.DefaultCheckpointInterval,
+			SyncInterval:       wal.DefaultSyncInterval,
+		},
+		Alerts: AlertsConfig{
+			Cooldown:       time.Minute,
+			WebhookTimeout: 10 * time.Second,
+			WebhookRetries: 3,
+		},
+		Shutdown: ShutdownConfig{
+			Timeout:    15 * time.Second,
+			RetryDelay: 3 * time.Second,
+		},
+		Metrics: MetricsConfig{Path: "/metrics"},
+		Auth:    AuthConfig{JWT: JWTConfig{MarketsClaim: auth.DefaultMarketsClaim}},
+		Limits: LimitsConfig{
+			StreamsPerClient:    64,
+			MarketsPerClient:    32,
+			MaxMarkets:          256,
+			NewMarketsPerMinute: 20,
+			NewMarketsBurst:     10,
+		},
+		Log: LogConfig{Output: "stderr"},
+	}
+}
+
+// loadConfig builds the effective configuration from defaults, the config
+// file, the environment and the command-line args (without the program name
+// or subcommand), then validates it.
+func loadConfig(args []string) (Config, error) {
+	cfg := defaultConfig()
+
+	fs := flag.NewFlagSet("srv", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv("CANDLES_CONFIG"), "path to a YAML config file")
+	listen := fs.String("listen", "", "gRPC listen address")
+	exchanges := fs.String("exchanges", "", "comma-separated exchanges to enable (binance,bybit,okx)")
+	streamBuf := fs.Int("stream-buffer", 0, "per-stream candle buffer size")
+	depth := fs.Int("history-depth", 0, "finalized candles kept per market")
+	policy := fs.String("backpressure", "", "default backpressure policy")
+	logOut := fs.String("log-output", "", `log destination: "stderr", "stdout" or a file path`)
+	warm := fs.String("warm", "", "comma-separated SYMBOL:INTERVAL markets to pre-warm")
+	baseInterval := fs.String("base-interval", "", "resample longer intervals from this one (empty: off)")
+	gapFill := fs.Bool("gap-fill", false, "synthesize flat candles for periods with no trades")
+	lateWindow := fs.Duration("late-window", 0, "merge late exchange updates into revisions for this long after a period ends")
+	storePath := fs.String("store", "", "candle store file (empty: memory only)")
+	walDir := fs.String("wal-dir", "", "write-ahead log directory (empty: disabled)")
+	alertsPath := fs.String("alerts-path", "", "alert rules file (empty: memory only)")
+	metricsListen := fs.String("metrics-listen", "", `Prometheus endpoint address ("" keeps the config value, "off" disables)`)
+	httpListen := fs.String("http-listen", "", `HTTP/JSON gateway address ("" keeps the config value, "off" disables)`)
+	shutdownTimeout := fs.Duration("shutdown-timeout", 0, "deadline for draining on SIGINT/SIGTERM")
+	tlsCert := fs.String("tls-cert", "", "TLS certificate file (empty: plaintext)")
+	tlsKey := fs.String("tls-key", "", "TLS private key file")
+	tlsClientCA := fs.String("tls-client-ca", "", "CA file client certificates must be signed by (enables mTLS)")
+	jwksFile := fs.String("jwks", "", "JWKS file bearer tokens are verified against (empty: no JWTs)")
+	streamsPerClient := fs.Int("streams-per-client", 0, "concurrent streams per client (0: unlimited)")
+	marketsPerClient := fs.Int("markets-per-client", 0, "distinct markets streamed per client (0: unlimited)")
+	maxMarkets := fs.Int("max-markets", 0, "markets the server runs (0: unlimited)")
+	newMarketsPerMinute := fs.Int("new-markets-per-minute", 0, "markets started per minute (0: unlimited)")
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+	if fs.NArg() > 0 {
+		return cfg, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	if *path != "" {
+		if err := readConfigFile(*path, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+	if err := applyEnv(&cfg); err != nil {
+		return cfg, err
+	}
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "listen":
+			cfg.Listen = *listen
+		case "exchanges":
+			err = errors.Join(err, enableOnly(&cfg, *exchanges))
+		case "stream-buffer":
+			cfg.Buffers.Stream = *streamBuf
+		case "history-depth":
+			cfg.History.Depth = *depth
+		case "backpressure":
+			cfg.Markets.Defaults.Backpressure = *policy
+		case "log-output":
+			cfg.Log.Output = *logOut
+		case "warm":
+			cfg.Markets.Warm = splitList(*warm)
+		case "base-interval":
+			cfg.Markets.BaseInterval = *baseInterval
+		case "gap-fill":
+			cfg.Markets.GapFill = *gapFill
+		case "late-window":
+			cfg.Markets.LateWindow = *lateWindow
+		case "store":
+			cfg.Store.Path = *storePath
+		case "wal-dir":
+			cfg.WAL.Dir = *walDir
+		case "alerts-path":
+			cfg.Alerts.Path = *alertsPath
+		case "metrics-listen":
+			cfg.Metrics.Listen = *metricsListen
+		case "http-listen":
+			cfg.HTTP.Listen = *httpListen
+		case "shutdown-timeout":
+			cfg.Shutdown.Timeout = *shutdownTimeout
+		case "tls-cert":
+			cfg.TLS.CertFile = *tlsCert
+		case "tls-key":
+			cfg.TLS.KeyFile = *tlsKey
+		case "tls-client-ca":
+			cfg.TLS.ClientCAFile = *tlsClientCA
+		case "jwks":
+			cfg.Auth.JWT.JWKSFile = *jwksFile
+		case "streams-per-client":
+			cfg.Limits.StreamsPerClient = *streamsPerClient
+		case "markets-per-client":
+			cfg.Limits.MarketsPerClient = *marketsPerClient
+		case "max-markets":
+			cfg.Limits.MaxMarkets = *maxMarkets
+		case "new-markets-per-minute":
+			cfg.Limits.NewMarketsPerMinute = *newMarketsPerMinute
+		}
+	})
+	if err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.validate()
+}
+
+func readConfigFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && err != io.EOF {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv overrides cfg from CANDLES_* environment variables.
+func applyEnv(cfg *Config) error {
+	var errs []error
+	str := func(key string, dst *string) {
+		if v, ok := os.LookupEnv(key); ok {
+			*dst = v
+		}
+	}
+	num := func(key string, dst *int) {
+		if v, ok := os.LookupEnv(key); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				return
+			}
+			*dst = n
+		}
+	}
+	duration := func(key string, dst *time.Duration) {
+		if v, ok := os.LookupEnv(key); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				return
+			}
+			*dst = d
+		}
+	}
+	boolean := func(key string, dst *bool) {
+		if v, ok := os.LookupEnv(key); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				return
+			}
+			*dst = b
+		}
+	}
+
+	str("CANDLES_LISTEN", &cfg.Listen)
+	num("CANDLES_STREAM_BUFFER", &cfg.Buffers.Stream)
+	num("CANDLES_HISTORY_DEPTH", &cfg.History.Depth)
+	str("CANDLES_BACKPRESSURE", &cfg.Markets.Defaults.Backpressure)
+	str("CANDLES_BASE_INTERVAL", &cfg.Markets.BaseInterval)
+	boolean("CANDLES_GAP_FILL", &cfg.Markets.GapFill)
+	duration("CANDLES_LATE_WINDOW", &cfg.Markets.LateWindow)
+	str("CANDLES_LOG_OUTPUT", &cfg.Log.Output)
+	str("CANDLES_STORE_PATH", &cfg.Store.Path)
+	str("CANDLES_WAL_DIR", &cfg.WAL.Dir)
+	str("CANDLES_ALERTS_PATH", &cfg.Alerts.Path)
+	duration("CANDLES_SHUTDOWN_TIMEOUT", &cfg.Shutdown.Timeout)
+	str("CANDLES_METRICS_LISTEN", &cfg.Metrics.Listen)
+	str("CANDLES_HTTP_LISTEN", &cfg.HTTP.Listen)
+	str("CANDLES_TLS_CERT_FILE", &cfg.TLS.CertFile)
+	str("CANDLES_TLS_KEY_FILE", &cfg.TLS.KeyFile)
+	str("CANDLES_TLS_CLIENT_CA_FILE", &cfg.TLS.ClientCAFile)
+	str("CANDLES_AUTH_JWKS_FILE", &cfg.Auth.JWT.JWKSFile)
+	num("CANDLES_LIMITS_STREAMS_PER_CLIENT", &cfg.Limits.StreamsPerClient)
+	num("CANDLES_LIMITS_MARKETS_PER_CLIENT", &cfg.Limits.MarketsPerClient)
+	num("CANDLES_LIMITS_MAX_MARKETS", &cfg.Limits.MaxMarkets)
+	num("CANDLES_LIMITS_NEW_MARKETS_PER_MINUTE", &cfg.Limits.NewMarketsPerMinute)
+	num("CANDLES_LIMITS_NEW_MARKETS_BURST", &cfg.Limits.NewMarketsBurst)
+	all := cfg.Exchanges.all()
+	for _, name := range exchangeNames {
+		ex := all[name]
+		prefix := "CANDLES_" + strings.ToUpper(name) + "_"
+		boolean(prefix+"ENABLED", &ex.Enabled)
+		str(prefix+"REST_URL", &ex.RESTURL)
+		str(prefix+"WS_URL", &ex.WSURL)
+	}
+	if v, ok := os.LookupEnv("CANDLES_WARM"); ok {
+		cfg.Markets.Warm = splitList(v)
+	}
+	if v, ok := os.LookupEnv("CANDLES_EXCHANGES"); ok {
+		errs = append(errs, enableOnly(cfg, v))
+	}
+	return errors.Join(errs...)
+}
+
+// splitList splits a comma-separated list, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// enableOnly enables exactly the exchanges named in the comma-separated list.
+func enableOnly(cfg *Config, list string) error {
+	all := cfg.Exchanges.all()
+	want := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := all[name]; !ok {
+			return fmt.Errorf("exchanges: unknown exchange %q", name)
+		}
+		want[name] = true
+	}
+	for name, ex := range all {
+		ex.Enabled = want[name]
+	}
+	return nil
+}
+
+// exchangeNames lists the supported exchanges in a stable order.
+var exchangeNames = []string{"binance", "bybit", "okx"}
+
+// all returns the exchange configs keyed by name.
+func (e *ExchangesConfig) all() map[string]*ExchangeConfig {
+	return map[string]*ExchangeConfig{
+		"binance": &e.Binance,
+		"bybit":   &e.Bybit,
+		"okx":     &e.OKX,
+	}
+}
+
+// validate reports every problem with cfg at once.
+func (cfg *Config) validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if _, _, err := net.SplitHostPort(cfg.Listen); err != nil {
+		fail("listen: %q is not a host:port address", cfg.Listen)
+	}
+
+	enabled := 0
+	all := cfg.Exchanges.all()
+	for _, name := range exchangeNames {
+		ex := all[name]
+		if !ex.Enabled {
+			continue
+		}
+		enabled++
+		if err := checkURL(ex.RESTURL, "http", "https"); err != nil {
+			fail("exchanges.%s.rest_url: %v", name, err)
+		}
+		if err := checkURL(ex.WSURL, "ws", "wss"); err != nil {
+			fail("exchanges.%s.ws_url: %v", name, err)
+		}
+		if ex.HTTPTimeout <= 0 {
+			fail("exchanges.%s.http_timeout: must be positive", name)
+		}
+	}
+	if enabled == 0 {
+		fail("exchanges: at least one exchange must be enabled")
+	}
+	switch cfg.Exchanges.Bybit.Category {
+	case "linear", "spot", "inverse":
+	default:
+		fail("exchanges.bybit.category: %q is not one of linear, spot, inverse", cfg.Exchanges.Bybit.Category)
+	}
+
+	if _, err := parsePolicy(cfg.Markets.Defaults.Backpressure); err != nil {
+		fail("markets.defaults.backpressure: %v", err)
+	}
+	if cfg.Markets.BaseInterval != "" {
+		if _, err := candle.IntervalDuration(cfg.Markets.BaseInterval); err != nil {
+			fail("markets.base_interval: %v", err)
+		}
+	}
+	if cfg.Markets.LateWindow < 0 {
+		fail("markets.late_window: must not be negative")
+	}
+	cfg.Markets.Divergence.validate("markets.divergence", fail)
+	for symbol, sc := range cfg.Markets.Symbols {
+		sc.validate(fmt.Sprintf("markets.symbols.%s", symbol), all, fail)
+	}
+	for i, m := range cfg.Markets.Warm {
+		symbol, interval, ok := strings.Cut(m, ":")
+		if !ok || symbol == "" {
+			fail("markets.warm[%d]: %q is not SYMBOL:INTERVAL", i, m)
+			continue
+		}
+		if _, err := candle.IntervalDuration(interval); err != nil {
+			fail("markets.warm[%d]: %v", i, err)
+		}
+	}
+	if cfg.Buffers.Stream <= 0 {
+		fail("buffers.stream: must be positive, got %d", cfg.Buffers.Stream)
+	}
+	if cfg.History.Depth <= 0 {
+		fail("history.depth: must be positive, got %d", cfg.History.Depth)
+	}
+	if err := cfg.Store.Retention.Validate(); err != nil {
+		fail("store.retention: %v", err)
+	}
+	if cfg.WAL.CheckpointInterval <= 0 {
+		fail("wal.checkpoint_interval: must be positive")
+	}
+	if cfg.WAL.SyncInterval <= 0 {
+		fail("wal.sync_interval: must be positive")
+	}
+	if cfg.Alerts.Cooldown < minAlertCooldown {
+		fail("alerts.cooldown: must be at least %v", minAlertCooldown)
+	}
+	if cfg.Alerts.WebhookTimeout <= 0 {
+		fail("alerts.webhook_timeout: must be positive")
+	}
+	if cfg.Alerts.WebhookRetries < 0 {
+		fail("alerts.webhook_retries: must not be negative")
+	}
+	for i, n := range cfg.Alerts.WebhookAllowedNetworks {
+		if _, err := netip.ParsePrefix(n); err != nil {
+			fail("alerts.webhook_allowed_networks[%d]: %v", i, err)
+		}
+	}
+	if cfg.Shutdown.Timeout <= 0 {
+		fail("shutdown.timeout: must be positive")
+	}
+	if cfg.Shutdown.RetryDelay < 0 {
+		fail("shutdown.retry_delay: must not be negative")
+	}
+	if cfg.Metrics.Listen == "off" {
+		cfg.Metrics.Listen = ""
+	}
+	if cfg.Metrics.Listen != "" {
+		if _, _, err := net.SplitHostPort(cfg.Metrics.Listen); err != nil {
+			fail("metrics.listen: %q is not a host:port address", cfg.Metrics.Listen)
+		}
+		if !strings.HasPrefix(cfg.Metrics.Path, "/") {
+			fail("metrics.path: %q must start with /", cfg.Metrics.Path)
+		}
+	}
+	if cfg.HTTP.Listen == "off" {
+		cfg.HTTP.Listen = ""
+	}
+	if cfg.HTTP.Listen != "" {
+		if _, _, err := net.SplitHostPort(cfg.HTTP.Listen); err != nil {
+			fail("http.listen: %q is not a host:port address", cfg.HTTP.Listen)
+		}
+	}
+	if (cfg.TLS.CertFile == "") != (cfg.TLS.KeyFile == "") {
+		fail("tls: cert_file and key_file must be set together")
+	}
+	if cfg.TLS.ClientCAFile != "" && cfg.TLS.CertFile == "" {
+		fail("tls.client_ca_file: requires cert_file and key_file")
+	}
+	cfg.Auth.validate(fail)
+	cfg.Limits.validate(fail)
+	if cfg.Log.Output == "" {
+		fail("log.output: must not be empty")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// validate checks sc against the exchanges in all, reporting problems
+// under the config path at.
+func (sc SymbolConfig) validate(at string, all map[string]*ExchangeConfig, fail func(string, ...any)) {
+	n := 0
+	for _, ex := range all {
+		if ex.Enabled {
+			n++
+		}
+	}
+	taking := make(map[string]bool)
+	for _, name := range sc.Exchanges {
+		ex, ok := all[name]
+		switch {
+		case !ok:
+			fail("%s.exchanges: unknown exchange %q", at, name)
+		case !ex.Enabled:
+			fail("%s.exchanges: %s is not enabled", at, name)
+		case taking[name]:
+			fail("%s.exchanges: %s listed twice", at, name)
+		}
+		taking[name] = true
+	}
+	if len(sc.Exchanges) > 0 {
+		n = len(sc.Exchanges)
+	}
+	if sc.Quorum < 0 || sc.Quorum > n {
+		fail("%s.quorum: must be between 1 and %d, or 0 for all of them, got %d", at, n, sc.Quorum)
+	}
+	for name, w := range sc.Weights {
+		ex, ok := all[name]
+		switch {
+		case !ok || !ex.Enabled || (len(sc.Exchanges) > 0 && !taking[name]):
+			fail("%s.weights: %s does not take part", at, name)
+		case w < 0:
+			fail("%s.weights.%s: must not be negative", at, name)
+		}
+	}
+	if sc.Divergence != nil {
+		sc.Divergence.validate(at+".divergence", fail)
+	}
+}
+
+// validate checks the API keys and JWT settings.
+func (ac AuthConfig) validate(fail func(string, ...any)) {
+	names := make(map[string]bool)
+	for i, k := range ac.APIKeys {
+		at := fmt.Sprintf("auth.api_keys[%d]", i)
+		switch {
+		case k.Name == "":
+			fail("%s.name: must not be empty", at)
+		case names[k.Name]:
+			fail("%s.name: %q listed twice", at, k.Name)
+		}
+		names[k.Name] = true
+		switch {
+		case (k.Key == "") == (k.KeySHA256 == ""):
+			fail("%s: set exactly one of key and key_sha256", at)
+		case k.KeySHA256 != "":
+			if _, err := auth.ParseKeyHash(k.KeySHA256); err != nil {
+				fail("%s.key_sha256: %v", at, err)
+			}
+		}
+		for _, pat := range k.Markets {
+			if err := auth.ValidatePattern(pat); err != nil {
+				fail("%s.markets: %v", at, err)
+			}
+		}
+	}
+	if ac.JWT.JWKSFile != "" && ac.JWT.MarketsClaim == "" {
+		fail("auth.jwt.markets_claim: must not be empty")
+	}
+}
+
+// validate reports negative limits and a rate without a burst.
+func (lc LimitsConfig) validate(fail func(string, ...any)) {
+	for _, l := range []struct {
+		name string
+		v    int
+	}{
+		{"streams_per_client", lc.StreamsPerClient},
+		{"markets_per_client", lc.MarketsPerClient},
+		{"max_markets", lc.MaxMarkets},
+		{"new_markets_per_minute", lc.NewMarketsPerMinute},
+		{"new_markets_burst", lc.NewMarketsBurst},
+	} {
+		if l.v < 0 {
+			fail("limits.%s: must not be negative", l.name)
+		}
+	}
+	if lc.NewMarketsPerMinute > 0 && lc.NewMarketsBurst < 1 {
+		fail("limits.new_markets_burst: must be at least 1 with new_markets_per_minute set")
+	}
+}
+
+// serverTLS loads the certificates of cfg, or returns nil if TLS is off.
+func (cfg TLSConfig) serverTLS() (*tls.Config, error) {
+	if cfg.CertFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	tc := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls: %s holds no PEM certificates", cfg.ClientCAFile)
+		}
+		tc.ClientCAs = pool
+		tc.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tc, nil
+}
+
+// validate reports negative thresholds under the config path at.
+func (dc DivergenceConfig) validate(at string, fail func(string, ...any)) {
+	if dc.SpreadBps < 0 {
+		fail("%s.spread_bps: must not be negative", at)
+	}
+	if dc.DeviationBps < 0 {
+		fail("%s.deviation_bps: must not be negative", at)
+	}
+}
+
+// thresholds converts dc for the aggregator.
+func (dc DivergenceConfig) thresholds() aggregator.DivergenceThresholds {
+	return aggregator.DivergenceThresholds{SpreadBps: dc.SpreadBps, DeviationBps: dc.DeviationBps}
+}
+
+// aggregatorMarkets converts the per-symbol settings for the aggregator.
+func (m *MarketsConfig) aggregatorMarkets() map[string]aggregator.MarketConfig {
+	out := make(map[string]aggregator.MarketConfig, len(m.Symbols))
+	for symbol, sc := range m.Symbols {
+		mc := aggregator.MarketConfig{
+			Exchanges: sc.Exchanges,
+			Quorum:    sc.Quorum,
+			Weights:   sc.Weights,
+		}
+		if sc.Divergence != nil {
+			th := sc.Divergence.thresholds()
+			mc.Divergence = &th
+		}
+		out[symbol] = mc
+	}
+	return out
+}
+
+func checkURL(raw string, schemes ...string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	for _, s := range schemes {
+		if u.Scheme == s && u.Host != "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q must be an absolute %s URL", raw, strings.Join(schemes, "/"))
+}
+
+// parsePolicy maps a config name such as "drop_oldest" to its enum value.
+func parsePolicy(name string) (pb.BackpressurePolicy, error) {
+	v, ok := pb.BackpressurePolicy_value["BACKPRESSURE_POLICY_"+strings.ToUpper(name)]
+	if !ok || v == int32(pb.BackpressurePolicy_BACKPRESSURE_POLICY_UNSPECIFIED) {
+		return 0, fmt.Errorf("unknown policy %q", name)
+	}
+	return pb.BackpressurePolicy(v), nil
+}
+
+// setupLogging points the standard logger at cfg.Output.
+func (cfg LogConfig) setupLogging() error {
+	flags := log.LstdFlags
+	if cfg.UTC {
+		flags |= log.LUTC
+	}
+	if cfg.Microseconds {
+		flags |= log.Lmicroseconds
+	}
+	log.SetFlags(flags)
+
+	switch cfg.Output {
+	case "stderr":
+		log.SetOutput(os.Stderr)
+	case "stdout":
+		log.SetOutput(os.Stdout)
+	default:
+		f, err := os.OpenFile(cfg.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("log.output: %w", err)
+		}
+		log.SetOutput(f)
+	}
+	return nil
+}
+
+// adapters builds the enabled exchange adapters.
+func (cfg *Config) adapters() []adapter.Adapter {
+	var out []adapter.Adapter
+	ex := cfg.Exchanges
+	if ex.Binance.Enabled {
+		out = append(out, binance.NewWithOptions(binance.Options{
+			RESTURL:     ex.Binance.RESTURL,
+			WSURL:       ex.Binance.WSURL,
+			HTTPTimeout: ex.Binance.HTTPTimeout,
+		}))
+	}
+	if ex.Bybit.Enabled {
+		out = append(out, bybit.NewWithOptions(bybit.Options{
+			RESTURL:     ex.Bybit.RESTURL,
+			WSURL:       ex.Bybit.WSURL,
+			Category:    ex.Bybit.Category,
+			HTTPTimeout: ex.Bybit.HTTPTimeout,
+		}))
+	}
+	if ex.OKX.Enabled {
+		out = append(out, okx.NewWithOptions(okx.Options{
+			RESTURL:     ex.OKX.RESTURL,
+			WSURL:       ex.OKX.WSURL,
+			HTTPTimeout: ex.OKX.HTTPTimeout,
+		}))
+	}
+	return out
+}
+
+// dump writes cfg as YAML.  API keys are written as their key_sha256, so
+// the output can be shared and still loads as the same config.
+func (cfg *Config) dump(w io.Writer) error {
+	out := *cfg
+	out.Auth.APIKeys = slices.Clone(cfg.Auth.APIKeys)
+	for i, k := range out.Auth.APIKeys {
+		if k.Key != "" {
+			h := auth.HashKey(k.Key)
+			out.Auth.APIKeys[i].Key, out.Auth.APIKeys[i].KeySHA256 = "", hex.EncodeToString(h[:])
+		}
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&out); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestDumpHashesKeys checks that dump-config prints API keys as their
+// SHA-256, leaving the config itself alone.
+func TestDumpHashesKeys(t *testing.T) {
+	var cfg Config
+	cfg.Auth.APIKeys = []APIKeyConfig{
+		{Name: "alice", Key: "s3cret"},
+		{Name: "bob", KeySHA256: "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"},
+	}
+	var buf bytes.Buffer
+	if err := cfg.dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "s3cret") {
+		t.Errorf("dump prints the key:\n%s", out)
+	}
+	for _, h := range []string{
+		"key_sha256: 1ec1c26b50d5d3c58d9583181af8076655fe00756bf7285940ba3670f99fcba0", // sha256("s3cret")
+		"key_sha256: 2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b",
+	} {
+		if !strings.Contains(out, h) {
+			t.Errorf("dump lacks %q:\n%s", h, out)
+		}
+	}
+	if cfg.Auth.APIKeys[0].Key != "s3cret" || cfg.Auth.APIKeys[0].KeySHA256 != "" {
+		t.Errorf("dump changed the config: %+v", cfg.Auth.APIKeys[0])
+	}
+}
+
+func TestSymbolQuorum(t *testing.T) {
+	all := map[string]*ExchangeConfig{"binance": {Enabled: true}, "bybit": {Enabled: true}, "okx": {Enabled: true}}
+	for _, tc := range []struct {
+		sc   SymbolConfig
+		want string // part of the error; empty for none
+	}{
+		{SymbolConfig{}, ""}, // all of them
+		{SymbolConfig{Quorum: 3}, ""},
+		{SymbolConfig{Quorum: 4}, "between 1 and 3, or 0 for all of them, got 4"},
+		{SymbolConfig{Quorum: -1}, "got -1"},
+		{SymbolConfig{Exchanges: []string{"binance", "okx"}, Quorum: 2}, ""},
+		{SymbolConfig{Exchanges: []string{"binance", "okx"}, Quorum: 3}, "between 1 and 2"},
+	} {
+		var errs []string
+		tc.sc.validate("symbols.BTCUSDT", all, func(format string, args ...any) {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		})
+		got := strings.Join(errs, "; ")
+		if tc.want == "" && got != "" || !strings.Contains(got, tc.want) {
+			t.Errorf("%+v: %q, want %q", tc.sc, got, tc.want)
+		}
+	}
+}
+
+// TestListenersOff checks that the metrics endpoint and the gateway are
+// only served when configured.
+func TestListenersOff(t *testing.T) {
+	t.Setenv("CANDLES_CONFIG", "")
+	t.Setenv("CANDLES_METRICS_LISTEN", "")
+	t.Setenv("CANDLES_HTTP_LISTEN", "")
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Metrics.Listen != "" || cfg.HTTP.Listen != "" {
+		t.Errorf("by default metrics on %q, gateway on %q; want both off", cfg.Metrics.Listen, cfg.HTTP.Listen)
+	}
+
+	cfg, err = loadConfig([]string{"-metrics-listen", ":9090", "-http-listen", ":8080"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Metrics.Listen != ":9090" || cfg.HTTP.Listen != ":8080" {
+		t.Errorf("metrics on %q, gateway on %q; want :9090 and :8080", cfg.Metrics.Listen, cfg.HTTP.Listen)
+	}
+}
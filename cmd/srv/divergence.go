@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"log"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/yitech/candles/aggregator"
+	"github.com/yitech/candles/metrics"
+	pb "github.com/yitech/candles/model/protobuf"
+)
+
+// Divergence streams the aggregator's cross-exchange divergence of a
+// market. Up to buffers.stream measurements are buffered per stream; when
+// the client falls behind, new ones are dropped (the next update of the
+// period measures it again) and counted like a DROP_NEWEST candle stream's.
+func (s *server) Divergence(req *pb.DivergenceRequest, stream pb.CandleService_DivergenceServer) error {
+	if err := authorize(stream.Context(), req.Symbol, req.Interval); err != nil {
+		return err
+	}
+	select {
+	case <-s.drain:
+		return s.shutdownStatus()
+	default:
+	}
+	market := req.Symbol + ":" + req.Interval
+	release, err := s.limits.acquire(stream.Context(), market)
+	if err != nil {
+		return err
+	}
+	defer release()
+	opened, err := s.limits.open(req.Symbol, req.Interval)
+	if err != nil {
+		return err
+	}
+	log.Printf("divergence: symbol=%s interval=%s", req.Symbol, req.Interval)
+
+	drops := metrics.StreamDrops.WithLabelValues(market, pb.BackpressurePolicy_BACKPRESSURE_POLICY_DROP_NEWEST.String())
+	ch := make(chan *pb.MarketDivergence, s.streamBuf)
+	tok, err := s.agg.SubscribeDivergence(req.Symbol, req.Interval, func(d *aggregator.Divergence) {
+		select {
+		case ch <- divergenceToProto(d):
+		default:
+			drops.Inc()
+		}
+	})
+	opened()
+	switch {
+	case errors.Is(err, aggregator.ErrResampled):
+		return status.Errorf(codes.InvalidArgument, "%s is resampled; request its base interval", market)
+	case err != nil:
+		return status.Errorf(codes.Internal, "aggregator subscribe: %v", err)
+	}
+	defer tok.Unsubscribe()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			log.Printf("disconnect: divergence symbol=%s interval=%s", req.Symbol, req.Interval)
+			return stream.Context().Err()
+		case <-s.flushed:
+			return s.shutdownStatus()
+		case d := <-ch:
+			if err := stream.Send(d); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func divergenceToProto(d *aggregator.Divergence) *pb.MarketDivergence {
+	pd := &pb.MarketDivergence{
+		Symbol:    d.Symbol,
+		Interval:  d.Interval,
+		OpenTime:  d.OpenTime,
+		IsClosed:  d.Closed,
+		Median:    d.Median,
+		Spread:    d.Spread,
+		SpreadBps: d.SpreadBps,
+		Alert:     d.Alert,
+		Exchanges: make([]*pb.ExchangeDivergence, len(d.Exchanges)),
+	}
+	for i, e := range d.Exchanges {
+		pd.Exchanges[i] = &pb.ExchangeDivergence{
+			Exchange:     e.Exchange,
+			Close:        e.Close,
+			DeviationBps: e.DeviationBps,
+			LagMs:        e.LagMs,
+			IsClosed:     e.Closed,
+		}
+	}
+	return pd
+}
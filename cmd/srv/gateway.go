@@ -0,0 +1,521 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/yitech/candles/auth"
+	pb "github.com/yitech/candles/model/protobuf"
+)
+
+// The gateway serves CandleService as HTTP/JSON on the routes given by the
+// google.api.http options in proto/candle.proto.  Handlers call the same server methods as gRPC,
+// so both front ends share validation, aggregator subscriptions,
+// backpressure and the drain on shutdown.  Messages are encoded with
+// protojson; server streams are sent as Server-Sent Events, one message
+// per event, and end with an "error" event carrying the status if the RPC
+// fails after the stream started.
+
+// jsonOptions is how the gateway and alert webhooks encode messages: the
+// .proto field names, with zero values included.
+var jsonOptions = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+
+const (
+	// maxBodyBytes bounds a request body.
+	maxBodyBytes = 1 << 20
+
+	// sseKeepalive is how often an event stream gets a comment line, so
+	// proxies keep it open.  The first one, after sseCommit, also commits
+	// the response of a stream with nothing to send yet.
+	sseKeepalive = 15 * time.Second
+	sseCommit    = time.Second
+)
+
+// newGateway returns the handler serving s over HTTP/JSON, and the
+// WebSocket endpoint on /v1/ws, to callers authenticated by authn (nil for
+// anyone).  Responses allow the origins listed in corsOrigins ("*" for
+// any).  It panics if an RPC of CandleService has no handler or no route
+// the gateway can serve.
+func newGateway(s *server, corsOrigins []string, authn auth.Authenticator) http.Handler {
+	handlers := map[string]http.HandlerFunc{
+		"Subscribe":   stream(s, s.Subscribe, candleEventID, "resume_token"),
+		"GetCandles":  unary(s.GetCandles),
+		"ListMarkets": unary(s.ListMarkets),
+		"GetStatus":   unary(s.GetStatus),
+		"Divergence":  stream(s, s.Divergence, nil, ""),
+		"CreateAlert": unary(s.CreateAlert),
+		"GetAlert":    unary(s.GetAlert),
+		"ListAlerts":  unary(s.ListAlerts),
+		"UpdateAlert": unary(s.UpdateAlert),
+		"DeleteAlert": unary(s.DeleteAlert),
+		"WatchAlerts": stream(s, s.WatchAlerts, nil, ""),
+	}
+
+	mux := http.NewServeMux()
+	methods := pb.File_candle_proto.Services().ByName("CandleService").Methods()
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		h, ok := handlers[string(md.Name())]
+		if !ok {
+			panic("gateway: no handler for " + md.Name())
+		}
+		pattern, err := httpPattern(md)
+		if err != nil {
+			panic(fmt.Sprintf("gateway: %s: %v", md.Name(), err))
+		}
+		mux.HandleFunc(pattern, h)
+		delete(handlers, string(md.Name()))
+	}
+	for rpc := range handlers {
+		panic("gateway: handler for unknown RPC " + rpc)
+	}
+	mux.HandleFunc("GET /v1/ws", newWebSocketHandler(s, corsOrigins))
+	return withCORS(withAuth(withPeer(mux), authn), corsOrigins)
+}
+
+// httpPattern returns the http.ServeMux pattern of md's google.api.http
+// option.  The gateway serves one binding per RPC, with path variables
+// naming top-level fields, and the whole request as the body of a POST or
+// PUT; it reports an error for any other rule.
+func httpPattern(md protoreflect.MethodDescriptor) (string, error) {
+	rule, _ := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+	if rule == nil {
+		return "", errors.New("no google.api.http option")
+	}
+	var method, path string
+	switch p := rule.Pattern.(type) {
+	case *annotations.HttpRule_Get:
+		method, path = http.MethodGet, p.Get
+	case *annotations.HttpRule_Post:
+		method, path = http.MethodPost, p.Post
+	case *annotations.HttpRule_Put:
+		method, path = http.MethodPut, p.Put
+	case *annotations.HttpRule_Delete:
+		method, path = http.MethodDelete, p.Delete
+	default:
+		return "", fmt.Errorf("unsupported HTTP rule %v", rule)
+	}
+	hasBody := method == http.MethodPost || method == http.MethodPut
+	switch {
+	case len(rule.AdditionalBindings) > 0:
+		return "", errors.New("additional bindings are not supported")
+	case rule.ResponseBody != "":
+		return "", errors.New("response_body is not supported")
+	case hasBody && rule.Body != "*":
+		return "", fmt.Errorf("%s must take the whole request as body, not %q", method, rule.Body)
+	case !hasBody && rule.Body != "":
+		return "", fmt.Errorf("%s has no body", method)
+	}
+	fields := md.Input().Fields()
+	for _, seg := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		if !strings.HasPrefix(seg, "{") {
+			if strings.ContainsAny(seg, "{}*:=") {
+				return "", fmt.Errorf("path %s: unsupported segment %q", path, seg)
+			}
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(seg, "{"), "}")
+		if fd := fields.ByName(protoreflect.Name(name)); fd == nil || fd.IsList() || fd.Message() != nil {
+			return "", fmt.Errorf("path %s: %q is not a scalar field of %s", path, name, md.Input().Name())
+		}
+	}
+	return method + " " + path, nil
+}
+
+// withPeer puts the caller's address in the request context, as gRPC
+// does, so limits tell gateway clients apart like gRPC ones.
+func withPeer(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ap, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+			r = r.WithContext(peer.NewContext(r.Context(), &peer.Peer{Addr: net.TCPAddrFromAddrPort(ap)}))
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// serveGateway starts serving h on addr, over TLS if tc is set.
+func serveGateway(addr string, h http.Handler, tc *tls.Config) *http.Server {
+	srv := &http.Server{Addr: addr, Handler: h, TLSConfig: tc, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		log.Printf("HTTP gateway listening on %s", addr)
+		var err error
+		if tc != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("warn: HTTP gateway: %v", err)
+		}
+	}()
+	return srv
+}
+
+// withCORS lets browsers on origins call h.
+func withCORS(h http.Handler, origins []string) http.Handler {
+	if len(origins) == 0 {
+		return h
+	}
+	anyOrigin := slices.Contains(origins, "*")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" && (anyOrigin || slices.Contains(origins, origin)) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+			if r.Method == http.MethodOptions {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Last-Event-ID, X-API-Key")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// unary serves a unary RPC.
+func unary[Req any, Resp proto.Message, PReq interface {
+	*Req
+	proto.Message
+}](call func(context.Context, PReq) (Resp, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := PReq(new(Req))
+		if err := decodeRequest(r, req, ""); err != nil {
+			writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+			return
+		}
+		resp, err := call(r.Context(), req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// stream serves a server-streaming RPC as Server-Sent Events.  id, if
+// set, gives each event's id; a reconnecting EventSource sends the last
+// one back in Last-Event-ID, which sets the request field resumeField.
+func stream[Req, T any, PReq interface {
+	*Req
+	proto.Message
+}](s *server, call func(PReq, grpc.ServerStreamingServer[T]) error, id func(*T) string, resumeField string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := PReq(new(Req))
+		if err := decodeRequest(r, req, resumeField); err != nil {
+			writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+			return
+		}
+		st := &sseStream[T]{
+			ctx:   r.Context(),
+			w:     w,
+			rc:    http.NewResponseController(w),
+			id:    id,
+			retry: s.retryDelay,
+		}
+		stop := st.keepalive()
+		err := call(req, st)
+		stop()
+		if err != nil && r.Context().Err() == nil {
+			st.fail(err)
+		}
+	}
+}
+
+// candleEventID is a candle's Seq, the resume token of a Subscribe stream.
+func candleEventID(c *pb.Candle) string {
+	return strconv.FormatUint(c.Seq, 10)
+}
+
+// sseStream is a grpc.ServerStreamingServer writing Server-Sent Events.
+// The response is committed with the RPC's header, its first event or the
+// first keepalive; until then a failing RPC still gets an HTTP error
+// status.
+type sseStream[T any] struct {
+	ctx   context.Context
+	w     http.ResponseWriter
+	rc    *http.ResponseController
+	id    func(*T) string
+	retry time.Duration // reconnection time sent to the client
+
+	mu      sync.Mutex
+	started bool
+}
+
+func (st *sseStream[T]) Send(m *T) error {
+	data, err := jsonOptions.Marshal(any(m).(proto.Message))
+	if err != nil {
+		return status.Errorf(codes.Internal, "encode: %v", err)
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.start()
+	if st.id != nil {
+		fmt.Fprintf(st.w, "id: %s\n", st.id(m))
+	}
+	if _, err := fmt.Fprintf(st.w, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	return st.rc.Flush()
+}
+
+// start commits the response (called under lock).
+func (st *sseStream[T]) start() {
+	if st.started {
+		return
+	}
+	st.started = true
+	h := st.w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	st.w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(st.w, "retry: %d\n\n", st.retry.Milliseconds())
+}
+
+func (st *sseStream[T]) SendHeader(metadata.MD) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.start()
+	return st.rc.Flush()
+}
+
+// keepalive writes a comment line after sseCommit, then every
+// sseKeepalive, until the returned function is called.
+func (st *sseStream[T]) keepalive() (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTimer(sseCommit)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+			}
+			st.mu.Lock()
+			st.start()
+			io.WriteString(st.w, ": keepalive\n\n")
+			st.rc.Flush()
+			st.mu.Unlock()
+			t.Reset(sseKeepalive)
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// fail reports err: as the HTTP status if nothing was sent yet, otherwise
+// as a final "error" event.
+func (st *sseStream[T]) fail(err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if !st.started {
+		writeError(st.w, err)
+		return
+	}
+	data, _ := jsonOptions.Marshal(status.Convert(err).Proto())
+	fmt.Fprintf(st.w, "event: error\ndata: %s\n\n", data)
+	st.rc.Flush()
+}
+
+func (st *sseStream[T]) Context() context.Context    { return st.ctx }
+func (st *sseStream[T]) SetHeader(metadata.MD) error { return nil }
+func (st *sseStream[T]) SetTrailer(metadata.MD)      {}
+func (st *sseStream[T]) SendMsg(m any) error         { return st.Send(m.(*T)) }
+func (st *sseStream[T]) RecvMsg(any) error           { return io.EOF }
+
+// decodeRequest fills m from r: the JSON body of a POST or PUT, then the
+// query string, then the route's path wildcards, each naming a field of m
+// by its .proto or JSON name.  A Last-Event-ID header sets resumeField
+// unless the query does.  The access_token parameter, a credential for
+// withAuth, is ignored whether or not authentication is on.
+func decodeRequest(r *http.Request, m proto.Message, resumeField string) error {
+	if r.Method == http.MethodPost || r.Method == http.MethodPut {
+		body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodyBytes))
+		if err != nil {
+			return err
+		}
+		if len(body) > 0 {
+			if err := protojson.Unmarshal(body, m); err != nil {
+				return fmt.Errorf("body: %w", err)
+			}
+		}
+	}
+	msg := m.ProtoReflect()
+	fields := msg.Descriptor().Fields()
+	q := r.URL.Query()
+	q.Del("access_token")
+	if id := r.Header.Get("Last-Event-ID"); id != "" && resumeField != "" && !q.Has(resumeField) {
+		q.Set(resumeField, id)
+	}
+	for key, vals := range q {
+		fd := fields.ByName(protoreflect.Name(key))
+		if fd == nil {
+			fd = fields.ByJSONName(key)
+		}
+		if fd == nil {
+			return fmt.Errorf("unknown parameter %q", key)
+		}
+		if err := setField(msg, fd, vals); err != nil {
+			return fmt.Errorf("parameter %s: %w", key, err)
+		}
+	}
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if v := r.PathValue(string(fd.Name())); v != "" {
+			if err := setField(msg, fd, []string{v}); err != nil {
+				return fmt.Errorf("path %s: %w", fd.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// setField sets a scalar field of m to the last of vals, or appends all of
+// them to a repeated one.
+func setField(m protoreflect.Message, fd protoreflect.FieldDescriptor, vals []string) error {
+	if fd.IsMap() || fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+		return errors.New("not settable from the URL; send a JSON body")
+	}
+	if fd.IsList() {
+		l := m.Mutable(fd).List()
+		for _, s := range vals {
+			v, err := parseScalar(fd, s)
+			if err != nil {
+				return err
+			}
+			l.Append(v)
+		}
+		return nil
+	}
+	v, err := parseScalar(fd, vals[len(vals)-1])
+	if err != nil {
+		return err
+	}
+	m.Set(fd, v)
+	return nil
+}
+
+func parseScalar(fd protoreflect.FieldDescriptor, s string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(s)
+		return protoreflect.ValueOfBool(b), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfInt32(int32(n)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(s, 10, 64)
+		return protoreflect.ValueOfInt64(n), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(s, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(n)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(s, 10, 64)
+		return protoreflect.ValueOfUint64(n), err
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(s, 64)
+		return protoreflect.ValueOfFloat64(f), err
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(s, 32)
+		return protoreflect.ValueOfFloat32(float32(f)), err
+	case protoreflect.EnumKind:
+		return parseEnum(fd.Enum(), s)
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported field type %s", fd.Kind())
+}
+
+// parseEnum accepts a value's full name ("BACKPRESSURE_POLICY_CONFLATE"),
+// the part after the enum's prefix ("conflate", any case) or its number.
+func parseEnum(ed protoreflect.EnumDescriptor, s string) (protoreflect.Value, error) {
+	vals := ed.Values()
+	upper := strings.ToUpper(s)
+	for i := 0; i < vals.Len(); i++ {
+		name := string(vals.Get(i).Name())
+		if name == upper || strings.HasSuffix(name, "_"+upper) {
+			return protoreflect.ValueOfEnum(vals.Get(i).Number()), nil
+		}
+	}
+	if n, err := strconv.ParseInt(s, 10, 32); err == nil && vals.ByNumber(protoreflect.EnumNumber(n)) != nil {
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
+	}
+	return protoreflect.Value{}, fmt.Errorf("%q is not a %s", s, ed.Name())
+}
+
+// writeJSON writes m with the given HTTP status.
+func writeJSON(w http.ResponseWriter, code int, m proto.Message) {
+	data, err := jsonOptions.Marshal(m)
+	if err != nil {
+		code = http.StatusInternalServerError
+		data = []byte(`{"code":13,"message":"encode response"}`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+// writeError writes err as a google.rpc.Status with the matching HTTP
+// status.
+func writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	writeJSON(w, httpStatus(st.Code()), st.Proto())
+}
+
+// httpStatus maps a gRPC code to the HTTP status the gateway answers with.
+func httpStatus(c codes.Code) int {
+	switch c {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // client closed request
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
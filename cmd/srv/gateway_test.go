@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	pb "github.com/yitech/candles/model/protobuf"
+)
+
+// TestDecodeRequestAccessToken checks that a credential in the query is
+// not taken for a field, with or without authentication.
+func TestDecodeRequestAccessToken(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/candles?symbol=BTCUSDT&interval=1m&access_token=secret", nil)
+	var req pb.CandlesRequest
+	if err := decodeRequest(r, &req, ""); err != nil {
+		t.Fatal(err)
+	}
+	if req.Symbol != "BTCUSDT" || req.Interval != "1m" {
+		t.Errorf("decoded %v", &req)
+	}
+}
+
+// TestHTTPPattern checks the routes of CandleService, and that rules the
+// gateway cannot serve are refused.
+func TestHTTPPattern(t *testing.T) {
+	methods := pb.File_candle_proto.Services().ByName("CandleService").Methods()
+	want := map[string]string{
+		"Subscribe":   "GET /v1/candles/stream",
+		"GetCandles":  "GET /v1/candles",
+		"ListMarkets": "GET /v1/markets",
+		"GetStatus":   "GET /v1/status",
+		"Divergence":  "GET /v1/divergence/stream",
+		"CreateAlert": "POST /v1/alerts",
+		"GetAlert":    "GET /v1/alerts/{id}",
+		"ListAlerts":  "GET /v1/alerts",
+		"UpdateAlert": "PUT /v1/alerts/{id}",
+		"DeleteAlert": "DELETE /v1/alerts/{id}",
+		"WatchAlerts": "GET /v1/alerts/stream",
+	}
+	if methods.Len() != len(want) {
+		t.Errorf("CandleService has %d RPCs, want %d", methods.Len(), len(want))
+	}
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		got, err := httpPattern(md)
+		if err != nil || got != want[string(md.Name())] {
+			t.Errorf("%s: got %q, %v; want %q", md.Name(), got, err, want[string(md.Name())])
+		}
+	}
+
+	getAlert := methods.ByName("GetAlert")
+	for _, rule := range []*annotations.HttpRule{
+		nil,
+		{Pattern: &annotations.HttpRule_Patch{Patch: "/v1/alerts/{id}"}},
+		{Pattern: &annotations.HttpRule_Get{Get: "/v1/alerts/{name}"}},
+		{Pattern: &annotations.HttpRule_Get{Get: "/v1/alerts/{id=*}"}},
+		{Pattern: &annotations.HttpRule_Get{Get: "/v1/alerts:get"}},
+		{Pattern: &annotations.HttpRule_Get{Get: "/v1/alerts/{id}"}, Body: "*"},
+		{Pattern: &annotations.HttpRule_Post{Post: "/v1/alerts"}},
+		{Pattern: &annotations.HttpRule_Get{Get: "/v1/alerts/{id}"}, ResponseBody: "id"},
+		{Pattern: &annotations.HttpRule_Get{Get: "/v1/alerts/{id}"},
+			AdditionalBindings: []*annotations.HttpRule{{Pattern: &annotations.HttpRule_Get{Get: "/v1/alert/{id}"}}}},
+	} {
+		md := withRule(t, getAlert, rule)
+		if got, err := httpPattern(md); err == nil {
+			t.Errorf("rule %v: got %q, want an error", rule, got)
+		}
+	}
+}
+
+// withRule returns a copy of md whose google.api.http option is rule.
+func withRule(t *testing.T, md protoreflect.MethodDescriptor, rule *annotations.HttpRule) protoreflect.MethodDescriptor {
+	t.Helper()
+	fdp := protodesc.ToFileDescriptorProto(md.ParentFile())
+	for _, m := range fdp.Service[0].Method {
+		if m.GetName() == string(md.Name()) {
+			m.Options = &descriptorpb.MethodOptions{}
+			if rule != nil {
+				proto.SetExtension(m.Options, annotations.E_Http, rule)
+			}
+		}
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd.Services().Get(0).Methods().ByName(md.Name())
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/yitech/candles/indicators"
+	"github.com/yitech/candles/model/candle"
+	pb "github.com/yitech/candles/model/protobuf"
+)
+
+const (
+	// maxIndicators caps the indicators one stream may request.
+	maxIndicators = 16
+	// maxIndicatorSeed caps the closed candles read to seed a stream's
+	// indicators, whatever their warm-up.
+	maxIndicatorSeed = 5000
+)
+
+// streamIndicators are the indicators a stream requested, in request order.
+// They are only used from the stream's aggregator callback.
+type streamIndicators []*indicators.Indicator
+
+func newStreamIndicators(specs []string) (streamIndicators, error) {
+	if len(specs) > maxIndicators {
+		return nil, fmt.Errorf("at most %d indicators per stream", maxIndicators)
+	}
+	si := make(streamIndicators, len(specs))
+	for i, s := range specs {
+		spec, err := indicators.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		si[i] = indicators.New(spec)
+	}
+	return si, nil
+}
+
+// seedLen is how many closed candles of interval the indicators need to be
+// warmed up.
+func (si streamIndicators) seedLen(interval string) int {
+	dur, _ := candle.IntervalDuration(interval)
+	n := 0
+	for _, ind := range si {
+		n = max(n, ind.Spec().Warmup(dur))
+	}
+	return min(n, maxIndicatorSeed)
+}
+
+// prime advances the indicators over cs, closed candles in order.
+func (si streamIndicators) prime(cs []candle.Candle) {
+	for i := range cs {
+		for _, ind := range si {
+			ind.Update(&cs[i])
+		}
+	}
+}
+
+// attach folds c into the indicators and sets their values on pc.
+func (si streamIndicators) attach(c *candle.Candle, pc *pb.Candle) {
+	if len(si) == 0 {
+		return
+	}
+	pc.Indicators = make([]*pb.IndicatorValue, len(si))
+	for i, ind := range si {
+		v := ind.Update(c)
+		pc.Indicators[i] = &pb.IndicatorValue{Name: ind.Spec().String(), Values: append([]float64(nil), v...)}
+	}
+}
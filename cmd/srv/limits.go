@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/yitech/candles/aggregator"
+	"github.com/yitech/candles/auth"
+	"github.com/yitech/candles/metrics"
+)
+
+// Every market the aggregator runs holds a socket to each exchange for the
+// life of the server, so clients are limited in what they may open:
+//
+//   - streams_per_client: concurrent streams (Subscribe, Divergence,
+//     WatchAlerts; each WebSocket subscription counts as one) and enabled
+//     alert rules per client,
+//   - markets_per_client: distinct markets among a client's open streams
+//     and enabled alert rules,
+//   - max_markets: markets running on the server, not counting the base
+//     markets resampled ones are computed from unless requested themselves,
+//   - new_markets_per_minute: how fast markets are started, server-wide,
+//     with bursts of new_markets_burst.
+//
+// A client is the authenticated principal or, without one, the peer's IP
+// address.  Requests over a limit fail with RESOURCE_EXHAUSTED.
+
+// limiter enforces the LimitsConfig.  A zero limit is not enforced.
+type limiter struct {
+	agg *aggregator.Aggregator
+	cfg LimitsConfig
+	now func() time.Time
+
+	mu      sync.Mutex
+	clients map[string]*clientUsage
+	opening map[string]int // markets being started, by key
+
+	// Token bucket of market starts.
+	tokens float64
+	filled time.Time
+}
+
+// clientUsage is what one client has open.
+type clientUsage struct {
+	streams int
+	markets map[string]int // open streams by market
+}
+
+func newLimiter(agg *aggregator.Aggregator, cfg LimitsConfig) *limiter {
+	return &limiter{
+		agg:     agg,
+		cfg:     cfg,
+		now:     time.Now,
+		clients: make(map[string]*clientUsage),
+		opening: make(map[string]int),
+		tokens:  float64(cfg.NewMarketsBurst),
+		filled:  time.Now(),
+	}
+}
+
+// clientID identifies the caller in ctx: its principal's name, or the IP
+// address it connects from.
+func clientID(ctx context.Context) string {
+	if p, ok := auth.FromContext(ctx); ok && p.Name != "" {
+		return p.Name
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return host
+		}
+		return p.Addr.String()
+	}
+	return "unknown"
+}
+
+// acquire counts a stream of the caller in ctx on market ("" for streams
+// of no market) against its limits.  release must be called when the
+// stream ends.
+func (l *limiter) acquire(ctx context.Context, market string) (release func(), err error) {
+	return l.count(clientID(ctx), market, true)
+}
+
+// count counts a stream of client id on market, like acquire.  Unless
+// enforce is set it never fails, so that what a client held before a
+// restart is counted even when over a lowered limit.
+func (l *limiter) count(id, market string, enforce bool) (release func(), err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c := l.clients[id]
+	if c == nil {
+		c = &clientUsage{markets: make(map[string]int)}
+	}
+	if max := l.cfg.StreamsPerClient; enforce && max > 0 && c.streams >= max {
+		return nil, exhausted("streams_per_client", id,
+			fmt.Sprintf("too many streams: %d open, alert rules included; the limit is %d per client", c.streams, max), 0)
+	}
+	if max := l.cfg.MarketsPerClient; enforce && max > 0 && market != "" && c.markets[market] == 0 && len(c.markets) >= max {
+		return nil, exhausted("markets_per_client", id,
+			fmt.Sprintf("too many markets: %d in use, the limit is %d per client", len(c.markets), max), 0)
+	}
+	l.clients[id] = c
+	c.streams++
+	if market != "" {
+		c.markets[market]++
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			c.streams--
+			if market != "" {
+				if c.markets[market]--; c.markets[market] == 0 {
+					delete(c.markets, market)
+				}
+			}
+			if c.streams == 0 {
+				delete(l.clients, id)
+			}
+		})
+	}, nil
+}
+
+// open admits a start of the market symbol/interval, unless it is running
+// already, against max_markets and the start rate.  done must be called
+// once the aggregator has been asked to start it, whether or not it did.
+func (l *limiter) open(symbol, interval string) (done func(), err error) {
+	key := symbol + ":" + interval
+	if l.agg.Running(symbol, interval) {
+		return func() {}, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.opening[key] == 0 {
+		running := l.agg.RunningMarkets() + len(l.opening)
+		if max := l.cfg.MaxMarkets; max > 0 && running >= max {
+			return nil, exhausted("max_markets", "server",
+				fmt.Sprintf("too many markets: the server runs its limit of %d; subscribe to a running one", max), 0)
+		}
+		if wait := l.take(); wait > 0 {
+			return nil, exhausted("new_markets_per_minute", "server",
+				fmt.Sprintf("markets are started at most %d per minute; retry in %v", l.cfg.NewMarketsPerMinute, max(wait.Round(time.Second), time.Second)), wait)
+		}
+	}
+	l.opening[key]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			if l.opening[key]--; l.opening[key] == 0 {
+				delete(l.opening, key)
+			}
+			l.mu.Unlock()
+			l.updateGauge()
+		})
+	}, nil
+}
+
+// updateGauge sets the running markets gauge.
+func (l *limiter) updateGauge() {
+	metrics.RunningMarkets.Set(float64(l.agg.RunningMarkets()))
+}
+
+// take spends a token of the start rate, or returns how long until one is
+// available (called under lock).
+func (l *limiter) take() time.Duration {
+	perMinute := l.cfg.NewMarketsPerMinute
+	if perMinute <= 0 {
+		return 0
+	}
+	now := l.now()
+	rate := float64(perMinute) / time.Minute.Seconds()
+	l.tokens = math.Min(float64(l.cfg.NewMarketsBurst), l.tokens+now.Sub(l.filled).Seconds()*rate)
+	l.filled = now
+	if l.tokens < 1 {
+		return time.Duration((1 - l.tokens) / rate * float64(time.Second))
+	}
+	l.tokens--
+	return 0
+}
+
+// exhausted counts a rejection by limit and returns its RESOURCE_EXHAUSTED
+// status, with a QuotaFailure naming subject and, if retry is positive, a
+// RetryInfo.
+func exhausted(limit, subject, msg string, retry time.Duration) error {
+	metrics.LimitRejections.WithLabelValues(limit).Inc()
+	st := status.New(codes.ResourceExhausted, msg)
+	details := []protoadapt.MessageV1{&errdetails.QuotaFailure{Violations: []*errdetails.QuotaFailure_Violation{
+		{Subject: subject, Description: "limits." + limit},
+	}}}
+	if retry > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(retry)})
+	}
+	if d, err := st.WithDetails(details...); err == nil {
+		st = d
+	}
+	return st.Err()
+}
@@ -1,59 +1,212 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"math"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
 
-	"github.com/yitech/candles/adapter/binance"
-	"github.com/yitech/candles/adapter/bybit"
-	"github.com/yitech/candles/adapter/okx"
 	"github.com/yitech/candles/aggregator"
+	"github.com/yitech/candles/metrics"
 	"github.com/yitech/candles/model/candle"
 	pb "github.com/yitech/candles/model/protobuf"
+	"github.com/yitech/candles/store"
+	"github.com/yitech/candles/wal"
 )
 
-const streamBuf = 64 // per-stream channel buffer
-
 type server struct {
 	pb.UnimplementedCandleServiceServer
-	agg *aggregator.Aggregator
+	agg       *aggregator.Aggregator
+	streamBuf int                   // per-stream candle buffer
+	policy    pb.BackpressurePolicy // used when a request leaves it unset
+	alerts    *alertEngine
+	limits    *limiter
+
+	// drain is closed when shutdown starts: new streams are refused.
+	// flushed is closed once the aggregator has published the periods
+	// that are over: open streams send what they have buffered, then end
+	// with a reconnect hint.
+	drain      chan struct{}
+	flushed    chan struct{}
+	retryDelay time.Duration
+
+	startedAt time.Time
+
+	gateway    *http.Server // nil unless the HTTP/JSON gateway is enabled
+	websockets wsConns      // connections to the gateway's WebSocket endpoint
 }
 
 // Subscribe fans out to all exchanges via the aggregator and streams merged
-// candles to the gRPC client. A buffered channel decouples the aggregator's
-// push goroutine from the gRPC send loop.
+// candles to the gRPC client. A non-zero resume_token first replays the
+// closed candles published after it; otherwise history asks for the most
+// recent closed candles. Requested indicators are seeded from the history
+// before it and attached to every candle. A bounded streamQueue decouples the
+// aggregator's push goroutine from the gRPC send loop; when the client falls
+// behind, the requested BackpressurePolicy decides what is dropped and the
+// drop count is reported on the next candle sent.
 func (s *server) Subscribe(req *pb.SubscribeRequest, stream pb.CandleService_SubscribeServer) error {
-	log.Printf("subscribe: symbol=%s interval=%s", req.Symbol, req.Interval)
+	policy := req.Backpressure
+	if policy == pb.BackpressurePolicy_BACKPRESSURE_POLICY_UNSPECIFIED {
+		policy = s.policy
+	}
+	if _, ok := pb.BackpressurePolicy_name[int32(policy)]; !ok {
+		return status.Errorf(codes.InvalidArgument, "unknown backpressure policy %d", policy)
+	}
+	inds, err := newStreamIndicators(req.Indicators)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	if err := authorize(stream.Context(), req.Symbol, req.Interval); err != nil {
+		return err
+	}
+	select {
+	case <-s.drain:
+		return s.shutdownStatus()
+	default:
+	}
+	market := req.Symbol + ":" + req.Interval
+	release, err := s.limits.acquire(stream.Context(), market)
+	if err != nil {
+		return err
+	}
+	defer release()
+	opened, err := s.limits.open(req.Symbol, req.Interval)
+	if err != nil {
+		return err
+	}
+	log.Printf("subscribe: symbol=%s interval=%s backpressure=%s resume=%d",
+		req.Symbol, req.Interval, policy, req.ResumeToken)
 
-	ch := make(chan *candle.Candle, streamBuf)
+	q := newStreamQueue(s.streamBuf, policy, metrics.StreamDrops.WithLabelValues(market, policy.String()))
 
-	tok, err := s.agg.Subscribe(req.Symbol, req.Interval, func(c *candle.Candle) {
-		select {
-		case ch <- c:
-		default:
-			log.Printf("warn: slow consumer [%s:%s], candle dropped", req.Symbol, req.Interval)
+	opts := aggregator.SubscribeOptions{
+		ResumeAfter: req.ResumeToken,
+		History:     int(req.History),
+		Seed:        inds.seedLen(req.Interval),
+		OnSeed:      inds.prime,
+	}
+	tok, err := s.agg.SubscribeWith(req.Symbol, req.Interval, opts, func(c *candle.Candle, dropped int) {
+		// Indicators are computed here, ahead of the queue, so candles
+		// it drops still advance them.  Candles the aggregator dropped
+		// are reported along with the queue's own drops.
+		pc := toProto(c)
+		inds.attach(c, pc)
+		q.lost(dropped)
+		if q.push(pc) {
+			log.Printf("warn: slow consumer [%s:%s], dropping candles (%s)", req.Symbol, req.Interval, policy)
 		}
 	})
-	if err != nil {
+	opened()
+	switch {
+	case errors.Is(err, aggregator.ErrResumeGap):
+		return status.Errorf(codes.OutOfRange,
+			"resume token %d is older than the server history; resubscribe without a token", req.ResumeToken)
+	case errors.Is(err, aggregator.ErrResumeAhead):
+		return status.Errorf(codes.OutOfRange,
+			"resume token %d is ahead of the stream; resubscribe without a token", req.ResumeToken)
+	case err != nil:
 		return status.Errorf(codes.Internal, "aggregator subscribe: %v", err)
 	}
 	defer tok.Unsubscribe()
+	// The header tells the client the subscription is in place.
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	active := metrics.ActiveStreams.WithLabelValues(market)
+	active.Inc()
+	defer active.Dec()
 
 	for {
 		select {
 		case <-stream.Context().Done():
 			log.Printf("disconnect: symbol=%s interval=%s", req.Symbol, req.Interval)
 			return stream.Context().Err()
-		case c := <-ch:
-			if err := stream.Send(toProto(c)); err != nil {
+		case <-s.flushed:
+			if err := sendBuffered(stream, q); err != nil {
 				return err
 			}
+			return s.shutdownStatus()
+		case <-q.ready:
 		}
+
+		for {
+			if q.overflowed() {
+				log.Printf("disconnect: symbol=%s interval=%s: slow consumer", req.Symbol, req.Interval)
+				return status.Errorf(codes.ResourceExhausted,
+					"slow consumer: stream buffer of %d candles overflowed", s.streamBuf)
+			}
+			c, dropped, ok := q.pop()
+			if !ok {
+				break
+			}
+			if err := send(stream, c, dropped); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Limits of GetCandles.
+const (
+	defaultCandlesLimit = 100
+	maxCandlesLimit     = 1000
+)
+
+// GetCandles returns the closed candles of a market before end_time, newest
+// last, from the aggregator's history and the candle store.
+func (s *server) GetCandles(ctx context.Context, req *pb.CandlesRequest) (*pb.CandlesResponse, error) {
+	if req.Symbol == "" {
+		return nil, status.Error(codes.InvalidArgument, "symbol is required")
+	}
+	if _, err := candle.IntervalDuration(req.Interval); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
+	limit := int(req.Limit)
+	switch {
+	case limit == 0:
+		limit = defaultCandlesLimit
+	case limit > maxCandlesLimit:
+		return nil, status.Errorf(codes.InvalidArgument, "limit %d is above %d", limit, maxCandlesLimit)
+	}
+	if err := authorize(ctx, req.Symbol, req.Interval); err != nil {
+		return nil, err
+	}
+	before := req.EndTime
+	if before <= 0 {
+		before = math.MaxInt64
+	}
+	cs, err := s.agg.History(req.Symbol, req.Interval, before, limit)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "history: %v", err)
+	}
+	resp := &pb.CandlesResponse{Candles: make([]*pb.Candle, len(cs))}
+	for i := range cs {
+		resp.Candles[i] = toProto(&cs[i])
+	}
+	return resp, nil
+}
+
+func send(stream pb.CandleService_SubscribeServer, pc *pb.Candle, dropped uint64) error {
+	pc.Dropped = dropped
+	return stream.Send(pc)
 }
 
 func toProto(c *candle.Candle) *pb.Candle {
@@ -69,27 +222,166 @@ func toProto(c *candle.Candle) *pb.Candle {
 		Volume:    c.Volume,
 		CloseTime: c.CloseTime,
 		IsClosed:  c.IsClosed,
+		Seq:       c.Seq,
+		Synthetic: c.Synthetic,
+		Revision:  c.Revision,
 	}
 }
 
 func main() {
-	agg := aggregator.New(
-		binance.New(),
-		bybit.New(),
-		okx.New(),
-	)
-	defer agg.Close()
-
-	lis, err := net.Listen("tcp", ":50051")
+	args := os.Args[1:]
+	dumpConfig := len(args) > 0 && args[0] == "dump-config"
+	if dumpConfig {
+		args = args[1:]
+	}
+
+	cfg, err := loadConfig(args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if dumpConfig {
+		if err := cfg.dump(os.Stdout); err != nil {
+			log.Fatalf("dump-config: %v", err)
+		}
+		return
+	}
+	if err := cfg.Log.setupLogging(); err != nil {
+		log.Fatal(err)
+	}
+
+	var st store.Store
+	if cfg.Store.Path != "" {
+		b, err := store.OpenBolt(cfg.Store.Path, store.BoltOptions{Retention: cfg.Store.Retention})
+		if err != nil {
+			log.Fatal(err)
+		}
+		st = b
+		log.Printf("candle store: %s", cfg.Store.Path)
+	}
+
+	var wl *wal.Log
+	if cfg.WAL.Dir != "" {
+		wl, err = wal.Open(cfg.WAL.Dir, wal.Options{SyncInterval: cfg.WAL.SyncInterval})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	adapters := cfg.adapters()
+	agg := aggregator.NewWithConfig(aggregator.Config{
+		HistoryDepth:       cfg.History.Depth,
+		Store:              st,
+		WAL:                wl,
+		CheckpointInterval: cfg.WAL.CheckpointInterval,
+		BaseInterval:       cfg.Markets.BaseInterval,
+		GapFill:            cfg.Markets.GapFill,
+		LateWindow:         cfg.Markets.LateWindow,
+		Markets:            cfg.Markets.aggregatorMarkets(),
+		Divergence:         cfg.Markets.Divergence.thresholds(),
+	}, adapters...)
+	if wl != nil {
+		n, err := agg.Recover()
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("wal: replayed %d records from %s", n, cfg.WAL.Dir)
+	}
+
+	policy, _ := parsePolicy(cfg.Markets.Defaults.Backpressure) // validated
+
+	for _, m := range cfg.Markets.Warm {
+		symbol, interval, _ := strings.Cut(m, ":") // validated
+		if err := agg.Warm(symbol, interval, cfg.History.Depth); err != nil {
+			log.Printf("warn: warm %s: %v (will start on first subscribe)", m, err)
+			continue
+		}
+		log.Printf("warmed %s", m)
+	}
+
+	limits := newLimiter(agg, cfg.Limits)
+	alerts := newAlertEngine(agg, limits, cfg.Alerts, cfg.Buffers.Stream)
+	if err := alerts.load(); err != nil {
+		log.Fatal(err)
+	}
+	limits.updateGauge()
+	if cfg.Alerts.Path != "" {
+		log.Printf("alert rules: %s", cfg.Alerts.Path)
+	}
+
+	tc, err := cfg.TLS.serverTLS()
+	if err != nil {
+		log.Fatal(err)
+	}
+	authn, err := newAuthenticator(cfg.Auth)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var opts []grpc.ServerOption
+	if tc != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tc)))
+		if tc.ClientCAs != nil {
+			log.Printf("tls: on, client certificates required")
+		} else {
+			log.Printf("tls: on")
+		}
+	}
+	if authn != nil {
+		opts = append(opts, grpc.ChainUnaryInterceptor(authUnary(authn)), grpc.ChainStreamInterceptor(authStream(authn)))
+		log.Printf("auth: %d API keys, JWT %s", len(cfg.Auth.APIKeys), cmp.Or(cfg.Auth.JWT.JWKSFile, "off"))
+	}
+
+	lis, err := net.Listen("tcp", cfg.Listen)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
-	s := grpc.NewServer()
-	pb.RegisterCandleServiceServer(s, &server{agg: agg})
+	srv := &server{
+		agg:        agg,
+		streamBuf:  cfg.Buffers.Stream,
+		policy:     policy,
+		alerts:     alerts,
+		limits:     limits,
+		drain:      make(chan struct{}),
+		flushed:    make(chan struct{}),
+		retryDelay: cfg.Shutdown.RetryDelay,
+		startedAt:  time.Now(),
+	}
+	s := grpc.NewServer(opts...)
+	pb.RegisterCandleServiceServer(s, srv)
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(s, hs)
+	reflection.Register(s)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go watchHealth(ctx, hs, agg)
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- s.Serve(lis) }()
+	log.Printf("gRPC server listening on %s", cfg.Listen)
+
+	if cfg.HTTP.Listen != "" {
+		srv.gateway = serveGateway(cfg.HTTP.Listen, newGateway(srv, cfg.HTTP.CORSOrigins, authn), tc)
+	}
+
+	var metricsSrv *http.Server
+	if cfg.Metrics.Listen != "" {
+		metricsSrv = serveMetrics(cfg.Metrics.Listen, cfg.Metrics.Path)
+	}
 
-	log.Printf("gRPC server listening on :50051")
-	if err := s.Serve(lis); err != nil {
+	select {
+	case err := <-serveErr:
 		log.Fatalf("failed to serve: %v", err)
+	case <-ctx.Done():
+	}
+	stop() // a second signal kills the process
+
+	hs.Shutdown() // report NOT_SERVING while draining
+	shutdown(s, srv, agg, adapters, st, wl, cfg.Shutdown.Timeout)
+	if metricsSrv != nil {
+		metricsSrv.Close()
 	}
 }
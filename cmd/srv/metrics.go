@@ -0,0 +1,25 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// serveMetrics exposes the default Prometheus registry on addr/path.
+// Listen errors are logged rather than fatal: metrics are not worth taking
+// the candle stream down for.
+func serveMetrics(addr, path string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle(path, promhttp.Handler())
+	srv := &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		log.Printf("metrics listening on %s%s", addr, path)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("warn: metrics server: %v", err)
+		}
+	}()
+	return srv
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	pb "github.com/yitech/candles/model/protobuf"
+)
+
+// streamQueue is a bounded per-stream candle buffer sitting between the
+// aggregator callback (producer) and a stream's send loop (consumer).
+// push never blocks; when the buffer is full the configured
+// BackpressurePolicy decides what is dropped.
+type streamQueue struct {
+	mu       sync.Mutex
+	policy   pb.BackpressurePolicy
+	size     int
+	buf      []*pb.Candle
+	dropped  uint64 // drops since the last pop
+	overflow bool   // set once under DISCONNECT, or past hardCap
+	drops    prometheus.Counter
+
+	// ready is signalled (without blocking) after every push.
+	ready chan struct{}
+}
+
+// newStreamQueue creates a queue holding up to size candles. Every dropped
+// candle is also added to drops.
+func newStreamQueue(size int, policy pb.BackpressurePolicy, drops prometheus.Counter) *streamQueue {
+	return &streamQueue{
+		drops:  drops,
+		policy: policy,
+		size:   size,
+		buf:    make([]*pb.Candle, 0, size),
+		ready:  make(chan struct{}, 1),
+	}
+}
+
+// push enqueues c according to the queue's policy. It reports whether this
+// push started a new run of drops, so callers can log once per burst.
+func (q *streamQueue) push(c *pb.Candle) (firstDrop bool) {
+	q.mu.Lock()
+	before := q.dropped
+
+	switch {
+	case q.overflow:
+		// Stream is being torn down; nothing more is delivered.
+	case q.policy == pb.BackpressurePolicy_BACKPRESSURE_POLICY_CONFLATE && q.conflate(c):
+	case len(q.buf) < q.size:
+		q.buf = append(q.buf, c)
+	default:
+		q.pushFull(c)
+	}
+
+	firstDrop = before == 0 && q.dropped > 0
+	if n := q.dropped - before; n > 0 {
+		q.drops.Add(float64(n))
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return firstDrop
+}
+
+// lost adds n candles dropped ahead of the queue to the count reported on
+// the next pop.
+func (q *streamQueue) lost(n int) {
+	if n <= 0 {
+		return
+	}
+	q.mu.Lock()
+	q.dropped += uint64(n)
+	q.mu.Unlock()
+}
+
+// pushFull applies the policy to c when the buffer is at capacity
+// (called under lock).
+func (q *streamQueue) pushFull(c *pb.Candle) {
+	switch q.policy {
+	case pb.BackpressurePolicy_BACKPRESSURE_POLICY_DROP_OLDEST:
+		q.evict(0)
+		q.buf = append(q.buf, c)
+
+	case pb.BackpressurePolicy_BACKPRESSURE_POLICY_DROP_UPDATES,
+		pb.BackpressurePolicy_BACKPRESSURE_POLICY_CONFLATE:
+		// Make room by discarding the oldest in-progress update. If every
+		// buffered candle is closed, a closed candle is still admitted
+		// (the buffer grows by at most one per period) while an
+		// in-progress one is dropped.  A client that falls hardCap
+		// candles behind is disconnected as under DISCONNECT.
+		switch i := q.oldestOpen(); {
+		case i >= 0:
+			q.evict(i)
+			q.buf = append(q.buf, c)
+		case !c.IsClosed:
+			q.dropped++
+		case len(q.buf) >= q.hardCap():
+			q.disconnect()
+		default:
+			q.buf = append(q.buf, c)
+		}
+
+	case pb.BackpressurePolicy_BACKPRESSURE_POLICY_DISCONNECT:
+		q.disconnect()
+
+	default: // DROP_NEWEST
+		q.dropped++
+	}
+}
+
+// hardCap bounds the closed candles DROP_UPDATES and CONFLATE keep past
+// the queue's size.
+func (q *streamQueue) hardCap() int { return 2 * q.size }
+
+// disconnect drops everything buffered and marks the stream for teardown
+// (called under lock).
+func (q *streamQueue) disconnect() {
+	q.overflow = true
+	q.dropped += uint64(len(q.buf)) + 1
+	q.buf = q.buf[:0]
+}
+
+// conflate replaces a buffered in-progress candle with the same OpenTime as
+// c. Reports whether c was absorbed (called under lock).
+func (q *streamQueue) conflate(c *pb.Candle) bool {
+	for i := len(q.buf) - 1; i >= 0; i-- {
+		b := q.buf[i]
+		if b.OpenTime != c.OpenTime {
+			continue
+		}
+		if b.IsClosed {
+			return false
+		}
+		q.buf[i] = c
+		q.dropped++
+		return true
+	}
+	return false
+}
+
+// oldestOpen returns the index of the oldest buffered candle that is not
+// closed, or -1 (called under lock).
+func (q *streamQueue) oldestOpen() int {
+	for i, b := range q.buf {
+		if !b.IsClosed {
+			return i
+		}
+	}
+	return -1
+}
+
+// evict removes buf[i] and counts it as dropped (called under lock).
+func (q *streamQueue) evict(i int) {
+	copy(q.buf[i:], q.buf[i+1:])
+	q.buf[len(q.buf)-1] = nil
+	q.buf = q.buf[:len(q.buf)-1]
+	q.dropped++
+}
+
+// pop removes the oldest buffered candle. dropped is the number of candles
+// discarded since the previous pop, to be reported in-band to the client.
+func (q *streamQueue) pop() (c *pb.Candle, dropped uint64, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.buf) == 0 {
+		return nil, 0, false
+	}
+	c = q.buf[0]
+	q.buf[0] = nil
+	q.buf = q.buf[1:]
+	dropped = q.dropped
+	q.dropped = 0
+	return c, dropped, true
+}
+
+// overflowed reports whether the DISCONNECT policy, or the hard cap of
+// DROP_UPDATES and CONFLATE, has tripped.
+func (q *streamQueue) overflowed() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.overflow
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	pb "github.com/yitech/candles/model/protobuf"
+)
+
+// TestQueueHardCap checks that DROP_UPDATES and CONFLATE keep closed
+// candles past the queue's size, up to its hard cap, then disconnect.
+func TestQueueHardCap(t *testing.T) {
+	for _, policy := range []pb.BackpressurePolicy{
+		pb.BackpressurePolicy_BACKPRESSURE_POLICY_DROP_UPDATES,
+		pb.BackpressurePolicy_BACKPRESSURE_POLICY_CONFLATE,
+	} {
+		t.Run(policy.String(), func(t *testing.T) {
+			const size = 4
+			q := newStreamQueue(size, policy, prometheus.NewCounter(prometheus.CounterOpts{Name: "drops"}))
+			for i := range 2 * size {
+				q.push(&pb.Candle{OpenTime: int64(i), IsClosed: true})
+				q.push(&pb.Candle{OpenTime: int64(i + 1)}) // dropped once full
+			}
+			if q.overflowed() {
+				t.Fatalf("overflowed within %d closed candles", 2*size)
+			}
+			if len(q.buf) != 2*size {
+				t.Fatalf("%d candles buffered, want %d", len(q.buf), 2*size)
+			}
+			q.push(&pb.Candle{OpenTime: 2 * size, IsClosed: true})
+			if !q.overflowed() {
+				t.Fatalf("not overflowed with %d closed candles buffered", len(q.buf))
+			}
+			if _, _, ok := q.pop(); ok {
+				t.Error("an overflowed queue still delivers")
+			}
+		})
+	}
+}
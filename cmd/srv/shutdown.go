@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/yitech/candles/adapter"
+	"github.com/yitech/candles/aggregator"
+	pb "github.com/yitech/candles/model/protobuf"
+	"github.com/yitech/candles/store"
+	"github.com/yitech/candles/wal"
+)
+
+// shutdown drains the server within timeout:
+//
+//  1. refuse new streams,
+//  2. finalize and publish pending periods that are already over,
+//  3. let every stream, gRPC, HTTP or WebSocket, send what it has
+//     buffered and end it with UNAVAILABLE plus a retry hint,
+//  4. stop evaluating alert rules and deliver the queued webhooks,
+//  5. checkpoint the write-ahead log and write the finalized periods to the
+//     store, then close both,
+//  6. close exchange sockets.
+//
+// Whatever is still running when the deadline expires is stopped forcibly.
+func shutdown(s *grpc.Server, srv *server, agg *aggregator.Aggregator, adapters []adapter.Adapter, st store.Store, wl *wal.Log, timeout time.Duration) {
+	log.Printf("shutting down (deadline %v)", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	close(srv.drain)
+	if !within(ctx, agg.Flush) {
+		log.Printf("warn: pending periods not flushed at deadline")
+	}
+	close(srv.flushed)
+
+	if !within(ctx, s.GracefulStop) {
+		log.Printf("warn: streams still open at deadline, closing them")
+		s.Stop()
+	}
+	if srv.gateway != nil && !within(ctx, func() { srv.gateway.Shutdown(ctx) }) {
+		log.Printf("warn: HTTP streams still open at deadline, closing them")
+		srv.gateway.Close()
+	}
+	if !within(ctx, srv.websockets.wait) {
+		log.Printf("warn: WebSocket connections still open at deadline, closing them")
+		srv.websockets.closeAll()
+	}
+
+	if !within(ctx, srv.alerts.close) {
+		log.Printf("warn: alert webhooks still pending at deadline")
+	}
+	agg.Close()
+	if wl != nil {
+		if err := wl.Close(); err != nil {
+			log.Printf("warn: close wal: %v", err)
+		}
+	}
+	if st != nil {
+		if err := st.Close(); err != nil {
+			log.Printf("warn: close store: %v", err)
+		}
+	}
+	closed := within(ctx, func() {
+		var wg sync.WaitGroup
+		for _, ad := range adapters {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ad.Close()
+			}()
+		}
+		wg.Wait()
+	})
+	if !closed {
+		log.Printf("warn: exchange sockets still open at deadline")
+		return
+	}
+	log.Printf("shutdown complete")
+}
+
+// within runs fn and reports whether it finished before ctx expired.
+func within(ctx context.Context, fn func()) bool {
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// sendBuffered flushes everything left in q to the client.
+func sendBuffered(stream pb.CandleService_SubscribeServer, q *streamQueue) error {
+	for {
+		c, dropped, ok := q.pop()
+		if !ok {
+			return nil
+		}
+		if err := send(stream, c, dropped); err != nil {
+			return err
+		}
+	}
+}
+
+// shutdownStatus is the final status of every stream during a drain. It
+// carries a RetryInfo so clients know when to reconnect (with their
+// resume_token) to this or another instance.
+func (s *server) shutdownStatus() error {
+	st := status.New(codes.Unavailable, "server shutting down; reconnect with resume_token")
+	if d, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(s.retryDelay)}); err == nil {
+		st = d
+	}
+	return st.Err()
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/yitech/candles/aggregator"
+	pb "github.com/yitech/candles/model/protobuf"
+)
+
+// healthInterval is how often the health status is recomputed.
+const healthInterval = 5 * time.Second
+
+// GetStatus reports every market the aggregator knows about that the
+// caller may access, with its per-exchange connection state, plus a
+// per-exchange summary of those markets.
+func (s *server) GetStatus(ctx context.Context, _ *pb.StatusRequest) (*pb.StatusResponse, error) {
+	resp := &pb.StatusResponse{StartedAt: s.startedAt.UnixMilli()}
+	byEx := make(map[string]*pb.ExchangeStatus)
+
+	for _, m := range s.agg.Status() {
+		if !allowed(ctx, m.Symbol, m.Interval) {
+			continue
+		}
+		pm := &pb.MarketStatus{
+			Symbol:         m.Symbol,
+			Interval:       m.Interval,
+			Subscribers:    uint32(m.Subscribers),
+			HistoryDepth:   uint32(m.HistoryDepth),
+			PendingPeriods: uint32(m.Pending),
+			Seq:            m.Seq,
+			LastUpdate:     unixMilli(m.LastUpdate),
+			ResampledFrom:  m.ResampledFrom,
+		}
+		for _, e := range m.Exchanges {
+			pm.Exchanges = append(pm.Exchanges, &pb.ExchangeConnection{
+				Exchange:   e.Exchange,
+				Connected:  e.Connected,
+				LastUpdate: unixMilli(e.LastUpdate),
+				Reconnects: e.Reconnects,
+			})
+
+			es, ok := byEx[e.Exchange]
+			if !ok {
+				es = &pb.ExchangeStatus{Exchange: e.Exchange}
+				byEx[e.Exchange] = es
+				resp.Exchanges = append(resp.Exchanges, es)
+			}
+			if m.ResampledFrom != "" {
+				continue // counted under the base market
+			}
+			es.Subscriptions++
+			if e.Connected {
+				es.Connected++
+			}
+			es.LastUpdate = max(es.LastUpdate, unixMilli(e.LastUpdate))
+		}
+		resp.Markets = append(resp.Markets, pm)
+	}
+	return resp, nil
+}
+
+// ListMarkets lists the markets of GetStatus.
+func (s *server) ListMarkets(ctx context.Context, _ *pb.ListMarketsRequest) (*pb.ListMarketsResponse, error) {
+	st, err := s.GetStatus(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ListMarketsResponse{Markets: st.Markets}, nil
+}
+
+// unixMilli is t in Unix ms, or 0 for the zero time.
+func unixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+// watchHealth keeps hs in step with exchange connectivity until ctx ends.
+// An exchange is up while any of its subscriptions for running markets is
+// connected, so a single market's socket dropping does not fail the
+// server; the server is SERVING while any exchange is up, or no market
+// runs, and the latest WAL append succeeded.
+func watchHealth(ctx context.Context, hs *health.Server, agg *aggregator.Aggregator) {
+	t := time.NewTicker(healthInterval)
+	defer t.Stop()
+
+	last := healthpb.HealthCheckResponse_SERVING
+	wasUp := make(map[string]bool)
+	var walErr error
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		up := exchangesUp(agg.Status())
+		anyUp := len(up) == 0
+		for ex, ok := range up {
+			if was, seen := wasUp[ex]; seen && was != ok {
+				log.Printf("health: exchange %s up=%t", ex, ok)
+			}
+			wasUp[ex] = ok
+			anyUp = anyUp || ok
+		}
+		if err := agg.WALErr(); (err == nil) != (walErr == nil) {
+			if err != nil {
+				log.Printf("health: wal append failing: %v", err)
+			} else {
+				log.Printf("health: wal append recovered")
+			}
+			walErr = err
+		}
+		st := healthpb.HealthCheckResponse_SERVING
+		if !anyUp || walErr != nil {
+			st = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		if st != last {
+			log.Printf("health: %s", st)
+			last = st
+		}
+		hs.SetServingStatus("", st)
+		hs.SetServingStatus(pb.CandleService_ServiceDesc.ServiceName, st)
+	}
+}
+
+// exchangesUp reports, for every exchange subscribed for a running market,
+// whether any of those subscriptions is connected.  Resampled markets share
+// their base market's subscriptions and are skipped.
+func exchangesUp(ms []aggregator.MarketStatus) map[string]bool {
+	up := make(map[string]bool)
+	for _, m := range ms {
+		if !m.Running || m.ResampledFrom != "" {
+			continue
+		}
+		for _, e := range m.Exchanges {
+			up[e.Exchange] = up[e.Exchange] || e.Connected
+		}
+	}
+	return up
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"github.com/yitech/candles/adapter"
+	"github.com/yitech/candles/aggregator"
+	"github.com/yitech/candles/auth"
+	pb "github.com/yitech/candles/model/protobuf"
+)
+
+func TestExchangesUp(t *testing.T) {
+	ex := func(name string, connected bool) aggregator.ExchangeStatus {
+		return aggregator.ExchangeStatus{Exchange: name, SubscriptionStatus: adapter.SubscriptionStatus{Connected: connected}}
+	}
+	ms := []aggregator.MarketStatus{
+		// binance has one market down, okx all: binance is still up.
+		{Symbol: "BTCUSDT", Interval: "1m", Running: true, Exchanges: []aggregator.ExchangeStatus{ex("binance", true), ex("okx", false)}},
+		{Symbol: "ETHUSDT", Interval: "1m", Running: true, Exchanges: []aggregator.ExchangeStatus{ex("binance", false), ex("okx", false)}},
+		// Not running: a failed start, ignored.
+		{Symbol: "SOLUSDT", Interval: "1m", Exchanges: []aggregator.ExchangeStatus{ex("bybit", false)}},
+		// Resampled: the base market's subscriptions, ignored.
+		{Symbol: "BTCUSDT", Interval: "5m", Running: true, ResampledFrom: "1m", Exchanges: []aggregator.ExchangeStatus{ex("binance", true), ex("okx", true)}},
+	}
+	want := map[string]bool{"binance": true, "okx": false}
+	if got := exchangesUp(ms); !maps.Equal(got, want) {
+		t.Errorf("exchangesUp = %v, want %v", got, want)
+	}
+}
+
+// TestGetStatusAllowed checks that GetStatus and ListMarkets leave out the
+// markets the caller may not access.
+func TestGetStatusAllowed(t *testing.T) {
+	agg := aggregator.NewWithConfig(aggregator.Config{})
+	defer agg.Close()
+	for _, symbol := range []string{"BTCUSDT", "ETHUSDT"} {
+		if err := agg.Warm(symbol, "1m", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := &server{agg: agg}
+
+	for _, tc := range []struct {
+		name    string
+		ctx     context.Context
+		markets int
+	}{
+		{"no authentication", context.Background(), 2},
+		{"unrestricted", auth.NewContext(context.Background(), &auth.Principal{Name: "a"}), 2},
+		{"restricted", auth.NewContext(context.Background(), &auth.Principal{Name: "b", Markets: []string{"BTCUSDT:*"}}), 1},
+		{"no market", auth.NewContext(context.Background(), &auth.Principal{Name: "c", Markets: []string{"SOLUSDT:*"}}), 0},
+	} {
+		st, err := s.GetStatus(tc.ctx, &pb.StatusRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(st.Markets) != tc.markets {
+			t.Errorf("%s: GetStatus lists %d markets, want %d", tc.name, len(st.Markets), tc.markets)
+		}
+		list, err := s.ListMarkets(tc.ctx, &pb.ListMarketsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list.Markets) != tc.markets {
+			t.Errorf("%s: ListMarkets lists %d markets, want %d", tc.name, len(list.Markets), tc.markets)
+		}
+	}
+}
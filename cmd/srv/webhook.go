@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"github.com/yitech/candles/metrics"
+	pb "github.com/yitech/candles/model/protobuf"
+)
+
+// webhookQueue bounds the alert events waiting for delivery.
+const webhookQueue = 256
+
+// webhookSender POSTs alert events, encoded like the gateway's JSON, to
+// their rules' webhooks from a single goroutine, in firing order.  A
+// request that fails (a network error, a 429 or a 5xx) is retried with
+// exponential backoff; other responses are final.  Events fired while the
+// queue is full are dropped.
+//
+// Rules are created by API clients, so webhooks only connect to public
+// addresses and the allowed networks: the check runs on every address
+// dialed, after name resolution and on redirects, and no proxy is used.
+type webhookSender struct {
+	client  *http.Client
+	retries int
+	queue   chan webhookEvent
+	done    chan struct{}
+}
+
+type webhookEvent struct {
+	url string
+	ev  *pb.AlertEvent
+}
+
+func newWebhookSender(timeout time.Duration, retries int, allowed []netip.Prefix) *webhookSender {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !webhookAllowed(ap.Addr(), allowed) {
+				return fmt.Errorf("webhook address %s is not public or in alerts.webhook_allowed_networks", ap.Addr())
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	w := &webhookSender{
+		client:  &http.Client{Timeout: timeout, Transport: transport},
+		retries: retries,
+		queue:   make(chan webhookEvent, webhookQueue),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// send queues ev for url without blocking.  It must not be called after
+// close.
+func (w *webhookSender) send(url string, ev *pb.AlertEvent) {
+	select {
+	case w.queue <- webhookEvent{url, ev}:
+	default:
+		metrics.AlertDeliveryFailures.WithLabelValues("webhook").Inc()
+		log.Printf("warn: alerts: webhook queue full, dropping rule %s event", ev.Rule.Id)
+	}
+}
+
+func (w *webhookSender) run() {
+	defer close(w.done)
+	for we := range w.queue {
+		body, err := jsonOptions.Marshal(we.ev)
+		if err != nil {
+			log.Printf("warn: alerts: webhook: %v", err)
+			continue
+		}
+		backoff := time.Second
+		for attempt := 0; ; attempt++ {
+			retry, err := w.post(we.url, body)
+			if err == nil {
+				break
+			}
+			if !retry || attempt == w.retries {
+				metrics.AlertDeliveryFailures.WithLabelValues("webhook").Inc()
+				log.Printf("warn: alerts: rule %s: webhook %s: %v (giving up)", we.ev.Rule.Id, we.url, err)
+				break
+			}
+			log.Printf("warn: alerts: rule %s: webhook %s: %v (retrying in %v)", we.ev.Rule.Id, we.url, err, backoff)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+}
+
+// post makes one delivery attempt and reports whether a failure is worth
+// retrying.
+func (w *webhookSender) post(url string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "candles-alerts")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode/100 == 2:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("%s", resp.Status)
+	}
+	return false, fmt.Errorf("%s", resp.Status)
+}
+
+// sharedAddrSpace is the carrier-grade NAT range (RFC 6598), private in
+// all but name.
+var sharedAddrSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// webhookAllowed reports whether webhooks may connect to ip: a public
+// unicast address, or one in allowed.
+func webhookAllowed(ip netip.Addr, allowed []netip.Prefix) bool {
+	ip = ip.Unmap()
+	for _, p := range allowed {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddrSpace.Contains(ip)
+}
+
+// close delivers the queued events and returns once they are done.
+func (w *webhookSender) close() {
+	close(w.queue)
+	<-w.done
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookAllowed(t *testing.T) {
+	allowed := []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")}
+	for _, tc := range []struct {
+		ip string
+		ok bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1::1", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"10.0.0.1", false},
+		{"10.1.2.3", true}, // allowed
+		{"::ffff:10.1.2.3", true},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+	} {
+		if got := webhookAllowed(netip.MustParseAddr(tc.ip), allowed); got != tc.ok {
+			t.Errorf("webhookAllowed(%s) = %t, want %t", tc.ip, got, tc.ok)
+		}
+	}
+}
+
+// TestWebhookRefusesLoopback checks that the sender's dialer refuses an
+// address outside the allowed networks.
+func TestWebhookRefusesLoopback(t *testing.T) {
+	w := newWebhookSender(time.Second, 0, nil)
+	defer w.close()
+	retry, err := w.post("http://127.0.0.1:1/hook", []byte("{}"))
+	if err == nil || !strings.Contains(err.Error(), "not public") {
+		t.Fatalf("post to loopback: retry=%t err=%v, want the address refused", retry, err)
+	}
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/yitech/candles/metrics"
+	pb "github.com/yitech/candles/model/protobuf"
+)
+
+// The WebSocket endpoint lets a browser follow several markets over one
+// connection.  Every frame is a JSON object with a "type".  The client
+// sends:
+//
+//	{"type":"subscribe","id":"1","symbol":"BTCUSDT","interval":"1m",...}
+//	{"type":"unsubscribe","id":"2","symbol":"BTCUSDT","interval":"1m"}
+//	{"type":"ping","id":"3"}
+//
+// where a subscribe carries the fields of a SubscribeRequest, named and
+// parsed like the gateway's query parameters, and id is an optional tag
+// echoed in the replies.  The server answers with "subscribed",
+// "unsubscribed" and "pong", pushes {"type":"candle","id":..,"candle":{..}}
+// for each subscription, and sends a "heartbeat" every wsHeartbeat.  An
+// "error" carries a google.rpc.Status; with a symbol and interval it means
+// that subscription has ended.
+//
+// Each subscription runs server.Subscribe, so it gets the same aggregator
+// subscription, backpressure policy, indicators, history replay and drain
+// as a gRPC stream.
+
+const (
+	// wsHeartbeat is how often the server sends a heartbeat message and a
+	// ping; a connection that has not been heard from, pong included, in
+	// wsReadTimeout is closed.
+	wsHeartbeat   = 15 * time.Second
+	wsReadTimeout = 2*wsHeartbeat + 10*time.Second
+
+	// wsWriteTimeout bounds one write; a client that cannot take a message
+	// in that time is disconnected.
+	wsWriteTimeout = 10 * time.Second
+
+	// wsMaxMessage bounds a client message.
+	wsMaxMessage = 64 << 10
+
+	// wsMaxSubscriptions bounds the markets one connection follows.
+	wsMaxSubscriptions = 64
+)
+
+// wsMessage is a message the server sends.
+type wsMessage struct {
+	Type     string          `json:"type"`
+	ID       string          `json:"id,omitempty"`
+	Symbol   string          `json:"symbol,omitempty"`
+	Interval string          `json:"interval,omitempty"`
+	Candle   json.RawMessage `json:"candle,omitempty"`
+	Error    json.RawMessage `json:"error,omitempty"`
+	Time     int64           `json:"time,omitempty"` // heartbeats, Unix ms
+}
+
+// newWebSocketHandler returns the handler upgrading requests to the
+// WebSocket protocol.  Besides same-origin pages, browsers on the origins
+// listed in corsOrigins ("*" for any) may connect.
+func newWebSocketHandler(s *server, corsOrigins []string) http.HandlerFunc {
+	up := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if origin == "" || slices.Contains(corsOrigins, "*") || slices.Contains(corsOrigins, origin) {
+				return true
+			}
+			u, err := url.Parse(origin)
+			return err == nil && strings.EqualFold(u.Host, r.Host)
+		},
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-s.drain:
+			writeError(w, s.shutdownStatus())
+			return
+		default:
+		}
+		conn, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return // the upgrader has replied
+		}
+		s.websockets.add(conn)
+		defer s.websockets.remove(conn)
+
+		ctx, cancel := context.WithCancel(r.Context())
+		c := &wsConn{s: s, conn: conn, ctx: ctx, subs: make(map[string]*wsSub)}
+		log.Printf("websocket: %s connected", r.RemoteAddr)
+		metrics.WebSocketConnections.Inc()
+		defer metrics.WebSocketConnections.Dec()
+
+		go c.heartbeat()
+		go c.drainOnShutdown()
+		c.read()
+		cancel()
+		c.wg.Wait()
+		conn.Close()
+		log.Printf("websocket: %s disconnected", r.RemoteAddr)
+	}
+}
+
+// wsConn is one WebSocket connection and its subscriptions, keyed by
+// market.
+type wsConn struct {
+	s    *server
+	conn *websocket.Conn
+	ctx  context.Context // canceled when the connection ends
+
+	wmu sync.Mutex // serializes writes
+
+	mu      sync.Mutex
+	subs    map[string]*wsSub
+	closing bool // draining: no new subscriptions
+	wg      sync.WaitGroup
+}
+
+type wsSub struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// read handles client messages until the connection fails or closes.
+func (c *wsConn) read() {
+	c.conn.SetReadLimit(wsMaxMessage)
+	c.conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+	})
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+		var head struct {
+			Type string `json:"type"`
+			ID   string `json:"id"`
+		}
+		if err := json.Unmarshal(data, &head); err != nil {
+			c.fail(wsMessage{}, status.Errorf(codes.InvalidArgument, "malformed message: %v", err))
+			continue
+		}
+		switch head.Type {
+		case "subscribe":
+			req := new(pb.SubscribeRequest)
+			if err := decodeWSRequest(data, req); err != nil {
+				c.fail(wsMessage{ID: head.ID}, status.Error(codes.InvalidArgument, err.Error()))
+				continue
+			}
+			c.subscribe(head.ID, req)
+		case "unsubscribe":
+			req := new(pb.SubscribeRequest)
+			if err := decodeWSRequest(data, req); err != nil {
+				c.fail(wsMessage{ID: head.ID}, status.Error(codes.InvalidArgument, err.Error()))
+				continue
+			}
+			c.unsubscribe(head.ID, req.Symbol, req.Interval)
+		case "ping":
+			c.write(wsMessage{Type: "pong", ID: head.ID})
+		default:
+			c.fail(wsMessage{ID: head.ID}, status.Errorf(codes.InvalidArgument, "unknown message type %q", head.Type))
+		}
+	}
+}
+
+// subscribe starts streaming a market to the connection.
+func (c *wsConn) subscribe(id string, req *pb.SubscribeRequest) {
+	ref := wsMessage{ID: id, Symbol: req.Symbol, Interval: req.Interval}
+	key := req.Symbol + ":" + req.Interval
+	c.mu.Lock()
+	var err error
+	switch {
+	case c.closing:
+		err = c.s.shutdownStatus()
+	case c.subs[key] != nil:
+		err = status.Errorf(codes.AlreadyExists, "already subscribed to %s", key)
+	case len(c.subs) >= wsMaxSubscriptions:
+		err = status.Errorf(codes.ResourceExhausted, "at most %d subscriptions per connection", wsMaxSubscriptions)
+	}
+	if err != nil {
+		c.mu.Unlock()
+		c.fail(ref, err)
+		return
+	}
+	ctx, cancel := context.WithCancel(c.ctx)
+	sub := &wsSub{cancel: cancel, done: make(chan struct{})}
+	c.subs[key] = sub
+	c.wg.Add(1)
+	c.mu.Unlock()
+
+	go func() {
+		defer c.wg.Done()
+		defer close(sub.done)
+		err := c.s.Subscribe(req, &wsStream{c: c, ctx: ctx, ref: ref})
+		c.mu.Lock()
+		if c.subs[key] == sub {
+			delete(c.subs, key)
+		}
+		c.mu.Unlock()
+		if ctx.Err() == nil {
+			c.fail(ref, err)
+		}
+		cancel()
+	}()
+}
+
+// unsubscribe ends the subscription to a market.  The reply follows the
+// last candle the subscription sent.
+func (c *wsConn) unsubscribe(id, symbol, interval string) {
+	ref := wsMessage{ID: id, Symbol: symbol, Interval: interval}
+	key := symbol + ":" + interval
+	c.mu.Lock()
+	sub := c.subs[key]
+	delete(c.subs, key)
+	c.mu.Unlock()
+	if sub == nil {
+		c.fail(ref, status.Errorf(codes.NotFound, "not subscribed to %s", key))
+		return
+	}
+	sub.cancel()
+	<-sub.done
+	ref.Type = "unsubscribed"
+	c.write(ref)
+}
+
+// heartbeat sends a heartbeat message and a ping every wsHeartbeat until
+// the connection ends.
+func (c *wsConn) heartbeat() {
+	t := time.NewTicker(wsHeartbeat)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case now := <-t.C:
+			c.write(wsMessage{Type: "heartbeat", Time: now.UnixMilli()})
+			c.conn.WriteControl(websocket.PingMessage, nil, now.Add(wsWriteTimeout))
+		}
+	}
+}
+
+// drainOnShutdown lets the subscriptions send what they have buffered and
+// report the shutdown status, then closes the connection with "going
+// away".
+func (c *wsConn) drainOnShutdown() {
+	select {
+	case <-c.ctx.Done():
+		return
+	case <-c.s.drain:
+	}
+	c.mu.Lock()
+	c.closing = true
+	c.mu.Unlock()
+	c.wg.Wait()
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteTimeout))
+	// Give the client a moment to answer the close before hanging up.
+	select {
+	case <-c.ctx.Done():
+	case <-time.After(time.Second):
+		c.conn.Close()
+	}
+}
+
+// fail sends err as an error message about ref.
+func (c *wsConn) fail(ref wsMessage, err error) {
+	data, _ := jsonOptions.Marshal(status.Convert(err).Proto())
+	ref.Type = "error"
+	ref.Error = data
+	c.write(ref)
+}
+
+// write sends m.  A failed write closes the connection, which ends read and
+// with it every subscription.
+func (c *wsConn) write(m wsMessage) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return status.Errorf(codes.Internal, "encode: %v", err)
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		c.conn.Close()
+		return err
+	}
+	return nil
+}
+
+// wsStream is the pb.CandleService_SubscribeServer of one subscription.
+// Subscribe sends its header once subscribed, which is acknowledged to
+// the client.
+type wsStream struct {
+	c   *wsConn
+	ctx context.Context
+	ref wsMessage // id, symbol and interval of the subscription
+}
+
+func (st *wsStream) Send(pc *pb.Candle) error {
+	data, err := jsonOptions.Marshal(pc)
+	if err != nil {
+		return status.Errorf(codes.Internal, "encode: %v", err)
+	}
+	return st.c.write(wsMessage{Type: "candle", ID: st.ref.ID, Candle: data})
+}
+
+func (st *wsStream) SendHeader(metadata.MD) error {
+	m := st.ref
+	m.Type = "subscribed"
+	return st.c.write(m)
+}
+
+func (st *wsStream) Context() context.Context    { return st.ctx }
+func (st *wsStream) SetHeader(metadata.MD) error { return nil }
+func (st *wsStream) SetTrailer(metadata.MD)      {}
+func (st *wsStream) SendMsg(m any) error         { return st.Send(m.(*pb.Candle)) }
+func (st *wsStream) RecvMsg(any) error           { return io.EOF }
+
+// decodeWSRequest fills m from the fields of a client message other than
+// type and id, named and parsed like the gateway's query parameters.
+func decodeWSRequest(data []byte, m proto.Message) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	msg := m.ProtoReflect()
+	fds := msg.Descriptor().Fields()
+	for key, raw := range fields {
+		if key == "type" || key == "id" {
+			continue
+		}
+		fd := fds.ByName(protoreflect.Name(key))
+		if fd == nil {
+			fd = fds.ByJSONName(key)
+		}
+		if fd == nil {
+			return fmt.Errorf("unknown field %q", key)
+		}
+		vals, err := jsonValues(raw)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", key, err)
+		}
+		if len(vals) == 0 {
+			continue
+		}
+		if err := setField(msg, fd, vals); err != nil {
+			return fmt.Errorf("field %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// jsonValues turns a JSON scalar, or an array of them, into the strings
+// setField takes; null gives none.
+func jsonValues(raw json.RawMessage) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	list, ok := v.([]any)
+	if !ok {
+		list = []any{v}
+	}
+	var vals []string
+	for _, e := range list {
+		switch e := e.(type) {
+		case nil:
+		case string:
+			vals = append(vals, e)
+		case json.Number:
+			vals = append(vals, e.String())
+		case bool:
+			vals = append(vals, strconv.FormatBool(e))
+		default:
+			return nil, fmt.Errorf("not a scalar or a list of scalars")
+		}
+	}
+	return vals, nil
+}
+
+// wsConns tracks the open WebSocket connections, which
+// http.Server.Shutdown does not wait for.
+type wsConns struct {
+	mu    sync.Mutex
+	conns map[*websocket.Conn]bool
+	wg    sync.WaitGroup
+}
+
+func (t *wsConns) add(c *websocket.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conns == nil {
+		t.conns = make(map[*websocket.Conn]bool)
+	}
+	t.conns[c] = true
+	t.wg.Add(1)
+}
+
+func (t *wsConns) remove(c *websocket.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.conns, c)
+	t.wg.Done()
+}
+
+// wait returns once every connection has ended.
+func (t *wsConns) wait() { t.wg.Wait() }
+
+// closeAll closes the open connections.
+func (t *wsConns) closeAll() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for c := range t.conns {
+		c.Close()
+	}
+}
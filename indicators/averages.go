@@ -0,0 +1,159 @@
+package indicators
+
+import (
+	"math"
+	"slices"
+)
+
+// window holds the last n closes.
+type window struct {
+	vals []float64 // ring, in insertion order until full
+	head int       // index of the oldest value once full
+	n    int
+}
+
+func newWindow(n int) window {
+	return window{vals: make([]float64, 0, n), n: n}
+}
+
+// push adds x, evicting the oldest value if the window is full.
+func (w *window) push(x float64) {
+	if len(w.vals) < w.n {
+		w.vals = append(w.vals, x)
+		return
+	}
+	w.vals[w.head] = x
+	w.head = (w.head + 1) % w.n
+}
+
+// with calls fn for the values the window would hold after pushing x,
+// oldest first, and reports whether it would be full.
+func (w *window) with(x float64, fn func(i int, v float64)) bool {
+	if len(w.vals)+1 < w.n {
+		return false
+	}
+	skip := 0
+	if len(w.vals) == w.n {
+		skip = 1 // the oldest value would be evicted
+	}
+	for i := skip; i < len(w.vals); i++ {
+		fn(i-skip, w.vals[(w.head+i)%len(w.vals)])
+	}
+	fn(w.n-1, x)
+	return true
+}
+
+func (w window) clone() window {
+	w.vals = slices.Clip(slices.Clone(w.vals))
+	return w
+}
+
+// ema is an exponential moving average seeded with the simple average of
+// its first n inputs.  alpha is 2/(n+1), or 1/n for Wilder's smoothing.
+type ema struct {
+	n     int
+	alpha float64
+	count int     // inputs added
+	sum   float64 // of the first n inputs
+	v     float64 // valid once count >= n
+}
+
+func newEMAState(n int, wilder bool) ema {
+	e := ema{n: n, alpha: 2 / float64(n+1)}
+	if wilder {
+		e.alpha = 1 / float64(n)
+	}
+	return e
+}
+
+// next returns the average after x, and whether it is defined yet.
+func (e *ema) next(x float64) (float64, bool) {
+	switch {
+	case e.count+1 < e.n:
+		return 0, false
+	case e.count+1 == e.n:
+		return (e.sum + x) / float64(e.n), true
+	default:
+		return e.v + e.alpha*(x-e.v), true
+	}
+}
+
+func (e *ema) add(x float64) {
+	v, ok := e.next(x)
+	if e.count < e.n {
+		e.sum += x
+	}
+	e.count++
+	if ok {
+		e.v = v
+	}
+}
+
+type sma struct{ w window }
+
+func newSMA(p []float64) calc { return &sma{w: newWindow(int(p[0]))} }
+
+func (s *sma) peek(b bar, out []float64) []float64 {
+	var sum float64
+	if !s.w.with(b.close, func(_ int, v float64) { sum += v }) {
+		return out
+	}
+	return append(out, sum/float64(s.w.n))
+}
+
+func (s *sma) add(b bar)   { s.w.push(b.close) }
+func (s *sma) clone() calc { return &sma{w: s.w.clone()} }
+
+type emaCalc struct{ e ema }
+
+func newEMA(p []float64) calc { return &emaCalc{e: newEMAState(int(p[0]), false)} }
+
+func (c *emaCalc) peek(b bar, out []float64) []float64 {
+	if v, ok := c.e.next(b.close); ok {
+		out = append(out, v)
+	}
+	return out
+}
+
+func (c *emaCalc) add(b bar)   { c.e.add(b.close) }
+func (c *emaCalc) clone() calc { cp := *c; return &cp }
+
+// wma weighs the closes of its window 1 (oldest) to n (newest).
+type wma struct{ w window }
+
+func newWMA(p []float64) calc { return &wma{w: newWindow(int(p[0]))} }
+
+func (m *wma) peek(b bar, out []float64) []float64 {
+	var sum float64
+	if !m.w.with(b.close, func(i int, v float64) { sum += float64(i+1) * v }) {
+		return out
+	}
+	n := float64(m.w.n)
+	return append(out, sum/(n*(n+1)/2))
+}
+
+func (m *wma) add(b bar)   { m.w.push(b.close) }
+func (m *wma) clone() calc { return &wma{w: m.w.clone()} }
+
+// bb is Bollinger Bands: the SMA of the window and k population standard
+// deviations either side of it.
+type bb struct {
+	w window
+	k float64
+}
+
+func newBB(p []float64) calc { return &bb{w: newWindow(int(p[0])), k: p[1]} }
+
+func (c *bb) peek(b bar, out []float64) []float64 {
+	var sum, sumSq float64
+	if !c.w.with(b.close, func(_ int, v float64) { sum += v; sumSq += v * v }) {
+		return out
+	}
+	n := float64(c.w.n)
+	mean := sum / n
+	sd := math.Sqrt(max(sumSq/n-mean*mean, 0))
+	return append(out, mean, mean+c.k*sd, mean-c.k*sd)
+}
+
+func (c *bb) add(b bar)   { c.w.push(b.close) }
+func (c *bb) clone() calc { return &bb{w: c.w.clone(), k: c.k} }
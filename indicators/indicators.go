@@ -0,0 +1,213 @@
+// Package indicators computes technical indicators incrementally over a
+// market's candles: SMA, EMA, WMA, Bollinger Bands, RSI, MACD, ATR, VWAP
+// and OBV.
+//
+// An Indicator is fed every candle of one market in order.  Closed candles
+// advance it; an in-progress candle gives the values as if the period
+// closed with it, without advancing, so every update of a period can carry
+// current values.  A closed candle for the period last advanced over, such
+// as a revision, replaces it.  Candles of older periods are ignored.
+package indicators
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yitech/candles/model/candle"
+)
+
+// Spec names an indicator and its parameters.
+type Spec struct {
+	Name   string    // lower case, e.g. "ema"
+	Params []float64 // all of them, defaults filled in
+}
+
+// kind describes one indicator.
+type kind struct {
+	defaults []float64
+	periods  int      // how many leading params are periods (positive integers)
+	outputs  []string // names of the values, in order
+	new      func(p []float64) calc
+}
+
+var kinds = map[string]kind{
+	"sma":  {defaults: []float64{20}, periods: 1, outputs: []string{"sma"}, new: newSMA},
+	"ema":  {defaults: []float64{20}, periods: 1, outputs: []string{"ema"}, new: newEMA},
+	"wma":  {defaults: []float64{20}, periods: 1, outputs: []string{"wma"}, new: newWMA},
+	"bb":   {defaults: []float64{20, 2}, periods: 1, outputs: []string{"middle", "upper", "lower"}, new: newBB},
+	"rsi":  {defaults: []float64{14}, periods: 1, outputs: []string{"rsi"}, new: newRSI},
+	"macd": {defaults: []float64{12, 26, 9}, periods: 3, outputs: []string{"macd", "signal", "histogram"}, new: newMACD},
+	"atr":  {defaults: []float64{14}, periods: 1, outputs: []string{"atr"}, new: newATR},
+	"vwap": {outputs: []string{"vwap"}, new: newVWAP},
+	"obv":  {outputs: []string{"obv"}, new: newOBV},
+}
+
+// Names lists the supported indicators.
+var Names = []string{"sma", "ema", "wma", "bb", "rsi", "macd", "atr", "vwap", "obv"}
+
+// Parse parses a spec such as "ema(50)", "bb(20, 2.5)" or "rsi".  Names are
+// case-insensitive; missing trailing parameters take their defaults.
+func Parse(s string) (Spec, error) {
+	name, args, hasArgs := strings.Cut(strings.TrimSpace(s), "(")
+	name = strings.ToLower(strings.TrimSpace(name))
+	k, ok := kinds[name]
+	if !ok {
+		return Spec{}, fmt.Errorf("indicators: unknown indicator %q (want one of %s)", name, strings.Join(Names, ", "))
+	}
+	spec := Spec{Name: name, Params: append([]float64(nil), k.defaults...)}
+	if hasArgs {
+		args, ok = strings.CutSuffix(strings.TrimSpace(args), ")")
+		if !ok {
+			return Spec{}, fmt.Errorf("indicators: %q: missing )", s)
+		}
+		var fields []string
+		if strings.TrimSpace(args) != "" {
+			fields = strings.Split(args, ",")
+		}
+		if len(fields) > len(k.defaults) {
+			return Spec{}, fmt.Errorf("indicators: %s takes at most %d parameters", name, len(k.defaults))
+		}
+		for i, f := range fields {
+			v, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+			if err != nil {
+				return Spec{}, fmt.Errorf("indicators: %q: %w", s, err)
+			}
+			spec.Params[i] = v
+		}
+	}
+	if err := spec.validate(k); err != nil {
+		return Spec{}, err
+	}
+	return spec, nil
+}
+
+func (s Spec) validate(k kind) error {
+	for i, v := range s.Params {
+		switch {
+		case i < k.periods && (v < 1 || v != math.Trunc(v) || v > 10000):
+			return fmt.Errorf("indicators: %s: period %v is not an integer between 1 and 10000", s.Name, v)
+		case i >= k.periods && (v <= 0 || math.IsInf(v, 0) || math.IsNaN(v)):
+			return fmt.Errorf("indicators: %s: parameter %v must be positive", s.Name, v)
+		}
+	}
+	if s.Name == "macd" && s.Params[0] >= s.Params[1] {
+		return fmt.Errorf("indicators: macd: fast period %v must be shorter than slow period %v", s.Params[0], s.Params[1])
+	}
+	return nil
+}
+
+// String returns the canonical form of s, e.g. "bb(20,2)" or "obv".
+func (s Spec) String() string {
+	if len(s.Params) == 0 {
+		return s.Name
+	}
+	ps := make([]string, len(s.Params))
+	for i, v := range s.Params {
+		ps[i] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return s.Name + "(" + strings.Join(ps, ",") + ")"
+}
+
+// Outputs names the values of the indicator, in order: e.g. middle, upper
+// and lower for "bb".
+func (s Spec) Outputs() []string {
+	return kinds[s.Name].outputs
+}
+
+// Warmup is how many closed candles of interval the indicator needs before
+// its values no longer depend on where the series started: the period for
+// moving windows, enough periods for exponential smoothing to forget its
+// seed, a UTC day for VWAP, and none for OBV, which is cumulative by
+// definition.
+func (s Spec) Warmup(interval time.Duration) int {
+	switch s.Name {
+	case "sma", "wma", "bb":
+		return int(s.Params[0])
+	case "ema":
+		return 5 * int(s.Params[0])
+	case "rsi", "atr":
+		return 10*int(s.Params[0]) + 1
+	case "macd":
+		return 5*int(s.Params[1]) + 5*int(s.Params[2])
+	case "vwap":
+		if interval <= 0 {
+			return 0
+		}
+		return int((24*time.Hour + interval - 1) / interval)
+	}
+	return 0
+}
+
+// Indicator is one indicator computed over one market's candles.  It is
+// not safe for concurrent use.
+type Indicator struct {
+	spec   Spec
+	calc   calc // through the last closed candle
+	before calc // before the last closed candle; nil until one is added
+	last   int64
+	out    []float64
+}
+
+// New returns the indicator for spec, which must come from Parse.
+func New(spec Spec) *Indicator {
+	return &Indicator{spec: spec, calc: kinds[spec.Name].new(spec.Params)}
+}
+
+// Spec returns the indicator's spec.
+func (ind *Indicator) Spec() Spec { return ind.spec }
+
+// Update folds c into the indicator and returns its values, one per output,
+// or nil while it has too few candles (or c is older than the last closed
+// candle).  The slice is reused by the next call.
+func (ind *Indicator) Update(c *candle.Candle) []float64 {
+	switch {
+	case ind.before != nil && c.OpenTime < ind.last:
+		return nil
+	case ind.before != nil && c.OpenTime == ind.last:
+		if !c.IsClosed {
+			return nil
+		}
+		ind.calc = ind.before.clone() // a revision replaces the period
+	}
+	b := parse(c)
+	ind.out = ind.calc.peek(b, ind.out[:0])
+	if c.IsClosed {
+		ind.before = ind.calc.clone()
+		ind.calc.add(b)
+		ind.last = c.OpenTime
+	}
+	if len(ind.out) == 0 {
+		return nil
+	}
+	return ind.out
+}
+
+// calc is the state of one indicator.
+type calc interface {
+	// peek appends to out the values the indicator would have after b,
+	// or nothing while it would still be warming up.
+	peek(b bar, out []float64) []float64
+	// add advances the indicator over the closed candle b.
+	add(b bar)
+	clone() calc
+}
+
+// bar is a candle's numbers.
+type bar struct {
+	openTime               int64
+	open, high, low, close float64
+	volume                 float64
+}
+
+func parse(c *candle.Candle) bar {
+	b := bar{openTime: c.OpenTime}
+	b.open, _ = strconv.ParseFloat(c.Open, 64)
+	b.high, _ = strconv.ParseFloat(c.High, 64)
+	b.low, _ = strconv.ParseFloat(c.Low, 64)
+	b.close, _ = strconv.ParseFloat(c.Close, 64)
+	b.volume, _ = strconv.ParseFloat(c.Volume, 64)
+	return b
+}
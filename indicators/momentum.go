@@ -0,0 +1,115 @@
+package indicators
+
+import "math"
+
+// rsi is Wilder's relative strength index.
+type rsi struct {
+	gain, loss ema // Wilder averages of the close-to-close changes
+	prev       float64
+	hasPrev    bool
+}
+
+func newRSI(p []float64) calc {
+	n := int(p[0])
+	return &rsi{gain: newEMAState(n, true), loss: newEMAState(n, true)}
+}
+
+func (r *rsi) peek(b bar, out []float64) []float64 {
+	if !r.hasPrev {
+		return out
+	}
+	ch := b.close - r.prev
+	g, ok := r.gain.next(max(ch, 0))
+	l, _ := r.loss.next(max(-ch, 0))
+	switch {
+	case !ok:
+		return out
+	case l == 0 && g == 0:
+		return append(out, 50)
+	case l == 0:
+		return append(out, 100)
+	}
+	return append(out, 100-100/(1+g/l))
+}
+
+func (r *rsi) add(b bar) {
+	if r.hasPrev {
+		ch := b.close - r.prev
+		r.gain.add(max(ch, 0))
+		r.loss.add(max(-ch, 0))
+	}
+	r.prev, r.hasPrev = b.close, true
+}
+
+func (r *rsi) clone() calc { cp := *r; return &cp }
+
+// macd is the fast EMA minus the slow EMA of the close, its signal EMA and
+// the histogram between them.
+type macd struct {
+	fast, slow, signal ema
+}
+
+func newMACD(p []float64) calc {
+	return &macd{
+		fast:   newEMAState(int(p[0]), false),
+		slow:   newEMAState(int(p[1]), false),
+		signal: newEMAState(int(p[2]), false),
+	}
+}
+
+func (m *macd) peek(b bar, out []float64) []float64 {
+	f, _ := m.fast.next(b.close)
+	s, ok := m.slow.next(b.close) // the fast EMA is defined first
+	if !ok {
+		return out
+	}
+	sig, ok := m.signal.next(f - s)
+	if !ok {
+		return out
+	}
+	return append(out, f-s, sig, f-s-sig)
+}
+
+func (m *macd) add(b bar) {
+	f, _ := m.fast.next(b.close)
+	s, ok := m.slow.next(b.close)
+	m.fast.add(b.close)
+	m.slow.add(b.close)
+	if ok {
+		m.signal.add(f - s)
+	}
+}
+
+func (m *macd) clone() calc { cp := *m; return &cp }
+
+// atr is Wilder's average true range.
+type atr struct {
+	e         ema
+	prevClose float64
+	hasPrev   bool
+}
+
+func newATR(p []float64) calc { return &atr{e: newEMAState(int(p[0]), true)} }
+
+// trueRange is the candle's range extended to the previous close.
+func (a *atr) trueRange(b bar) float64 {
+	tr := b.high - b.low
+	if a.hasPrev {
+		tr = max(tr, math.Abs(b.high-a.prevClose), math.Abs(b.low-a.prevClose))
+	}
+	return tr
+}
+
+func (a *atr) peek(b bar, out []float64) []float64 {
+	if v, ok := a.e.next(a.trueRange(b)); ok {
+		out = append(out, v)
+	}
+	return out
+}
+
+func (a *atr) add(b bar) {
+	a.e.add(a.trueRange(b))
+	a.prevClose, a.hasPrev = b.close, true
+}
+
+func (a *atr) clone() calc { cp := *a; return &cp }
@@ -0,0 +1,68 @@
+package indicators
+
+// msPerDay is the length of a VWAP session.
+const msPerDay = 24 * 60 * 60 * 1000
+
+// vwap is the volume-weighted average of the typical price (high + low +
+// close) / 3 since the start of the candle's UTC day.
+type vwap struct {
+	day      int64 // session of pv and vol, in days since the epoch
+	pv, vol  float64
+	sessions int
+}
+
+func newVWAP([]float64) calc { return &vwap{day: -1} }
+
+// session returns the running sums after b.
+func (w *vwap) session(b bar) (pv, vol float64) {
+	if b.openTime/msPerDay == w.day {
+		pv, vol = w.pv, w.vol
+	}
+	tp := (b.high + b.low + b.close) / 3
+	return pv + tp*b.volume, vol + b.volume
+}
+
+func (w *vwap) peek(b bar, out []float64) []float64 {
+	if pv, vol := w.session(b); vol > 0 {
+		out = append(out, pv/vol)
+	}
+	return out
+}
+
+func (w *vwap) add(b bar) {
+	w.pv, w.vol = w.session(b)
+	w.day = b.openTime / msPerDay
+}
+
+func (w *vwap) clone() calc { cp := *w; return &cp }
+
+// obv is on-balance volume: the running sum of the volume of up closes
+// minus that of down closes, starting at 0.
+type obv struct {
+	v       float64
+	prev    float64
+	hasPrev bool
+}
+
+func newOBV([]float64) calc { return &obv{} }
+
+func (o *obv) next(b bar) float64 {
+	switch {
+	case !o.hasPrev:
+		return 0
+	case b.close > o.prev:
+		return o.v + b.volume
+	case b.close < o.prev:
+		return o.v - b.volume
+	}
+	return o.v
+}
+
+func (o *obv) peek(b bar, out []float64) []float64 { return append(out, o.next(b)) }
+
+func (o *obv) add(b bar) {
+	o.v = o.next(b)
+	o.prev, o.hasPrev = b.close, true
+}
+
+func (o *obv) clone() calc { cp := *o; return &cp }
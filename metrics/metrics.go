@@ -0,0 +1,236 @@
+// Package metrics defines the Prometheus collectors shared by the adapters,
+// the aggregator and the server. Collectors register with the default
+// registry; cmd/srv exposes them on its /metrics endpoint.
+//
+// The "market" label is the aggregator key, "SYMBOL:INTERVAL".
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "candles"
+
+// Adapter metrics.
+var (
+	CandlesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "adapter",
+		Name:      "candles_received_total",
+		Help:      "Live candle updates received from an exchange WebSocket.",
+	}, []string{"exchange", "market"})
+
+	ParseErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "adapter",
+		Name:      "parse_errors_total",
+		Help:      "WebSocket messages that could not be parsed.",
+	}, []string{"exchange", "market"})
+
+	Reconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "adapter",
+		Name:      "reconnects_total",
+		Help:      "WebSocket sessions that ended with an error and were retried.",
+	}, []string{"exchange", "market"})
+
+	Connected = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "adapter",
+		Name:      "connected",
+		Help:      "1 while the subscription's WebSocket session is established.",
+	}, []string{"exchange", "market"})
+
+	Backoff = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "adapter",
+		Name:      "backoff_seconds",
+		Help:      "Current reconnect backoff of the subscription; 0 when not backing off.",
+	}, []string{"exchange", "market"})
+
+	BackfillDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "adapter",
+		Name:      "backfill_duration_seconds",
+		Help:      "Latency of REST backfill calls, including pagination.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 10),
+	}, []string{"exchange"})
+
+	BackfillErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "adapter",
+		Name:      "backfill_errors_total",
+		Help:      "REST backfill calls that failed.",
+	}, []string{"exchange"})
+)
+
+// Aggregator metrics.
+var (
+	PeriodsClosed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "aggregator",
+		Name:      "periods_closed_total",
+		Help:      `Finalized periods by reason: "consensus" (every exchange, or the quorum, closed it), "forced" (a newer period started first) or "flush" (shutdown).`,
+	}, []string{"market", "reason"})
+
+	LateCandles = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "aggregator",
+		Name:      "late_candles_dropped_total",
+		Help:      "Exchange candles dropped because their period was already finalized and past the late-data window.",
+	}, []string{"exchange", "market"})
+
+	Revisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "aggregator",
+		Name:      "revisions_total",
+		Help:      "Closed candles re-published because a late exchange update changed them.",
+	}, []string{"market"})
+
+	DispatchDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "aggregator",
+		Name:      "dispatch_dropped_total",
+		Help:      "Candles dropped from a subscriber's queue because its handler fell behind.",
+	}, []string{"market"})
+
+	DivergenceSpread = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "aggregator",
+		Name:      "divergence_spread_bps",
+		Help:      "Spread between the highest and lowest exchange close at the last measured update, in basis points of the median close.",
+	}, []string{"market"})
+
+	DivergenceAlerts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "aggregator",
+		Name:      "divergence_alerts_total",
+		Help:      "Periods whose exchange closes diverged beyond the configured thresholds.",
+	}, []string{"market"})
+
+	SyntheticCandles = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "aggregator",
+		Name:      "synthetic_candles_total",
+		Help:      "Flat candles synthesized for periods no exchange reported.",
+	}, []string{"market"})
+
+	WALAppendErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "aggregator",
+		Name:      "wal_append_errors_total",
+		Help:      "Records that could not be appended to the write-ahead log.",
+	})
+)
+
+// Server metrics.
+var (
+	ActiveStreams = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "server",
+		Name:      "active_streams",
+		Help:      "Open Subscribe streams, WebSocket subscriptions included.",
+	}, []string{"market"})
+
+	WebSocketConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "server",
+		Name:      "websocket_connections",
+		Help:      "Open connections to the WebSocket endpoint.",
+	})
+
+	AuthFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "server",
+		Name:      "auth_failures_total",
+		Help:      `Requests refused by authentication: "missing" or "invalid" credentials, or "forbidden" markets.`,
+	}, []string{"reason"})
+
+	LimitRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "server",
+		Name:      "limit_rejections_total",
+		Help:      "Requests refused with RESOURCE_EXHAUSTED, by the limit reached.",
+	}, []string{"limit"})
+
+	RunningMarkets = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "server",
+		Name:      "running_markets",
+		Help:      "Markets whose exchange feeds are running, as counted against limits.max_markets.",
+	})
+
+	StreamDrops = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "server",
+		Name:      "slow_consumer_drops_total",
+		Help:      "Candles dropped for slow consumers, by backpressure policy.",
+	}, []string{"market", "policy"})
+)
+
+// Alert metrics.  "destination" is "webhook" or "stream".
+var (
+	AlertRules = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "alerts",
+		Name:      "rules",
+		Help:      "Alert rules defined, disabled ones included.",
+	})
+
+	AlertsFired = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "alerts",
+		Name:      "fired_total",
+		Help:      "Alert rule firings.",
+	}, []string{"market"})
+
+	AlertDeliveryFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "alerts",
+		Name:      "delivery_failures_total",
+		Help:      "Alert events not delivered: webhooks that failed every attempt and events dropped because a queue was full.",
+	}, []string{"destination"})
+)
+
+// Archiver metrics.  "exchange" is "aggregated" for the merged series.
+var (
+	ArchiveCoverage = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "archiver",
+		Name:      "coverage_ratio",
+		Help:      "Stored periods over expected periods since the archive start.",
+	}, []string{"exchange", "market"})
+
+	ArchiveMissing = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "archiver",
+		Name:      "missing_periods",
+		Help:      "Expected periods not in the store, including those the exchange has no data for.",
+	}, []string{"exchange", "market"})
+
+	ArchiveFilled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "archiver",
+		Name:      "filled_periods_total",
+		Help:      "Missing periods written by hole filling.",
+	}, []string{"exchange", "market"})
+
+	ArchiveRevised = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "archiver",
+		Name:      "revised_periods_total",
+		Help:      "Stored periods rewritten because the exchange revised them.",
+	}, []string{"exchange", "market"})
+)
+
+// ObserveBackfill records the latency and outcome of a backfill call that
+// started at start.
+func ObserveBackfill(exchange string, start time.Time, err error) {
+	BackfillDuration.WithLabelValues(exchange).Observe(time.Since(start).Seconds())
+	if err != nil {
+		BackfillErrors.WithLabelValues(exchange).Inc()
+	}
+}
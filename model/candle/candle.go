@@ -14,4 +14,18 @@ type Candle struct {
 	Volume    string
 	CloseTime int64
 	IsClosed  bool
+
+	// Synthetic marks a flat candle the aggregator made up for a period in
+	// which no exchange reported trades: O=H=L=C is the previous close and
+	// the volume is zero.
+	Synthetic bool
+
+	// Revision is 0 for the first closed version of a period and counts up
+	// each time the aggregator re-merges the period because an exchange
+	// update arrived after it closed.
+	Revision uint32
+
+	// Seq is assigned by the aggregator: strictly increasing per
+	// "symbol:interval" key. Zero for candles that did not pass through it.
+	Seq uint64
 }
@@ -0,0 +1,56 @@
+package candle
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// IntervalDuration converts an interval string to its length.
+//
+// Accepted forms are a positive count followed by a unit: m (minutes),
+// h/H (hours), d/D (days) or w/W (weeks), e.g. "1m", "4h", "4H", "1d".
+// Calendar months ("1M") have no fixed length and are rejected.
+func IntervalDuration(interval string) (time.Duration, error) {
+	if len(interval) < 2 {
+		return 0, fmt.Errorf("interval %q: want <count><unit>, e.g. 1m", interval)
+	}
+	n, err := strconv.Atoi(interval[:len(interval)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("interval %q: invalid count", interval)
+	}
+
+	var unit time.Duration
+	switch interval[len(interval)-1] {
+	case 'm':
+		unit = time.Minute
+	case 'h', 'H':
+		unit = time.Hour
+	case 'd', 'D':
+		unit = 24 * time.Hour
+	case 'w', 'W':
+		unit = 7 * 24 * time.Hour
+	default:
+		return 0, fmt.Errorf("interval %q: unsupported unit", interval)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// weekOrigin is the first Monday after the Unix epoch, in Unix ms.
+const weekOrigin = 4 * 24 * 60 * 60 * 1000
+
+// PeriodStart returns the OpenTime (Unix ms) of the period of length d that
+// contains t.  Periods are aligned to the Unix epoch, except multiples of a
+// week, which start on Mondays as they do on the exchanges.
+func PeriodStart(t int64, d time.Duration) int64 {
+	step := d.Milliseconds()
+	var origin int64
+	if d%(7*24*time.Hour) == 0 {
+		origin = weekOrigin
+	}
+	off := (t - origin) % step
+	if off < 0 {
+		off += step
+	}
+	return t - off
+}
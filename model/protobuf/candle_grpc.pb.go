@@ -19,7 +19,17 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	CandleService_Subscribe_FullMethodName = "/candle.CandleService/Subscribe"
+	CandleService_Subscribe_FullMethodName   = "/candle.CandleService/Subscribe"
+	CandleService_GetCandles_FullMethodName  = "/candle.CandleService/GetCandles"
+	CandleService_ListMarkets_FullMethodName = "/candle.CandleService/ListMarkets"
+	CandleService_GetStatus_FullMethodName   = "/candle.CandleService/GetStatus"
+	CandleService_Divergence_FullMethodName  = "/candle.CandleService/Divergence"
+	CandleService_CreateAlert_FullMethodName = "/candle.CandleService/CreateAlert"
+	CandleService_GetAlert_FullMethodName    = "/candle.CandleService/GetAlert"
+	CandleService_ListAlerts_FullMethodName  = "/candle.CandleService/ListAlerts"
+	CandleService_UpdateAlert_FullMethodName = "/candle.CandleService/UpdateAlert"
+	CandleService_DeleteAlert_FullMethodName = "/candle.CandleService/DeleteAlert"
+	CandleService_WatchAlerts_FullMethodName = "/candle.CandleService/WatchAlerts"
 )
 
 // CandleServiceClient is the client API for CandleService service.
@@ -27,10 +37,45 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 //
 // CandleService streams real-time aggregated candlestick data.
+//
+// Every RPC is also served as HTTP/JSON by the server's gateway, on the
+// route given in its comment. Request fields not bound by the route are
+// read from the query string (GET, DELETE) or the JSON body (POST, PUT);
+// server streams are sent as Server-Sent Events.
 type CandleServiceClient interface {
 	// Subscribe opens a server-side streaming RPC that pushes aggregated candles
 	// for the requested symbol/interval across all connected exchanges.
+	// HTTP: GET /v1/candles/stream
 	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Candle], error)
+	// GetCandles returns closed candles from the server's history and store.
+	// HTTP: GET /v1/candles
+	GetCandles(ctx context.Context, in *CandlesRequest, opts ...grpc.CallOption) (*CandlesResponse, error)
+	// ListMarkets lists the markets the aggregator is running.
+	// HTTP: GET /v1/markets
+	ListMarkets(ctx context.Context, in *ListMarketsRequest, opts ...grpc.CallOption) (*ListMarketsResponse, error)
+	// GetStatus reports active markets, exchange connections and buffers.
+	// HTTP: GET /v1/status
+	GetStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error)
+	// Divergence streams, for every merged update of a market, how far its
+	// exchanges' closes are apart and which exchange leads.
+	// HTTP: GET /v1/divergence/stream
+	Divergence(ctx context.Context, in *DivergenceRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MarketDivergence], error)
+	// Alert rules. CreateAlert and UpdateAlert return the stored rule;
+	// DeleteAlert returns the rule it deleted. Unknown ids fail with
+	// NOT_FOUND, invalid rules with INVALID_ARGUMENT.
+	// HTTP: POST /v1/alerts (body: the rule)
+	CreateAlert(ctx context.Context, in *AlertRule, opts ...grpc.CallOption) (*AlertRule, error)
+	// HTTP: GET /v1/alerts/{id}
+	GetAlert(ctx context.Context, in *AlertRequest, opts ...grpc.CallOption) (*AlertRule, error)
+	// HTTP: GET /v1/alerts
+	ListAlerts(ctx context.Context, in *ListAlertsRequest, opts ...grpc.CallOption) (*ListAlertsResponse, error)
+	// HTTP: PUT /v1/alerts/{id} (body: the rule)
+	UpdateAlert(ctx context.Context, in *AlertRule, opts ...grpc.CallOption) (*AlertRule, error)
+	// HTTP: DELETE /v1/alerts/{id}
+	DeleteAlert(ctx context.Context, in *AlertRequest, opts ...grpc.CallOption) (*AlertRule, error)
+	// WatchAlerts streams rule firings as they happen.
+	// HTTP: GET /v1/alerts/stream
+	WatchAlerts(ctx context.Context, in *WatchAlertsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AlertEvent], error)
 }
 
 type candleServiceClient struct {
@@ -60,15 +105,168 @@ func (c *candleServiceClient) Subscribe(ctx context.Context, in *SubscribeReques
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type CandleService_SubscribeClient = grpc.ServerStreamingClient[Candle]
 
+func (c *candleServiceClient) GetCandles(ctx context.Context, in *CandlesRequest, opts ...grpc.CallOption) (*CandlesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CandlesResponse)
+	err := c.cc.Invoke(ctx, CandleService_GetCandles_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *candleServiceClient) ListMarkets(ctx context.Context, in *ListMarketsRequest, opts ...grpc.CallOption) (*ListMarketsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListMarketsResponse)
+	err := c.cc.Invoke(ctx, CandleService_ListMarkets_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *candleServiceClient) GetStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StatusResponse)
+	err := c.cc.Invoke(ctx, CandleService_GetStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *candleServiceClient) Divergence(ctx context.Context, in *DivergenceRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MarketDivergence], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &CandleService_ServiceDesc.Streams[1], CandleService_Divergence_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DivergenceRequest, MarketDivergence]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CandleService_DivergenceClient = grpc.ServerStreamingClient[MarketDivergence]
+
+func (c *candleServiceClient) CreateAlert(ctx context.Context, in *AlertRule, opts ...grpc.CallOption) (*AlertRule, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AlertRule)
+	err := c.cc.Invoke(ctx, CandleService_CreateAlert_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *candleServiceClient) GetAlert(ctx context.Context, in *AlertRequest, opts ...grpc.CallOption) (*AlertRule, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AlertRule)
+	err := c.cc.Invoke(ctx, CandleService_GetAlert_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *candleServiceClient) ListAlerts(ctx context.Context, in *ListAlertsRequest, opts ...grpc.CallOption) (*ListAlertsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListAlertsResponse)
+	err := c.cc.Invoke(ctx, CandleService_ListAlerts_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *candleServiceClient) UpdateAlert(ctx context.Context, in *AlertRule, opts ...grpc.CallOption) (*AlertRule, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AlertRule)
+	err := c.cc.Invoke(ctx, CandleService_UpdateAlert_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *candleServiceClient) DeleteAlert(ctx context.Context, in *AlertRequest, opts ...grpc.CallOption) (*AlertRule, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AlertRule)
+	err := c.cc.Invoke(ctx, CandleService_DeleteAlert_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *candleServiceClient) WatchAlerts(ctx context.Context, in *WatchAlertsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AlertEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &CandleService_ServiceDesc.Streams[2], CandleService_WatchAlerts_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchAlertsRequest, AlertEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CandleService_WatchAlertsClient = grpc.ServerStreamingClient[AlertEvent]
+
 // CandleServiceServer is the server API for CandleService service.
 // All implementations must embed UnimplementedCandleServiceServer
 // for forward compatibility.
 //
 // CandleService streams real-time aggregated candlestick data.
+//
+// Every RPC is also served as HTTP/JSON by the server's gateway, on the
+// route given in its comment. Request fields not bound by the route are
+// read from the query string (GET, DELETE) or the JSON body (POST, PUT);
+// server streams are sent as Server-Sent Events.
 type CandleServiceServer interface {
 	// Subscribe opens a server-side streaming RPC that pushes aggregated candles
 	// for the requested symbol/interval across all connected exchanges.
+	// HTTP: GET /v1/candles/stream
 	Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[Candle]) error
+	// GetCandles returns closed candles from the server's history and store.
+	// HTTP: GET /v1/candles
+	GetCandles(context.Context, *CandlesRequest) (*CandlesResponse, error)
+	// ListMarkets lists the markets the aggregator is running.
+	// HTTP: GET /v1/markets
+	ListMarkets(context.Context, *ListMarketsRequest) (*ListMarketsResponse, error)
+	// GetStatus reports active markets, exchange connections and buffers.
+	// HTTP: GET /v1/status
+	GetStatus(context.Context, *StatusRequest) (*StatusResponse, error)
+	// Divergence streams, for every merged update of a market, how far its
+	// exchanges' closes are apart and which exchange leads.
+	// HTTP: GET /v1/divergence/stream
+	Divergence(*DivergenceRequest, grpc.ServerStreamingServer[MarketDivergence]) error
+	// Alert rules. CreateAlert and UpdateAlert return the stored rule;
+	// DeleteAlert returns the rule it deleted. Unknown ids fail with
+	// NOT_FOUND, invalid rules with INVALID_ARGUMENT.
+	// HTTP: POST /v1/alerts (body: the rule)
+	CreateAlert(context.Context, *AlertRule) (*AlertRule, error)
+	// HTTP: GET /v1/alerts/{id}
+	GetAlert(context.Context, *AlertRequest) (*AlertRule, error)
+	// HTTP: GET /v1/alerts
+	ListAlerts(context.Context, *ListAlertsRequest) (*ListAlertsResponse, error)
+	// HTTP: PUT /v1/alerts/{id} (body: the rule)
+	UpdateAlert(context.Context, *AlertRule) (*AlertRule, error)
+	// HTTP: DELETE /v1/alerts/{id}
+	DeleteAlert(context.Context, *AlertRequest) (*AlertRule, error)
+	// WatchAlerts streams rule firings as they happen.
+	// HTTP: GET /v1/alerts/stream
+	WatchAlerts(*WatchAlertsRequest, grpc.ServerStreamingServer[AlertEvent]) error
 	mustEmbedUnimplementedCandleServiceServer()
 }
 
@@ -82,6 +280,36 @@ type UnimplementedCandleServiceServer struct{}
 func (UnimplementedCandleServiceServer) Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[Candle]) error {
 	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
 }
+func (UnimplementedCandleServiceServer) GetCandles(context.Context, *CandlesRequest) (*CandlesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCandles not implemented")
+}
+func (UnimplementedCandleServiceServer) ListMarkets(context.Context, *ListMarketsRequest) (*ListMarketsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMarkets not implemented")
+}
+func (UnimplementedCandleServiceServer) GetStatus(context.Context, *StatusRequest) (*StatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedCandleServiceServer) Divergence(*DivergenceRequest, grpc.ServerStreamingServer[MarketDivergence]) error {
+	return status.Errorf(codes.Unimplemented, "method Divergence not implemented")
+}
+func (UnimplementedCandleServiceServer) CreateAlert(context.Context, *AlertRule) (*AlertRule, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateAlert not implemented")
+}
+func (UnimplementedCandleServiceServer) GetAlert(context.Context, *AlertRequest) (*AlertRule, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAlert not implemented")
+}
+func (UnimplementedCandleServiceServer) ListAlerts(context.Context, *ListAlertsRequest) (*ListAlertsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAlerts not implemented")
+}
+func (UnimplementedCandleServiceServer) UpdateAlert(context.Context, *AlertRule) (*AlertRule, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateAlert not implemented")
+}
+func (UnimplementedCandleServiceServer) DeleteAlert(context.Context, *AlertRequest) (*AlertRule, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteAlert not implemented")
+}
+func (UnimplementedCandleServiceServer) WatchAlerts(*WatchAlertsRequest, grpc.ServerStreamingServer[AlertEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchAlerts not implemented")
+}
 func (UnimplementedCandleServiceServer) mustEmbedUnimplementedCandleServiceServer() {}
 func (UnimplementedCandleServiceServer) testEmbeddedByValue()                       {}
 
@@ -114,19 +342,228 @@ func _CandleService_Subscribe_Handler(srv interface{}, stream grpc.ServerStream)
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type CandleService_SubscribeServer = grpc.ServerStreamingServer[Candle]
 
+func _CandleService_GetCandles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CandlesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CandleServiceServer).GetCandles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CandleService_GetCandles_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CandleServiceServer).GetCandles(ctx, req.(*CandlesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CandleService_ListMarkets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMarketsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CandleServiceServer).ListMarkets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CandleService_ListMarkets_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CandleServiceServer).ListMarkets(ctx, req.(*ListMarketsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CandleService_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CandleServiceServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CandleService_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CandleServiceServer).GetStatus(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CandleService_Divergence_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DivergenceRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CandleServiceServer).Divergence(m, &grpc.GenericServerStream[DivergenceRequest, MarketDivergence]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CandleService_DivergenceServer = grpc.ServerStreamingServer[MarketDivergence]
+
+func _CandleService_CreateAlert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AlertRule)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CandleServiceServer).CreateAlert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CandleService_CreateAlert_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CandleServiceServer).CreateAlert(ctx, req.(*AlertRule))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CandleService_GetAlert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AlertRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CandleServiceServer).GetAlert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CandleService_GetAlert_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CandleServiceServer).GetAlert(ctx, req.(*AlertRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CandleService_ListAlerts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAlertsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CandleServiceServer).ListAlerts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CandleService_ListAlerts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CandleServiceServer).ListAlerts(ctx, req.(*ListAlertsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CandleService_UpdateAlert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AlertRule)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CandleServiceServer).UpdateAlert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CandleService_UpdateAlert_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CandleServiceServer).UpdateAlert(ctx, req.(*AlertRule))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CandleService_DeleteAlert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AlertRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CandleServiceServer).DeleteAlert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CandleService_DeleteAlert_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CandleServiceServer).DeleteAlert(ctx, req.(*AlertRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CandleService_WatchAlerts_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchAlertsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CandleServiceServer).WatchAlerts(m, &grpc.GenericServerStream[WatchAlertsRequest, AlertEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CandleService_WatchAlertsServer = grpc.ServerStreamingServer[AlertEvent]
+
 // CandleService_ServiceDesc is the grpc.ServiceDesc for CandleService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
 var CandleService_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "candle.CandleService",
 	HandlerType: (*CandleServiceServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetCandles",
+			Handler:    _CandleService_GetCandles_Handler,
+		},
+		{
+			MethodName: "ListMarkets",
+			Handler:    _CandleService_ListMarkets_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _CandleService_GetStatus_Handler,
+		},
+		{
+			MethodName: "CreateAlert",
+			Handler:    _CandleService_CreateAlert_Handler,
+		},
+		{
+			MethodName: "GetAlert",
+			Handler:    _CandleService_GetAlert_Handler,
+		},
+		{
+			MethodName: "ListAlerts",
+			Handler:    _CandleService_ListAlerts_Handler,
+		},
+		{
+			MethodName: "UpdateAlert",
+			Handler:    _CandleService_UpdateAlert_Handler,
+		},
+		{
+			MethodName: "DeleteAlert",
+			Handler:    _CandleService_DeleteAlert_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "Subscribe",
 			Handler:       _CandleService_Subscribe_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "Divergence",
+			Handler:       _CandleService_Divergence_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchAlerts",
+			Handler:       _CandleService_WatchAlerts_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "candle.proto",
 }
@@ -0,0 +1,315 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/yitech/candles/model/candle"
+)
+
+// DefaultPruneEvery is how often Bolt applies its retention policy.
+const DefaultPruneEvery = 10 * time.Minute
+
+// pruneBatch is how many candles Prune deletes per transaction.
+const pruneBatch = 10_000
+
+// BoltOptions tunes a Bolt store.  Zero fields take the defaults.
+type BoltOptions struct {
+	Retention  Retention
+	PruneEvery time.Duration
+}
+
+// Bolt is a Store backed by a single bbolt file.  Each series is a bucket
+// named by Series.String, keyed by big-endian OpenTime, so range scans are
+// cursor seeks.
+type Bolt struct {
+	db        *bolt.DB
+	retention Retention
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// record is the on-disk encoding of a candle; the series and OpenTime are
+// implied by the bucket and key.
+type record struct {
+	Open      string `json:"o"`
+	High      string `json:"h"`
+	Low       string `json:"l"`
+	Close     string `json:"c"`
+	Volume    string `json:"v"`
+	CloseTime int64  `json:"ct"`
+	Seq       uint64 `json:"seq,omitempty"`
+	Synthetic bool   `json:"syn,omitempty"`
+	Revision  uint32 `json:"rev,omitempty"`
+}
+
+// OpenBolt opens (creating if needed) the store at path and starts applying
+// opts.Retention in the background.
+func OpenBolt(path string, opts BoltOptions) (*Bolt, error) {
+	if err := opts.Retention.Validate(); err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	if opts.PruneEvery <= 0 {
+		opts.PruneEvery = DefaultPruneEvery
+	}
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("store: open %s: %w", path, err)
+	}
+	b := &Bolt{db: db, retention: opts.Retention, stop: make(chan struct{})}
+	if len(opts.Retention) > 0 {
+		b.wg.Add(1)
+		go b.pruneLoop(opts.PruneEvery)
+	}
+	return b, nil
+}
+
+func (b *Bolt) Put(cs []candle.Candle) error {
+	if len(cs) == 0 {
+		return nil
+	}
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		for i := range cs {
+			c := &cs[i]
+			bkt, err := tx.CreateBucketIfNotExists([]byte(SeriesOf(c).String()))
+			if err != nil {
+				return err
+			}
+			v, err := json.Marshal(record{
+				Open:      c.Open,
+				High:      c.High,
+				Low:       c.Low,
+				Close:     c.Close,
+				Volume:    c.Volume,
+				CloseTime: c.CloseTime,
+				Seq:       c.Seq,
+				Synthetic: c.Synthetic,
+				Revision:  c.Revision,
+			})
+			if err != nil {
+				return err
+			}
+			if err := bkt.Put(timeKey(c.OpenTime), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("store: put: %w", err)
+	}
+	return nil
+}
+
+func (b *Bolt) Range(s Series, start, end int64) ([]candle.Candle, error) {
+	var out []candle.Candle
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(s.String()))
+		if bkt == nil {
+			return nil
+		}
+		c := bkt.Cursor()
+		for k, v := c.Seek(timeKey(start)); k != nil; k, v = c.Next() {
+			t := int64(binary.BigEndian.Uint64(k))
+			if t >= end {
+				break
+			}
+			cd, err := decode(s, t, v)
+			if err != nil {
+				return err
+			}
+			out = append(out, cd)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("store: range %s: %w", s, err)
+	}
+	return out, nil
+}
+
+func (b *Bolt) OpenTimes(s Series, start, end int64) ([]int64, error) {
+	var out []int64
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(s.String()))
+		if bkt == nil {
+			return nil
+		}
+		c := bkt.Cursor()
+		for k, _ := c.Seek(timeKey(start)); k != nil; k, _ = c.Next() {
+			t := int64(binary.BigEndian.Uint64(k))
+			if t >= end {
+				break
+			}
+			out = append(out, t)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("store: open times %s: %w", s, err)
+	}
+	return out, nil
+}
+
+func (b *Bolt) Last(s Series, before int64, n int) ([]candle.Candle, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	out := make([]candle.Candle, 0, n)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(s.String()))
+		if bkt == nil {
+			return nil
+		}
+		// Position on the first key >= before, then walk backwards.
+		c := bkt.Cursor()
+		k, v := c.Seek(timeKey(before))
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		for ; k != nil && len(out) < n; k, v = c.Prev() {
+			cd, err := decode(s, int64(binary.BigEndian.Uint64(k)), v)
+			if err != nil {
+				return err
+			}
+			out = append(out, cd)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("store: last %s: %w", s, err)
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out, nil
+}
+
+// Prune deletes at most pruneBatch candles per transaction, so a large
+// backlog never holds the write lock for long.
+func (b *Bolt) Prune(interval string, before int64) (int, error) {
+	suffix := ":" + interval
+	var names []string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if strings.HasSuffix(string(name), suffix) {
+				names = append(names, string(name))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("store: prune %s: %w", interval, err)
+	}
+	removed := 0
+	for _, name := range names {
+		for {
+			n, err := b.pruneOldest(name, before)
+			removed += n
+			if err != nil {
+				return removed, fmt.Errorf("store: prune %s: %w", name, err)
+			}
+			if n < pruneBatch {
+				break
+			}
+		}
+	}
+	return removed, nil
+}
+
+// pruneOldest deletes up to pruneBatch of the oldest candles of bucket name
+// with OpenTime < before, in one transaction, and returns how many.
+func (b *Bolt) pruneOldest(name string, before int64) (int, error) {
+	n := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(name))
+		if bkt == nil {
+			return nil
+		}
+		// Deleting while iterating skips keys in bbolt, so collect first.
+		var keys [][]byte
+		c := bkt.Cursor()
+		for k, _ := c.First(); k != nil && len(keys) < pruneBatch && int64(binary.BigEndian.Uint64(k)) < before; k, _ = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		for _, k := range keys {
+			if err := bkt.Delete(k); err != nil {
+				return err
+			}
+		}
+		n = len(keys)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Close stops the retention loop and closes the file.
+func (b *Bolt) Close() error {
+	close(b.stop)
+	b.wg.Wait()
+	return b.db.Close()
+}
+
+// pruneLoop applies the retention policy now and then every period.
+func (b *Bolt) pruneLoop(every time.Duration) {
+	defer b.wg.Done()
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		now := time.Now()
+		for iv, keep := range b.retention {
+			n, err := b.Prune(iv, now.Add(-keep).UnixMilli())
+			if err != nil {
+				log.Printf("warn: %v", err)
+			} else if n > 0 {
+				log.Printf("store: pruned %d %s candles older than %v", n, iv, keep)
+			}
+		}
+		select {
+		case <-b.stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func timeKey(openTime int64) []byte {
+	var k [8]byte
+	binary.BigEndian.PutUint64(k[:], uint64(openTime))
+	return k[:]
+}
+
+func decode(s Series, openTime int64, v []byte) (candle.Candle, error) {
+	var r record
+	if err := json.Unmarshal(v, &r); err != nil {
+		return candle.Candle{}, fmt.Errorf("decode %d: %w", openTime, err)
+	}
+	return candle.Candle{
+		Exchange:  s.Exchange,
+		Symbol:    s.Symbol,
+		Interval:  s.Interval,
+		OpenTime:  openTime,
+		Open:      r.Open,
+		High:      r.High,
+		Low:       r.Low,
+		Close:     r.Close,
+		Volume:    r.Volume,
+		CloseTime: r.CloseTime,
+		IsClosed:  true,
+		Seq:       r.Seq,
+		Synthetic: r.Synthetic,
+		Revision:  r.Revision,
+	}, nil
+}
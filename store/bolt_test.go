@@ -0,0 +1,164 @@
+package store
+
+import (
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/yitech/candles/model/candle"
+)
+
+const minute = int64(time.Minute / time.Millisecond)
+
+func openTest(t *testing.T) *Bolt {
+	t.Helper()
+	b, err := OpenBolt(filepath.Join(t.TempDir(), "candles.db"), BoltOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+// series returns n closed candles of s, one a minute from minute 0.
+func series(s Series, n int) []candle.Candle {
+	cs := make([]candle.Candle, n)
+	for i := range cs {
+		p := strconv.Itoa(100 + i)
+		cs[i] = candle.Candle{
+			Exchange:  s.Exchange,
+			Symbol:    s.Symbol,
+			Interval:  s.Interval,
+			OpenTime:  int64(i) * minute,
+			Open:      p,
+			High:      p,
+			Low:       p,
+			Close:     p,
+			Volume:    "1.5",
+			CloseTime: int64(i+1)*minute - 1,
+			IsClosed:  true,
+			Seq:       uint64(i + 1),
+		}
+	}
+	return cs
+}
+
+func TestBoltRoundTrip(t *testing.T) {
+	b := openTest(t)
+	s := Series{"aggregated", "BTCUSDT", "1m"}
+	cs := series(s, 10)
+	cs[3].Synthetic = true
+	cs[4].Revision = 2
+	cs[5].Seq = 0
+	if err := b.Put(cs); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Put(series(Series{"binance", "BTCUSDT", "1m"}, 3)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := b.Range(s, 0, 1<<62)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, cs) {
+		t.Fatalf("Range = %+v, want %+v", got, cs)
+	}
+
+	// Writing an OpenTime again replaces the candle.
+	rev := cs[2]
+	rev.Close, rev.Revision, rev.Seq = "99", 1, 42
+	if err := b.Put([]candle.Candle{rev}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := b.Range(s, 2*minute, 3*minute); err != nil || len(got) != 1 || got[0] != rev {
+		t.Fatalf("Range after replacing = %+v, %v; want %+v", got, err, rev)
+	}
+
+	for _, tc := range []struct {
+		start, end int64
+		want       []int64
+	}{
+		{0, 3 * minute, []int64{0, minute, 2 * minute}},
+		{minute + 1, 3 * minute, []int64{2 * minute}}, // the first at or after start
+		{8 * minute, 1 << 62, []int64{8 * minute, 9 * minute}},
+		{3 * minute, 3 * minute, nil},
+		{20 * minute, 30 * minute, nil},
+	} {
+		times, err := b.OpenTimes(s, tc.start, tc.end)
+		if err != nil || !slices.Equal(times, tc.want) {
+			t.Errorf("OpenTimes(%d, %d) = %v, %v; want %v", tc.start, tc.end, times, err, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		before int64
+		n      int
+		want   []int64
+	}{
+		{1 << 62, 3, []int64{7 * minute, 8 * minute, 9 * minute}},
+		{2 * minute, 5, []int64{0, minute}},
+		{2*minute + 1, 1, []int64{2 * minute}},
+		{0, 5, nil},
+		{1 << 62, 0, nil},
+	} {
+		last, err := b.Last(s, tc.before, tc.n)
+		var times []int64
+		for _, c := range last {
+			times = append(times, c.OpenTime)
+		}
+		if err != nil || !slices.Equal(times, tc.want) {
+			t.Errorf("Last(%d, %d) = %v, %v; want %v", tc.before, tc.n, times, err, tc.want)
+		}
+	}
+
+	if got, err := b.Range(Series{"okx", "BTCUSDT", "1m"}, 0, 1<<62); err != nil || len(got) != 0 {
+		t.Errorf("Range of a series never written = %v, %v", got, err)
+	}
+}
+
+// TestBoltPrune prunes more candles than one transaction deletes, across
+// the series of an interval, and leaves other intervals alone.
+func TestBoltPrune(t *testing.T) {
+	b := openTest(t)
+	n := 2*pruneBatch + 500
+	btc, eth := Series{"aggregated", "BTCUSDT", "1m"}, Series{"binance", "ETHUSDT", "1m"}
+	hourly := Series{"aggregated", "BTCUSDT", "1h"}
+	for _, cs := range [][]candle.Candle{series(btc, n), series(eth, 100), series(hourly, 100), series(Series{"okx", "BTCUSDT", "11m"}, 100)} {
+		if err := b.Put(cs); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	before := int64(n-10) * minute
+	removed, err := b.Prune("1m", before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := n - 10 + 100; removed != want {
+		t.Errorf("pruned %d candles, want %d", removed, want)
+	}
+	for _, tc := range []struct {
+		s    Series
+		want int
+	}{
+		{btc, 10},
+		{eth, 0},
+		{hourly, 100},
+		{Series{"okx", "BTCUSDT", "11m"}, 100},
+	} {
+		times, err := b.OpenTimes(tc.s, 0, 1<<62)
+		if err != nil || len(times) != tc.want {
+			t.Errorf("%s: %d candles left, %v; want %d", tc.s, len(times), err, tc.want)
+		}
+		if tc.s == btc && len(times) > 0 && times[0] != before {
+			t.Errorf("%s: oldest left is %d, want %d", tc.s, times[0], before)
+		}
+	}
+
+	if removed, err := b.Prune("1m", before); err != nil || removed != 0 {
+		t.Errorf("second prune removed %d, %v; want 0", removed, err)
+	}
+}
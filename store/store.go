@@ -0,0 +1,74 @@
+// Package store persists finalized candles so history survives restarts.
+//
+// Candles are grouped into series, one per exchange, symbol and interval;
+// the aggregator writes the merged candle under the exchange "aggregated"
+// alongside each exchange's own contribution.  Within a series a candle is
+// identified by its OpenTime, and writing the same OpenTime again replaces
+// the stored candle.
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yitech/candles/model/candle"
+)
+
+// Series identifies one stream of candles.
+type Series struct {
+	Exchange string
+	Symbol   string
+	Interval string
+}
+
+// SeriesOf returns the series c belongs to.
+func SeriesOf(c *candle.Candle) Series {
+	return Series{Exchange: c.Exchange, Symbol: c.Symbol, Interval: c.Interval}
+}
+
+func (s Series) String() string {
+	return fmt.Sprintf("%s/%s:%s", s.Exchange, s.Symbol, s.Interval)
+}
+
+// Store is a persistent candle store.  Implementations are safe for
+// concurrent use.
+type Store interface {
+	// Put writes closed candles, replacing any stored candle of the same
+	// series and OpenTime.
+	Put(cs []candle.Candle) error
+
+	// Range returns the candles of s with start <= OpenTime < end, oldest
+	// first.
+	Range(s Series, start, end int64) ([]candle.Candle, error)
+
+	// Last returns up to n of the most recent candles of s with
+	// OpenTime < before, oldest first.
+	Last(s Series, before int64, n int) ([]candle.Candle, error)
+
+	// OpenTimes returns the OpenTimes stored for s with
+	// start <= OpenTime < end, in order, without decoding the candles.
+	OpenTimes(s Series, start, end int64) ([]int64, error)
+
+	// Prune deletes the candles of every series of interval with
+	// OpenTime < before and returns how many were removed.
+	Prune(interval string, before int64) (int, error)
+
+	Close() error
+}
+
+// Retention maps an interval ("1m", "1h", ...) to how long its candles are
+// kept.  Intervals without an entry are kept forever.
+type Retention map[string]time.Duration
+
+// Validate checks every interval and duration in r.
+func (r Retention) Validate() error {
+	for iv, d := range r {
+		if _, err := candle.IntervalDuration(iv); err != nil {
+			return err
+		}
+		if d <= 0 {
+			return fmt.Errorf("retention %s: must be positive", iv)
+		}
+	}
+	return nil
+}
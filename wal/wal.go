@@ -0,0 +1,377 @@
+// Package wal is a segmented append-only log with checkpoints.
+//
+// Records are opaque byte slices framed with their length and a CRC-32, so a
+// record torn by a crash is detected and skipped on replay.  The log is a
+// directory of numbered segments ("0000000000000001.wal", ...) and
+// checkpoints ("0000000000000001.ckpt").  A checkpoint numbered N
+// summarises every record in segments up to N; once it is written those
+// segments are deleted, which keeps the log compact.
+//
+// A Log never appends to a segment written by a previous process: Open
+// starts a new segment after the newest one on disk.
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	segmentExt    = ".wal"
+	checkpointExt = ".ckpt"
+
+	// maxRecord bounds a single record so a corrupt length cannot trigger
+	// a huge allocation on replay.
+	maxRecord = 16 << 20
+)
+
+// DefaultSyncInterval is how often appended records are flushed and fsynced.
+const DefaultSyncInterval = time.Second
+
+// ErrClosed is returned by operations on a closed Log.
+var ErrClosed = errors.New("wal: log closed")
+
+// Options tunes a Log.  Zero fields take the defaults.
+type Options struct {
+	// SyncInterval bounds how much of the log a power loss can lose;
+	// a process crash loses at most the records still in the write buffer.
+	SyncInterval time.Duration
+}
+
+// Log is an open write-ahead log.  It is safe for concurrent use.
+type Log struct {
+	dir string
+
+	mu     sync.Mutex
+	seg    uint64 // current segment number
+	f      *os.File
+	w      *bufio.Writer
+	closed bool
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Open opens the log in dir, creating the directory if needed, and starts a
+// new segment for appends.
+func Open(dir string, opts Options) (*Log, error) {
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = DefaultSyncInterval
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("wal: %w", err)
+	}
+	segs, err := list(dir, segmentExt)
+	if err != nil {
+		return nil, err
+	}
+	ckpts, err := list(dir, checkpointExt)
+	if err != nil {
+		return nil, err
+	}
+	var last uint64
+	if n := len(segs); n > 0 {
+		last = segs[n-1]
+	}
+	if n := len(ckpts); n > 0 && ckpts[n-1] > last {
+		last = ckpts[n-1]
+	}
+
+	l := &Log{dir: dir, stop: make(chan struct{})}
+	if err := l.openSegment(last + 1); err != nil {
+		return nil, err
+	}
+	l.wg.Add(1)
+	go l.syncLoop(opts.SyncInterval)
+	return l, nil
+}
+
+// Append adds a record to the current segment.
+func (l *Log) Append(rec []byte) error {
+	if len(rec) > maxRecord {
+		return fmt.Errorf("wal: record of %d bytes exceeds %d", len(rec), maxRecord)
+	}
+	var hdr [8]byte
+	binary.BigEndian.PutUint32(hdr[0:4], uint32(len(rec)))
+	binary.BigEndian.PutUint32(hdr[4:8], crc32.ChecksumIEEE(rec))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	if _, err := l.w.Write(hdr[:]); err != nil {
+		return fmt.Errorf("wal: append: %w", err)
+	}
+	if _, err := l.w.Write(rec); err != nil {
+		return fmt.Errorf("wal: append: %w", err)
+	}
+	return nil
+}
+
+// Replay calls checkpoint with the newest checkpoint on disk (if any), then
+// record for every record appended after it, oldest first.  It must be
+// called before the first Append.  A torn or corrupt record ends the replay
+// of its segment.
+func (l *Log) Replay(checkpoint func([]byte) error, record func([]byte) error) error {
+	ckpts, err := list(l.dir, checkpointExt)
+	if err != nil {
+		return err
+	}
+	var after uint64
+	if n := len(ckpts); n > 0 {
+		after = ckpts[n-1]
+		data, err := os.ReadFile(l.path(after, checkpointExt))
+		if err != nil {
+			return fmt.Errorf("wal: %w", err)
+		}
+		if err := checkpoint(data); err != nil {
+			return fmt.Errorf("wal: checkpoint %d: %w", after, err)
+		}
+	}
+
+	segs, err := list(l.dir, segmentExt)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	current := l.seg
+	l.mu.Unlock()
+	for _, seg := range segs {
+		if seg <= after || seg >= current {
+			continue
+		}
+		if err := l.replaySegment(seg, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rotate flushes the current segment and starts a new one.  It returns the
+// number of the last segment before the new one: a checkpoint taken after
+// Rotate returns covers every record up to it.
+func (l *Log) Rotate() (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return 0, ErrClosed
+	}
+	prev := l.seg
+	if err := l.closeSegment(); err != nil {
+		return 0, err
+	}
+	if err := l.openSegment(prev + 1); err != nil {
+		return 0, err
+	}
+	return prev, nil
+}
+
+// Checkpoint durably stores data as the checkpoint for every segment up to
+// and including seg, then deletes those segments and older checkpoints.
+func (l *Log) Checkpoint(seg uint64, data []byte) error {
+	path := l.path(seg, checkpointExt)
+	tmp := path + ".tmp"
+	if err := writeFileSync(tmp, data); err != nil {
+		return fmt.Errorf("wal: checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("wal: checkpoint: %w", err)
+	}
+	if err := syncDir(l.dir); err != nil {
+		return fmt.Errorf("wal: checkpoint: %w", err)
+	}
+
+	// Compact: everything the checkpoint covers is no longer needed.
+	for _, ext := range []string{segmentExt, checkpointExt} {
+		nums, err := list(l.dir, ext)
+		if err != nil {
+			return err
+		}
+		for _, n := range nums {
+			if n < seg || (n == seg && ext == segmentExt) {
+				if err := os.Remove(l.path(n, ext)); err != nil {
+					return fmt.Errorf("wal: compact: %w", err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Sync flushes buffered records and fsyncs the current segment.
+func (l *Log) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	if err := l.w.Flush(); err != nil {
+		return fmt.Errorf("wal: sync: %w", err)
+	}
+	if err := l.f.Sync(); err != nil {
+		return fmt.Errorf("wal: sync: %w", err)
+	}
+	return nil
+}
+
+// Close flushes and closes the log.  Later appends return ErrClosed.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	err := l.closeSegment()
+	l.mu.Unlock()
+
+	close(l.stop)
+	l.wg.Wait()
+	return err
+}
+
+// ── internal ─────────────────────────────────────────────────────────────────
+
+func (l *Log) path(n uint64, ext string) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%016d%s", n, ext))
+}
+
+// openSegment creates segment n and makes it current (called under lock or
+// before the Log is shared).
+func (l *Log) openSegment(n uint64) error {
+	f, err := os.OpenFile(l.path(n, segmentExt), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("wal: %w", err)
+	}
+	l.seg = n
+	l.f = f
+	l.w = bufio.NewWriterSize(f, 64<<10)
+	return nil
+}
+
+// closeSegment flushes, fsyncs and closes the current segment (called under
+// lock).
+func (l *Log) closeSegment() error {
+	if err := l.w.Flush(); err != nil {
+		l.f.Close()
+		return fmt.Errorf("wal: %w", err)
+	}
+	if err := l.f.Sync(); err != nil {
+		l.f.Close()
+		return fmt.Errorf("wal: %w", err)
+	}
+	if err := l.f.Close(); err != nil {
+		return fmt.Errorf("wal: %w", err)
+	}
+	return nil
+}
+
+func (l *Log) replaySegment(seg uint64, record func([]byte) error) error {
+	f, err := os.Open(l.path(seg, segmentExt))
+	if err != nil {
+		return fmt.Errorf("wal: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, 64<<10)
+	var hdr [8]byte
+	for n := 0; ; n++ {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			if err != io.EOF {
+				log.Printf("warn: wal segment %d: torn header after %d records", seg, n)
+			}
+			return nil
+		}
+		size := binary.BigEndian.Uint32(hdr[0:4])
+		if size > maxRecord {
+			log.Printf("warn: wal segment %d: corrupt length after %d records", seg, n)
+			return nil
+		}
+		rec := make([]byte, size)
+		if _, err := io.ReadFull(r, rec); err != nil {
+			log.Printf("warn: wal segment %d: torn record after %d records", seg, n)
+			return nil
+		}
+		if crc32.ChecksumIEEE(rec) != binary.BigEndian.Uint32(hdr[4:8]) {
+			log.Printf("warn: wal segment %d: checksum mismatch after %d records", seg, n)
+			return nil
+		}
+		if err := record(rec); err != nil {
+			return fmt.Errorf("wal: segment %d record %d: %w", seg, n, err)
+		}
+	}
+}
+
+func (l *Log) syncLoop(every time.Duration) {
+	defer l.wg.Done()
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-t.C:
+			if err := l.Sync(); err != nil && !errors.Is(err, ErrClosed) {
+				log.Printf("warn: %v", err)
+			}
+		}
+	}
+}
+
+// list returns the numbers of the files in dir with extension ext, sorted.
+func list(dir, ext string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("wal: %w", err)
+	}
+	var out []uint64
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ext)
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseUint(name, 10, 64); err == nil {
+			out = append(out, n)
+		}
+	}
+	slices.Sort(out)
+	return out, nil
+}
+
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,197 @@
+package wal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+)
+
+// appendAll appends records "<prefix>0", "<prefix>1", ... to l.
+func appendAll(t *testing.T, l *Log, prefix string, n int) {
+	t.Helper()
+	for i := range n {
+		if err := l.Append([]byte(fmt.Sprintf("%s%d", prefix, i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// replayAll reopens the log in dir and returns its checkpoint and records.
+func replayAll(t *testing.T, dir string) (string, []string) {
+	t.Helper()
+	l, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	var ckpt string
+	var recs []string
+	err = l.Replay(func(b []byte) error {
+		ckpt = string(b)
+		return nil
+	}, func(b []byte) error {
+		recs = append(recs, string(b))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ckpt, recs
+}
+
+// segments returns the numbers of the segments in dir.
+func segments(t *testing.T, dir string) []uint64 {
+	t.Helper()
+	segs, err := list(dir, segmentExt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return segs
+}
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendAll(t, l, "a", 3)
+	if _, err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	appendAll(t, l, "b", 2)
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Append([]byte("x")); err != ErrClosed {
+		t.Fatalf("append after close: %v, want ErrClosed", err)
+	}
+
+	ckpt, recs := replayAll(t, dir)
+	if want := []string{"a0", "a1", "a2", "b0", "b1"}; ckpt != "" || !slices.Equal(recs, want) {
+		t.Fatalf("replayed %q %q, want no checkpoint and %q", ckpt, recs, want)
+	}
+}
+
+// TestTornLastRecord checks that a record cut short by a crash is skipped
+// and every record before it is replayed.
+func TestTornLastRecord(t *testing.T) {
+	for _, cut := range []int{1, 4, 9} { // into the payload, the CRC, the length
+		t.Run(fmt.Sprint(cut), func(t *testing.T) {
+			dir := t.TempDir()
+			l, err := Open(dir, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			appendAll(t, l, "r", 3) // 8-byte header + 2-byte payload each
+			if err := l.Close(); err != nil {
+				t.Fatal(err)
+			}
+			path := l.path(segments(t, dir)[0], segmentExt)
+			fi, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Truncate(path, fi.Size()-int64(cut)); err != nil {
+				t.Fatal(err)
+			}
+
+			_, recs := replayAll(t, dir)
+			if want := []string{"r0", "r1"}; !slices.Equal(recs, want) {
+				t.Fatalf("replayed %q, want %q", recs, want)
+			}
+		})
+	}
+}
+
+// TestBadChecksum checks that a corrupt record ends the replay of its
+// segment, and that later segments are still replayed.
+func TestBadChecksum(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendAll(t, l, "a", 4)
+	if _, err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	appendAll(t, l, "b", 2)
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip a bit in the CRC of the second record of the first segment.
+	path := l.path(segments(t, dir)[0], segmentExt)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const recLen = 8 + 2
+	crc := b[recLen+4 : recLen+8]
+	binary.BigEndian.PutUint32(crc, binary.BigEndian.Uint32(crc)^1)
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, recs := replayAll(t, dir)
+	if want := []string{"a0", "b0", "b1"}; !slices.Equal(recs, want) {
+		t.Fatalf("replayed %q, want %q", recs, want)
+	}
+}
+
+// TestReplayAfterCompaction checks that a checkpoint replaces the segments
+// it covers: replay hands over the checkpoint and only the records after it.
+func TestReplayAfterCompaction(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendAll(t, l, "a", 3)
+	seg, err := l.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendAll(t, l, "b", 2)
+	if err := l.Checkpoint(seg, []byte("ckpt1")); err != nil {
+		t.Fatal(err)
+	}
+	seg, err = l.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendAll(t, l, "c", 2)
+	if err := l.Checkpoint(seg, []byte("ckpt2")); err != nil {
+		t.Fatal(err)
+	}
+	appendAll(t, l, "d", 1)
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if segs := segments(t, dir); !slices.Equal(segs, []uint64{seg + 1}) {
+		t.Fatalf("segments %v after compaction, want [%d]", segs, seg+1)
+	}
+	ckpts, err := list(dir, checkpointExt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ckpts, []uint64{seg}) {
+		t.Fatalf("checkpoints %v after compaction, want [%d]", ckpts, seg)
+	}
+
+	ckpt, recs := replayAll(t, dir)
+	if want := []string{"c0", "c1", "d0"}; ckpt != "ckpt2" || !slices.Equal(recs, want) {
+		t.Fatalf("replayed %q %q, want %q %q", ckpt, recs, "ckpt2", want)
+	}
+
+	// The reopened log started a segment of its own; replaying again still
+	// finds the same records.
+	ckpt, recs = replayAll(t, dir)
+	if want := []string{"c0", "c1", "d0"}; ckpt != "ckpt2" || !slices.Equal(recs, want) {
+		t.Fatalf("second replay %q %q, want %q %q", ckpt, recs, "ckpt2", want)
+	}
+}